@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/audit"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/installer"
+	"github.com/droy-go/droy-pm/pkg/resolver"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditJSON   bool
+	auditDryRun bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check dependencies for known vulnerabilities",
+	Long: `Check every package in droy.lock, or the resolved dependency tree when
+droy.lock is missing or out of date, against the registry's advisory
+database.
+
+The advisory-file setting (or --advisory-file) names a local JSON advisory
+database that is checked as well, and instead of the registry with
+--offline. It uses the format of the registry's bulk endpoint: an object
+mapping package names to lists of advisories.
+
+audit exits with status 1 when it finds a vulnerability at or above
+--audit-level (low, moderate, high or critical; default low).`,
+	Example: `  droy-pm audit
+  droy-pm audit --audit-level high            # Fail only on high and critical
+  droy-pm audit --json
+  droy-pm audit --offline --advisory-file advisories.json
+  droy-pm audit fix`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		level, err := audit.ParseSeverity(settings.Get("audit-level"))
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+
+		project, err := auditTarget()
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+
+		advisories, err := loadAdvisories(project.lock, project.deps)
+		if err != nil {
+			logger.Error("Failed to fetch advisories: %v", err)
+			os.Exit(1)
+		}
+
+		report, err := audit.Check(project.lock, project.deps, advisories)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+
+		if auditJSON {
+			// Keep ranges such as "<1.2.3" readable
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				logger.Error("Failed to encode report: %v", err)
+				os.Exit(1)
+			}
+		} else {
+			printAuditReport(project.pkg, report)
+		}
+
+		if report.Count(level) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var auditFixCmd = &cobra.Command{
+	Use:   "fix",
+	Short: "Update vulnerable packages within the ranges in droy.toml",
+	Long: `Update vulnerable packages to the newest versions without known
+vulnerabilities that the ranges in droy.toml and in other packages'
+dependencies allow, then install them. Other packages stay at their
+locked versions where possible.
+
+Vulnerabilities that can only be fixed by a version outside these ranges
+are reported; update droy.toml to fix them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Inside a workspace, its root droy.lock is the one installed
+		ws, err := enterWorkspace()
+		if err != nil {
+			return err
+		}
+
+		project, err := auditTarget()
+		if err != nil {
+			return err
+		}
+
+		advisories, err := loadAdvisories(project.lock, project.deps)
+		if err != nil {
+			return fmt.Errorf("failed to fetch advisories: %w", err)
+		}
+
+		report, err := audit.Check(project.lock, project.deps, advisories)
+		if err != nil {
+			return err
+		}
+		vulnerable := report.Vulnerable()
+		if len(vulnerable) == 0 {
+			logger.Success("No known vulnerabilities in %d packages", report.Packages)
+			return nil
+		}
+
+		fixed, unfixed := fixLock(project, advisories, vulnerable)
+		changes := lockChanges(project.lock, fixed)
+		for _, change := range changes {
+			fmt.Printf("  %s\n", change)
+		}
+		for _, name := range unfixed {
+			logger.Warning("No version of %s without known vulnerabilities fits the ranges in droy.toml", name)
+		}
+
+		if len(changes) == 0 {
+			return fmt.Errorf("nothing could be fixed without changing droy.toml")
+		}
+		if auditDryRun {
+			logger.Info("Dry run - droy.lock and droy_modules were not changed")
+			return nil
+		}
+
+		// The fixed droy.lock is written inside the transaction so that a
+		// failed install puts the old one back too
+		err = inTransaction(nil, func(inst *installer.Installer) error {
+			if err := config.WriteLockFile(fixed, "droy.lock"); err != nil {
+				return fmt.Errorf("failed to write droy.lock: %w", err)
+			}
+			return installDependencies(inst, ws)
+		})
+		if err != nil {
+			return err
+		}
+
+		logger.Success("Fixed %d of %d vulnerable packages", len(vulnerable)-len(unfixed), len(vulnerable))
+		return nil
+	},
+}
+
+// auditProject is the project an audit checks
+type auditProject struct {
+	pkg  *config.Package
+	lock *config.LockFile
+
+	// deps are the dependencies the lock covers; local holds the
+	// workspace members at a workspace root
+	deps  map[string]string
+	local map[string]*config.Package
+}
+
+// auditTarget returns the project with its lock, or a freshly resolved one
+// when droy.lock is missing or no longer matches droy.toml
+func auditTarget() (*auditProject, error) {
+	pkg, err := config.ReadPackageConfig("droy.toml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read droy.toml: %w", err)
+	}
+	deps, local, err := projectDependencies(pkg)
+	if err != nil {
+		return nil, err
+	}
+	project := &auditProject{pkg: pkg, deps: deps, local: local}
+
+	lock, err := config.ReadLockFile("droy.lock")
+	if err == nil && resolver.CheckLock(lock, deps) == nil && resolver.CheckLocal(lock, local) == nil {
+		project.lock = lock
+		return project, nil
+	}
+
+	res := newResolver()
+	res.Root = pkg.Name
+	res.Local = local
+	if _, err := res.Resolve(deps); err != nil {
+		return nil, fmt.Errorf("failed to resolve dependencies: %w", err)
+	}
+	project.lock = res.BuildLock(pkg, deps, lock)
+	return project, nil
+}
+
+// loadAdvisories gathers the advisories for the packages in lock from the
+// registry, unless offline, and from the advisory file if one is set
+func loadAdvisories(lock *config.LockFile, deps map[string]string) (audit.Advisories, error) {
+	advisories := make(audit.Advisories)
+
+	file := settings.Path("advisory-file")
+	if file != "" {
+		local, err := audit.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		advisories.Merge(local)
+	}
+
+	if settings.Bool("offline") {
+		if file == "" {
+			return nil, fmt.Errorf("set advisory-file to audit offline")
+		}
+		return advisories, nil
+	}
+
+	versions := audit.Versions(lock, deps)
+	if len(versions) == 0 {
+		return advisories, nil
+	}
+
+	remote, err := getRegistry("").Advisories(versions)
+	if err != nil {
+		if file == "" {
+			return nil, err
+		}
+		logger.Warning("Using only %s: %v", file, err)
+		return advisories, nil
+	}
+	advisories.Merge(remote)
+	return advisories, nil
+}
+
+// fixLock re-resolves the dependencies without the affected versions of
+// the vulnerable packages, one package at a time so that a package that
+// cannot be fixed does not hold back the others. It returns the new lock
+// and the packages it could not fix
+func fixLock(project *auditProject, advisories audit.Advisories, vulnerable []string) (*config.LockFile, []string) {
+	avoid := make(map[string]bool)
+	fixed := project.lock
+	var unfixed []string
+
+	for _, name := range vulnerable {
+		avoid[name] = true
+
+		res := newResolver()
+		res.Root = project.pkg.Name
+		res.Local = project.local
+		res.Prefer = resolver.LockedVersions(project.lock, project.deps)
+		res.Skip = func(dep, version string) bool {
+			if !avoid[dep] {
+				return false
+			}
+			// Versions that cannot be checked are avoided as well
+			affecting, err := advisories.Affecting(dep, version)
+			return err != nil || len(affecting) > 0
+		}
+
+		if _, err := res.Resolve(project.deps); err != nil {
+			delete(avoid, name)
+			unfixed = append(unfixed, name)
+			continue
+		}
+		fixed = res.BuildLock(project.pkg, project.deps, project.lock)
+	}
+
+	return fixed, unfixed
+}
+
+// lockChanges describes the packages whose version differs between two
+// locks, sorted by name
+func lockChanges(before, after *config.LockFile) []string {
+	var names []string
+	for name := range before.Packages {
+		names = append(names, name)
+	}
+	for name := range after.Packages {
+		if before.Packages[name] == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []string
+	for _, name := range names {
+		was, now := before.Packages[name], after.Packages[name]
+		switch {
+		case was == nil:
+			changes = append(changes, fmt.Sprintf("%s %s@%s", color.GreenString("+"), name, now.Version))
+		case now == nil:
+			changes = append(changes, fmt.Sprintf("%s %s@%s", color.RedString("-"), name, was.Version))
+		case was.Version != now.Version:
+			changes = append(changes, fmt.Sprintf("%s %s %s -> %s", color.CyanString("~"), name, was.Version, now.Version))
+		}
+	}
+	return changes
+}
+
+// severityColors highlights severities in audit output
+var severityColors = map[audit.Severity]func(format string, a ...interface{}) string{
+	audit.Low:      color.WhiteString,
+	audit.Moderate: color.YellowString,
+	audit.High:     color.RedString,
+	audit.Critical: color.New(color.FgRed, color.Bold).SprintfFunc(),
+}
+
+func printAuditReport(pkg *config.Package, report *audit.Report) {
+	if len(report.Findings) == 0 {
+		logger.Success("No known vulnerabilities in %d packages", report.Packages)
+		return
+	}
+
+	for _, f := range report.Findings {
+		adv := f.Advisory
+		fmt.Printf("%s %s@%s  %s\n",
+			severityColors[f.Severity]("%-8s", f.Severity),
+			color.CyanString(f.Name), f.Version, adv.Title)
+
+		if adv.ID != "" {
+			fmt.Printf("  %-11s %s\n", "Advisory:", adv.ID)
+		}
+		fmt.Printf("  %-11s %s\n", "Vulnerable:", adv.VulnerableVersions)
+		if adv.PatchedVersions != "" {
+			fmt.Printf("  %-11s %s\n", "Patched:", adv.PatchedVersions)
+		} else {
+			fmt.Printf("  %-11s %s\n", "Patched:", color.RedString("no fix available"))
+		}
+		fmt.Printf("  %-11s %s\n", "Path:", strings.Join(append([]string{pkg.Name}, f.Path...), " > "))
+		if adv.URL != "" {
+			fmt.Printf("  %-11s %s\n", "More info:", adv.URL)
+		}
+		fmt.Println()
+	}
+
+	var counts []string
+	for s := audit.Critical; s >= audit.Low; s-- {
+		n := report.Count(s) - report.Count(s+1)
+		if n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, s))
+		}
+	}
+	logger.Warning("Found %d vulnerabilities (%s) in %d packages",
+		len(report.Findings), strings.Join(counts, ", "), report.Packages)
+	logger.Info("Run 'droy-pm audit fix' to update vulnerable packages within the ranges in droy.toml")
+}
+
+func init() {
+	auditCmd.PersistentFlags().String("advisory-file", "", "Local JSON advisory database to check as well (default from config)")
+	auditCmd.Flags().String("audit-level", "", "Lowest severity that fails the audit: low, moderate, high or critical")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "Print the report as JSON")
+	auditFixCmd.Flags().BoolVar(&auditDryRun, "dry-run", false, "Show what would change without changing anything")
+
+	auditCmd.AddCommand(auditFixCmd)
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/workspace"
 	"github.com/droy-go/droy-pm/internal/logger"
 	"github.com/spf13/cobra"
 )
@@ -23,96 +24,115 @@ var buildCmd = &cobra.Command{
 	Use:   "build [file.droy]",
 	Short: "Build a Droy program",
 	Long: `Compile a Droy program to an executable or LLVM IR.
-If no file is specified, builds the main file from droy.toml.`,
+If no file is specified, builds the main file from droy.toml.
+
+With --workspace or --filter, builds each selected workspace member, members
+before the members that depend on them.`,
 	Example: `  droy-pm build                    # Build default main file
   droy-pm build app.droy           # Build specific file
   droy-pm build -o output          # Specify output name
-  droy-pm build --target=llvm      # Build to LLVM IR`,
+  droy-pm build --target=llvm      # Build to LLVM IR
+  droy-pm build --filter 'packages/*' # Build the members under packages/`,
 	Run: func(cmd *cobra.Command, args []string) {
-		var targetFile string
-		
-		if len(args) > 0 {
-			targetFile = args[0]
-		} else {
-			// Try to get from droy.toml
-			pkg, err := config.ReadPackageConfig("droy.toml")
-			if err == nil && pkg.Main != "" {
-				targetFile = pkg.Main
-			} else {
-				// Default to src/main.droy
-				targetFile = "src/main.droy"
-			}
-		}
-
-		// Check if file exists
-		if _, err := os.Stat(targetFile); os.IsNotExist(err) {
-			logger.Error("File not found: %s", targetFile)
+		if workspaceMode() {
+			forEachMember(func(m *workspace.Member) bool {
+				return buildProgram(args)
+			})
 			return
 		}
 
-		// Determine output name
-		if buildOutput == "" {
-			base := filepath.Base(targetFile)
-			buildOutput = strings.TrimSuffix(base, filepath.Ext(base))
+		buildProgram(args)
+	},
+}
+
+// buildProgram compiles a Droy file, or the main file of the package in
+// the current directory, and reports whether it succeeded
+func buildProgram(args []string) bool {
+	var targetFile string
+	
+	if len(args) > 0 {
+		targetFile = args[0]
+	} else {
+		// Try to get from droy.toml
+		pkg, err := config.ReadPackageConfig("droy.toml")
+		if err == nil && pkg.Main != "" {
+			targetFile = pkg.Main
+		} else {
+			// Default to src/main.droy
+			targetFile = "src/main.droy"
 		}
+	}
 
-		logger.Info("Building %s...", targetFile)
+	// Check if file exists
+	if _, err := os.Stat(targetFile); os.IsNotExist(err) {
+		logger.Error("File not found: %s", targetFile)
+		return false
+	}
 
-		// Find the Droy compiler
-		droyPath := findDroyCompiler()
-		if droyPath == "" {
-			logger.Error("Droy compiler not found")
-			logger.Info("Make sure Droy is installed and in your PATH")
-			return
-		}
+	// Determine output name
+	output := buildOutput
+	if output == "" {
+		base := filepath.Base(targetFile)
+		output = strings.TrimSuffix(base, filepath.Ext(base))
+	}
 
-		// Build command arguments
-		var execArgs []string
-		
-		if buildTarget == "llvm" {
-			// Build to LLVM IR
-			execArgs = append(execArgs, "-c", "-o", buildOutput+".ll", targetFile)
-		} else {
-			// Build to executable
-			execArgs = append(execArgs, targetFile)
-			if buildOutput != "" {
-				execArgs = append(execArgs, "-o", buildOutput)
-			}
-		}
+	logger.Info("Building %s...", targetFile)
 
-		if buildVerbose {
-			logger.Info("Command: %s %s", droyPath, strings.Join(execArgs, " "))
+	// Find the Droy compiler
+	droyPath := findDroyCompiler()
+	if droyPath == "" {
+		logger.Error("Droy compiler not found")
+		logger.Info("Make sure Droy is installed and in your PATH")
+		return false
+	}
+
+	// Build command arguments
+	var execArgs []string
+	
+	if buildTarget == "llvm" {
+		// Build to LLVM IR
+		execArgs = append(execArgs, "-c", "-o", output+".ll", targetFile)
+	} else {
+		// Build to executable
+		execArgs = append(execArgs, targetFile)
+		if output != "" {
+			execArgs = append(execArgs, "-o", output)
 		}
+	}
 
-		// Execute build
-		execCmd := exec.Command(droyPath, execArgs...)
-		execCmd.Stdin = os.Stdin
-		execCmd.Stdout = os.Stdout
-		execCmd.Stderr = os.Stderr
+	if buildVerbose {
+		logger.Info("Command: %s %s", droyPath, strings.Join(execArgs, " "))
+	}
 
-		if err := execCmd.Run(); err != nil {
-			logger.Error("Build failed: %v", err)
-			return
-		}
+	// Execute build
+	execCmd := exec.Command(droyPath, execArgs...)
+	execCmd.Stdin = os.Stdin
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
 
-		// Check if output was created
-		if buildTarget == "llvm" {
-			buildOutput += ".ll"
-		}
-		
-		if _, err := os.Stat(buildOutput); os.IsNotExist(err) {
-			logger.Warning("Build completed but output file not found")
-			return
-		}
+	if err := execCmd.Run(); err != nil {
+		logger.Error("Build failed: %v", err)
+		return false
+	}
 
-		// Get file info
-		if info, err := os.Stat(buildOutput); err == nil {
-			size := formatFileSize(info.Size())
-			logger.Success("Built %s (%s)", buildOutput, size)
-		} else {
-			logger.Success("Built %s", buildOutput)
-		}
-	},
+	// Check if output was created
+	if buildTarget == "llvm" {
+		output += ".ll"
+	}
+	
+	if _, err := os.Stat(output); os.IsNotExist(err) {
+		logger.Warning("Build completed but output file not found")
+		return true
+	}
+
+	// Get file info
+	if info, err := os.Stat(output); err == nil {
+		size := formatFileSize(info.Size())
+		logger.Success("Built %s (%s)", output, size)
+	} else {
+		logger.Success("Built %s", output)
+	}
+	return true
 }
 
 var cleanBuildCmd = &cobra.Command{
@@ -210,6 +230,7 @@ func init() {
 	buildCmd.Flags().StringVarP(&buildTarget, "target", "t", "", "Build target (native, llvm)")
 	buildCmd.Flags().BoolVarP(&buildOptimize, "optimize", "O", false, "Enable optimizations")
 	buildCmd.Flags().BoolVarP(&buildVerbose, "verbose", "v", false, "Verbose output")
+	addWorkspaceFlags(buildCmd)
 
 	rootCmd.AddCommand(buildCmd)
 	rootCmd.AddCommand(cleanBuildCmd)
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/droy-go/droy-pm/pkg/installer"
+	"github.com/spf13/cobra"
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Clean install from droy.lock",
+	Long: `Install dependencies exactly as recorded in droy.lock, for CI and
+other reproducible builds.
+
+droy_modules is removed first, and the command fails instead of updating
+droy.lock when it no longer matches droy.toml.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := removeModulesDir(); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", modulesDir(), err)
+		}
+
+		installFrozen = true
+		return installAllDependencies()
+	},
+}
+
+func init() {
+	ciCmd.Flags().BoolVarP(&installDev, "dev", "D", false, "Also install dev dependencies")
+	ciCmd.Flags().IntP("concurrency", "j", installer.DefaultConcurrency, "Number of packages to install in parallel")
+}
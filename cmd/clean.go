@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/droy-go/droy-pm/internal/logger"
 	"github.com/spf13/cobra"
@@ -16,10 +18,11 @@ var cleanCmd = &cobra.Command{
 		logger.Info("Cleaning up...")
 
 		// Remove droy_modules
-		if err := os.RemoveAll("droy_modules"); err != nil {
-			logger.Warning("Failed to remove droy_modules: %v", err)
+		modules := modulesDir()
+		if err := removeModulesDir(); err != nil {
+			logger.Warning("Failed to remove %s: %v", modules, err)
 		} else {
-			logger.Info("Removed droy_modules/")
+			logger.Info("Removed %s/", modules)
 		}
 
 		// Remove lock file
@@ -30,16 +33,42 @@ var cleanCmd = &cobra.Command{
 		}
 
 		// Clean cache
-		homeDir, err := os.UserHomeDir()
-		if err == nil {
-			cachePath := filepath.Join(homeDir, ".droy", "cache")
-			if err := os.RemoveAll(cachePath); err != nil {
-				logger.Warning("Failed to clean cache: %v", err)
-			} else {
-				logger.Info("Cleaned cache")
-			}
+		if err := os.RemoveAll(settings.Path("cache-dir")); err != nil {
+			logger.Warning("Failed to clean cache: %v", err)
+		} else {
+			logger.Info("Cleaned cache")
 		}
 
 		logger.Success("Cleanup complete")
 	},
 }
+
+// removeModulesDir deletes the modules directory. modules-dir is checked to
+// be inside the project when it is set, but a symlinked parent directory
+// could still lead elsewhere, so the real path is checked again here
+func removeModulesDir() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(cwd)
+	if err != nil {
+		return err
+	}
+
+	modules := filepath.Join(cwd, modulesDir())
+	parent, err := filepath.EvalSymlinks(filepath.Dir(modules))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// A symlink at modules itself is removed, not followed
+	rel, err := filepath.Rel(root, filepath.Join(parent, filepath.Base(modules)))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is not inside the project", modulesDir())
+	}
+	return os.RemoveAll(modules)
+}
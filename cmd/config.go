@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// settings is the effective configuration of this run, loaded before
+	// every command
+	settings = config.DefaultSettings()
+
+	configProject bool
+)
+
+// loadSettings loads the configuration layers and applies the flags of
+// cmd that share a name with a config key, such as --registry or --offline
+func loadSettings(cmd *cobra.Command) error {
+	s, err := config.LoadSettings()
+	if err != nil {
+		// Let the config commands run so that a broken file can be fixed
+		if cmd.Parent() != configCmd {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+		logger.Warning("Invalid configuration: %v", err)
+		s = config.DefaultSettings()
+	}
+
+	for _, key := range config.SettingKeys() {
+		flag := cmd.Flags().Lookup(key)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		if err := s.Set(key, flag.Value.String(), "--"+key); err != nil {
+			return err
+		}
+	}
+
+	settings = s
+	return nil
+}
+
+// modulesDir returns the directory packages are installed into
+func modulesDir() string {
+	return settings.Path("modules-dir")
+}
+
+// configFile returns the file config set and delete write to
+func configFile() string {
+	if configProject {
+		return config.ProjectSettingsFile
+	}
+	return config.UserSettingsPath()
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage droy-pm configuration",
+	Long: `Read and write droy-pm configuration.
+
+Settings are layered, each overriding the one before:
+  1. Built-in defaults
+  2. ~/.droy/config.toml
+  3. .droyrc in the project directory
+  4. Environment variables, e.g. DROY_REGISTRY or DROY_MODULES_DIR
+  5. Command-line flags, e.g. --registry or --offline
+
+Packages in a scope such as @acme can be served by their own registry
+by setting the @acme:registry key.
+
+set and delete change ~/.droy/config.toml, or .droyrc with --project.
+
+A .droyrc comes with the project, so it cannot set cache-dir, store-dir,
+trusted-keys or signature-policy, and modules-dir must be a directory
+inside the project.`,
+	Example: `  droy-pm config list
+  droy-pm config get registry
+  droy-pm config set registry https://droy.example.com
+  droy-pm config set modules-dir vendor --project
+  droy-pm config set @acme:registry https://droy.acme.internal
+  droy-pm config delete registry`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		if _, ok := config.LookupSetting(key); !ok {
+			return fmt.Errorf("unknown config key '%s'", key)
+		}
+
+		fmt.Println(settings.Get(key))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a key in the configuration file",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, value := args[0], args[1]
+		if err := config.ValidateSetting(key, value); err != nil {
+			return err
+		}
+		if def, _ := config.LookupSetting(key); configProject && def.UserOnly() {
+			return fmt.Errorf("%s can only be set in %s, not in %s", key, config.UserSettingsPath(), config.ProjectSettingsFile)
+		}
+
+		path := configFile()
+		values, err := config.ReadSettingsFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		values[key] = value
+		if err := config.WriteSettingsFile(path, values); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		logger.Success("Set %s = %s in %s", key, value, path)
+		return nil
+	},
+}
+
+var configDeleteCmd = &cobra.Command{
+	Use:     "delete <key>",
+	Aliases: []string{"rm", "unset"},
+	Short:   "Remove a key from the configuration file",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		path := configFile()
+
+		values, err := config.ReadSettingsFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		if _, ok := values[key]; !ok {
+			logger.Info("%s is not set in %s", key, path)
+			return nil
+		}
+
+		delete(values, key)
+		if err := config.WriteSettingsFile(path, values); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		logger.Success("Removed %s from %s", key, path)
+		return nil
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List every key with its value and origin",
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, key := range settings.Keys() {
+			fmt.Printf("%s %-40s %s\n",
+				color.CyanString("%-16s", key),
+				settings.Get(key),
+				color.HiBlackString("(%s)", settings.Source(key)))
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configDeleteCmd)
+	configCmd.AddCommand(configListCmd)
+
+	for _, c := range []*cobra.Command{configSetCmd, configDeleteCmd} {
+		c.Flags().BoolVarP(&configProject, "project", "p", false, "Write to .droyrc instead of ~/.droy/config.toml")
+	}
+}
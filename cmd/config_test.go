@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestLoadSettingsAppliesFlagsLast(t *testing.T) {
+	useTestProject(t, "name = \"app\"\n", "https://registry.example.com")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("DROY_CONCURRENCY", "2")
+
+	c := &cobra.Command{Use: "test"}
+	c.Flags().IntP("concurrency", "j", 8, "")
+	if err := c.Flags().Set("concurrency", "5"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loadSettings(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := settings.Int("concurrency"); got != 5 {
+		t.Errorf("concurrency = %d, want the flag's 5 over DROY_CONCURRENCY", got)
+	}
+	if got := settings.Source("concurrency"); got != "--concurrency" {
+		t.Errorf("concurrency comes from %q, want --concurrency", got)
+	}
+}
+
+func TestConfigGetUnknownKey(t *testing.T) {
+	if err := configGetCmd.RunE(configGetCmd, []string{"no-such-key"}); err == nil {
+		t.Error("config get of an unknown key succeeded")
+	}
+	if err := configSetCmd.RunE(configSetCmd, []string{"no-such-key", "1"}); err == nil {
+		t.Error("config set of an unknown key succeeded")
+	}
+}
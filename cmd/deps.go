@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/resolver"
 	"github.com/droy-go/droy-pm/internal/logger"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -33,7 +34,7 @@ var depsCmd = &cobra.Command{
 		fmt.Printf("  Development: %d\n\n", devDepCount)
 
 		// Check for missing dependencies
-		if _, err := os.Stat("droy_modules"); os.IsNotExist(err) {
+		if _, err := os.Stat(modulesDir()); os.IsNotExist(err) {
 			logger.Warning("Dependencies not installed. Run 'droy-pm install'")
 			return
 		}
@@ -43,11 +44,11 @@ var depsCmd = &cobra.Command{
 		outdated := []string{}
 
 		for name, requiredVersion := range pkg.Dependencies {
-			pkgPath := filepath.Join("droy_modules", name, "droy.toml")
+			pkgPath := filepath.Join(modulesDir(), name, "droy.toml")
 			if _, err := os.Stat(pkgPath); os.IsNotExist(err) {
 				missing = append(missing, name)
 			} else if installedPkg, err := config.ReadPackageConfig(pkgPath); err == nil {
-				if installedPkg.Version != requiredVersion {
+				if !resolver.Satisfies(installedPkg.Version, requiredVersion) {
 					outdated = append(outdated, fmt.Sprintf("%s (%s -> %s)", 
 						name, installedPkg.Version, requiredVersion))
 				}
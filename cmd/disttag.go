@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/semver"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var distTagRegistry string
+
+var distTagCmd = &cobra.Command{
+	Use:     "dist-tag",
+	Aliases: []string{"dist-tags"},
+	Short:   "Manage package dist-tags",
+	Long: `Dist-tags are names such as latest or next that point at a published
+version. Installing a package without a version installs its latest tag, and
+any tag can be used as a version spec: droy-pm install droy-http@next.
+
+Use them to publish prereleases without moving latest:
+  droy-pm publish --tag next
+  droy-pm dist-tag add droy-http@2.0.0 latest    # once 2.0.0 is stable`,
+}
+
+var distTagAddCmd = &cobra.Command{
+	Use:   "add <package@version> [tag]",
+	Short: "Point a tag at a version (default tag: latest)",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, version, ok := splitPackageVersion(args[0])
+		if !ok || !semver.IsValid(version) {
+			logger.Error("Expected <package@version>, got %s", args[0])
+			return
+		}
+
+		tag := registry.DefaultTag
+		if len(args) == 2 {
+			tag = args[1]
+		}
+
+		reg := targetRegistry(name, distTagRegistry)
+		if err := reg.AddDistTag(name, tag, version); err != nil {
+			logger.Error("Failed to add tag: %v", err)
+			return
+		}
+
+		logger.Success("%s: %s -> %s", name, tag, version)
+	},
+}
+
+var distTagRmCmd = &cobra.Command{
+	Use:     "rm <package> <tag>",
+	Aliases: []string{"remove"},
+	Short:   "Remove a tag",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, tag := args[0], args[1]
+
+		reg := targetRegistry(name, distTagRegistry)
+		if err := reg.RemoveDistTag(name, tag); err != nil {
+			logger.Error("Failed to remove tag: %v", err)
+			return
+		}
+
+		logger.Success("Removed %s from %s", tag, name)
+	},
+}
+
+var distTagLsCmd = &cobra.Command{
+	Use:     "ls [package]",
+	Aliases: []string{"list"},
+	Short:   "List the tags of a package (default: this project)",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+		} else {
+			pkg, err := config.ReadPackageConfig("droy.toml")
+			if err != nil {
+				logger.Error("Give a package name or run this in a project: %v", err)
+				return
+			}
+			name = pkg.Name
+		}
+
+		reg := targetRegistry(name, distTagRegistry)
+		tags, err := reg.DistTags(name)
+		if err != nil {
+			logger.Error("Failed to list tags: %v", err)
+			return
+		}
+
+		names := make([]string, 0, len(tags))
+		for tag := range tags {
+			names = append(names, tag)
+		}
+		sort.Strings(names)
+
+		for _, tag := range names {
+			fmt.Printf("%s %s\n", color.CyanString("%s:", tag), tags[tag])
+		}
+	},
+}
+
+// splitPackageVersion splits "name@version", keeping the "@" that starts
+// a scoped name
+func splitPackageVersion(spec string) (name, version string, ok bool) {
+	idx := strings.LastIndex(spec, "@")
+	if idx <= 0 {
+		return spec, "", false
+	}
+	return spec[:idx], spec[idx+1:], true
+}
+
+func init() {
+	distTagCmd.PersistentFlags().StringVarP(&distTagRegistry, "registry", "r", "", "Registry URL (default from publishConfig or config)")
+
+	distTagCmd.AddCommand(distTagAddCmd)
+	distTagCmd.AddCommand(distTagRmCmd)
+	distTagCmd.AddCommand(distTagLsCmd)
+}
@@ -125,12 +125,12 @@ func findDroyFiles(root string) ([]string, error) {
 	var files []string
 
 	excludedDirs := map[string]bool{
-		"droy_modules": true,
 		"node_modules": true,
 		".git":         true,
 		"dist":         true,
 		"build":        true,
 	}
+	excludedDirs[filepath.Base(modulesDir())] = true
 
 	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -7,7 +7,6 @@ import (
 	"path/filepath"
 
 	"github.com/droy-go/droy-pm/pkg/config"
-	"github.com/droy-go/droy-pm/pkg/registry"
 	"github.com/droy-go/droy-pm/internal/logger"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -93,20 +92,18 @@ func showProjectInfo() {
 	}
 
 	// Check installed packages
-	if _, err := os.Stat("droy_modules"); !os.IsNotExist(err) {
-		entries, _ := os.ReadDir("droy_modules")
-		if len(entries) > 0 {
+	if _, err := os.Stat(modulesDir()); !os.IsNotExist(err) {
+		dirs := installedDirs(modulesDir())
+		if len(dirs) > 0 {
 			fmt.Println()
-			color.Magenta("Installed Packages (%d):", len(entries))
-			for _, entry := range entries {
-				if entry.IsDir() {
-					// Try to get version
-					pkgPath := filepath.Join("droy_modules", entry.Name(), "droy.toml")
-					if subPkg, err := config.ReadPackageConfig(pkgPath); err == nil {
-						fmt.Printf("  %s@%s\n", color.CyanString(subPkg.Name), color.WhiteString(subPkg.Version))
-					} else {
-						fmt.Printf("  %s\n", color.CyanString(entry.Name()))
-					}
+			color.Magenta("Installed Packages (%d):", len(dirs))
+			for _, dir := range dirs {
+				// Try to get version
+				pkgPath := filepath.Join(modulesDir(), dir, "droy.toml")
+				if subPkg, err := config.ReadPackageConfig(pkgPath); err == nil {
+					fmt.Printf("  %s@%s\n", color.CyanString(subPkg.Name), color.WhiteString(subPkg.Version))
+				} else {
+					fmt.Printf("  %s\n", color.CyanString(dir))
 				}
 			}
 		}
@@ -149,7 +146,7 @@ func showPackageInfo(name string) {
 	}
 
 	// Get from registry
-	reg := registry.New("")
+	reg := getRegistry("")
 	info, err := reg.GetPackage(name)
 	if err != nil {
 		logger.Error("Failed to get package info: %v", err)
@@ -133,17 +133,17 @@ droy.lock
 
 ## Installation
 
-\`\`\`bash
+` + "```" + `bash
 droy-pm install %s
-\`\`\`
+` + "```" + `
 
 ## Usage
 
-\`\`\`droy
+` + "```" + `droy
 pkg "%s"
 
 // Your code here
-\`\`\`
+` + "```" + `
 
 ## License
 
@@ -2,15 +2,16 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/droy-go/droy-pm/pkg/config"
 	"github.com/droy-go/droy-pm/pkg/installer"
+	"github.com/droy-go/droy-pm/pkg/registry"
 	"github.com/droy-go/droy-pm/pkg/resolver"
+	"github.com/droy-go/droy-pm/pkg/semver"
+	"github.com/droy-go/droy-pm/pkg/workspace"
 	"github.com/droy-go/droy-pm/internal/logger"
-	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +19,7 @@ var (
 	installGlobal bool
 	installDev    bool
 	installSave   bool
+	installFrozen bool
 )
 
 var installCmd = &cobra.Command{
@@ -27,6 +29,12 @@ var installCmd = &cobra.Command{
 	Long: `Install packages from the Droy registry or GitHub.
 If no package is specified, installs all dependencies from droy.toml.
 
+In a workspace, installs run at the workspace root: every member's
+dependencies go into one droy_modules and one droy.lock there, and members
+are linked rather than downloaded. A package is added to the member
+containing the current directory, to the members selected with --workspace
+or --filter, or else to the root droy.toml.
+
 Common Droy packages:
   http      - HTTP client library (droy-http)
   json      - JSON parsing (droy-json)
@@ -35,123 +43,363 @@ Common Droy packages:
   fs        - File system utilities (droy-fs)
   net       - Network utilities (droy-net)`,
 	Example: `  droy-pm install                    # Install all dependencies
+  droy-pm install --frozen-lockfile  # Install exactly what droy.lock records
   droy-pm install http               # Install droy-http package
   droy-pm install json@2.0.0         # Install specific version
+  droy-pm install json@next          # Install the version tagged next
   droy-pm install mypackage          # Install from registry
-  droy-pm install github.com/user/repo # Install from GitHub`,
-	Run: func(cmd *cobra.Command, args []string) {
+  droy-pm install github.com/user/repo # Install from GitHub
+  droy-pm install --filter api       # Install only what the api member needs
+  droy-pm install json --workspace   # Add json to every workspace member`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
 			// Install all dependencies from droy.toml
-			installAllDependencies()
-		} else {
-			// Install specific package
-			installPackage(args[0])
+			return installAllDependencies()
 		}
+
+		// Install specific package
+		return installPackage(args[0])
 	},
 }
 
-func installAllDependencies() {
+func installAllDependencies() error {
+	ws, err := enterWorkspace()
+	if err != nil {
+		return err
+	}
+	return inTransaction(nil, func(inst *installer.Installer) error {
+		return installDependencies(inst, ws)
+	})
+}
+
+// installDependencies installs the dependencies of the project in the
+// current directory or, given a workspace, of the whole workspace
+func installDependencies(inst *installer.Installer, ws *workspace.Workspace) error {
+	if ws != nil {
+		return installWorkspace(inst, ws)
+	}
+
 	logger.Info("Reading package configuration...")
 
 	pkg, err := config.ReadPackageConfig("droy.toml")
 	if err != nil {
-		logger.Error("Failed to read droy.toml: %v", err)
 		logger.Info("Run 'droy-pm init' to create a new package")
-		return
+		return fmt.Errorf("failed to read droy.toml: %w", err)
 	}
 
 	if len(pkg.Dependencies) == 0 && len(pkg.DevDependencies) == 0 {
 		logger.Warning("No dependencies found in droy.toml")
-		return
+		return nil
 	}
 
 	logger.Info("Installing dependencies for '%s'...", pkg.Name)
 
-	// Resolve dependencies
-	res := resolver.New()
-	allDeps := make(map[string]string)
+	// The lock file always covers dev dependencies so that it does not
+	// depend on how install was invoked
+	allDeps := allDependencies(pkg)
+
+	lock, err := lockForInstall(pkg, allDeps, nil)
+	if err != nil {
+		return err
+	}
 
+	wanted := make(map[string]string)
 	for name, version := range pkg.Dependencies {
-		allDeps[name] = version
+		wanted[name] = version
 	}
 
 	if installDev {
 		for name, version := range pkg.DevDependencies {
-			allDeps[name] = version
+			wanted[name] = version
 		}
 	}
 
-	resolved, err := res.Resolve(allDeps)
+	return installLocked(inst, lock, resolver.LockedVersions(lock, wanted), nil, true)
+}
+
+// inTransaction runs fn with an installer whose transaction covers
+// droy_modules, droy.toml, droy.lock and files. The transaction is
+// committed when fn succeeds and rolled back otherwise, which also undoes
+// any edits fn made to those files
+func inTransaction(files []string, fn func(inst *installer.Installer) error) error {
+	inst := newInstaller()
+
+	tx, err := inst.Begin(append([]string{"droy.toml", "droy.lock"}, files...)...)
 	if err != nil {
-		logger.Error("Failed to resolve dependencies: %v", err)
-		return
+		return err
 	}
 
-	// Install each dependency
-	inst := installer.New("droy_modules")
-	total := len(resolved)
+	if err := fn(inst); err != nil {
+		if err := tx.Rollback(); err != nil {
+			logger.Warning("Rollback was incomplete: %v", err)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Warning("Failed to clean up backups: %v", err)
+	}
+	return nil
+}
+
+// installLocked installs packages at their locked versions and, with save,
+// records them in droy.lock; --frozen-lockfile never rewrites it. Packages
+// in links are linked from their local directory instead of installed.
+// inst must be in a transaction, which the caller rolls back when an
+// error is returned
+func installLocked(inst *installer.Installer, lock *config.LockFile, resolved map[string]string, links map[string]string, save bool) error {
 	installed := 0
 
-	for name, version := range resolved {
-		logger.Progress(installed+1, total, "Installing %s@%s", name, version)
-		
-		if err := inst.Install(name, version); err != nil {
-			logger.Error("Failed to install %s@%s: %v", name, version, err)
+	var names, linked []string
+	for name := range resolved {
+		if _, ok := links[name]; ok {
+			linked = append(linked, name)
+		} else {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(linked)
+	total := len(names)
+
+	jobs := make([]installer.Job, 0, len(names))
+	for _, name := range names {
+		jobs = append(jobs, installer.Job{Name: name, Locked: lock.Packages[name]})
+	}
+
+	// Clone progress would garble the live progress block
+	inst.Output = nil
+	progress := logger.NewMultiProgress(total)
+	results := inst.InstallAll(jobs, settings.Int("concurrency"), func(e installer.Event) {
+		task := e.Name + "@" + e.Version
+		if e.Done {
+			progress.Finish(task, e.Err)
+		} else {
+			progress.Update(task, e.Status)
+		}
+	})
+	progress.Stop()
+	reportWarnings(inst)
+
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Name)
 			continue
 		}
+		result.Package.Dependencies = lock.Packages[result.Name].Dependencies
+		lock.Packages[result.Name] = result.Package
 		installed++
 	}
 
-	// Create lock file
-	lock := &config.LockFile{
-		Version:      pkg.Version,
-		Dependencies: resolved,
+	for _, name := range linked {
+		if err := inst.Link(name, links[name]); err != nil {
+			logger.Error("%v", err)
+			failed = append(failed, name)
+		}
 	}
-	
-	if err := config.WriteLockFile(lock, "droy.lock"); err != nil {
-		logger.Warning("Failed to create lock file: %v", err)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to install %s; the project was rolled back", strings.Join(failed, ", "))
+	}
+
+	if !enforceLicenses(inst, names) {
+		return fmt.Errorf("packages violate the license policy; the project was rolled back")
+	}
+
+	if save && !installFrozen {
+		if err := config.WriteLockFile(lock, "droy.lock"); err != nil {
+			logger.Warning("Failed to create lock file: %v", err)
+		}
+	}
+
+	if len(linked) > 0 {
+		logger.Success("Installed %d/%d packages and linked %d workspace members", installed, total, len(linked))
+	} else {
+		logger.Success("Installed %d/%d packages", installed, total)
+	}
+	return nil
+}
+
+// allDependencies returns the dependencies and dev dependencies of a
+// package, the set droy.lock covers
+func allDependencies(pkg *config.Package) map[string]string {
+	deps := make(map[string]string)
+	for name, version := range pkg.Dependencies {
+		deps[name] = version
+	}
+	for name, version := range pkg.DevDependencies {
+		deps[name] = version
+	}
+	return deps
+}
+
+// lockForInstall returns droy.lock when it still satisfies deps, and a
+// freshly resolved lock otherwise. local holds workspace members, which
+// resolve to their own version. With --frozen-lockfile a missing or
+// outdated droy.lock is an error instead
+func lockForInstall(pkg *config.Package, deps map[string]string, local map[string]*config.Package) (*config.LockFile, error) {
+	lock, err := config.ReadLockFile("droy.lock")
+	if err == nil {
+		checkErr := resolver.CheckLock(lock, deps)
+		if checkErr == nil {
+			checkErr = resolver.CheckLocal(lock, local)
+		}
+		if checkErr == nil {
+			logger.Info("Using versions from droy.lock")
+			return lock, nil
+		}
+		if installFrozen {
+			return nil, fmt.Errorf("droy.lock is out of date: %v\nRun 'droy-pm install' to update it", checkErr)
+		}
+		logger.Info("droy.lock is out of date (%v), resolving dependencies...", checkErr)
+	} else {
+		if installFrozen {
+			return nil, fmt.Errorf("--frozen-lockfile requires an existing droy.lock: %v", err)
+		}
+		lock = nil
+	}
+
+	// Only what changed in droy.toml moves; everything else stays locked
+	var prefer map[string]string
+	if lock != nil {
+		prefer = resolver.LockedVersions(lock, deps)
+	}
+	return resolveLock(pkg, deps, local, lock, prefer)
+}
+
+// resolveLock resolves deps into a new lock file, trying the versions in
+// prefer first. Resolved URLs and integrity hashes are kept from previous
+// for packages whose version did not change
+func resolveLock(pkg *config.Package, deps map[string]string, local map[string]*config.Package, previous *config.LockFile, prefer map[string]string) (*config.LockFile, error) {
+	res := newResolver()
+	res.Root = pkg.Name
+	res.Local = local
+	res.Prefer = prefer
+
+	if _, err := res.Resolve(deps); err != nil {
+		return nil, fmt.Errorf("failed to resolve dependencies: %w", err)
+	}
+
+	return res.BuildLock(pkg, deps, previous), nil
+}
+
+// readLock returns droy.lock, or nil if there is none or it cannot be read
+func readLock() *config.LockFile {
+	lock, err := config.ReadLockFile("droy.lock")
+	if err != nil {
+		return nil
 	}
+	return lock
+}
 
-	logger.Success("Installed %d/%d packages", installed, total)
+// savedSpec is what droy.toml records for a requested version. An exact
+// version is saved as a caret range, and a range as it was given. GitHub
+// packages are pinned by ref, so their ref is recorded as it is
+func savedSpec(name, version string) string {
+	if strings.HasPrefix(name, "github.com/") || !semver.IsValid(version) {
+		return version
+	}
+	return "^" + version
 }
 
-func installPackage(pkgSpec string) {
+func installPackage(pkgSpec string) error {
+	ws, err := findWorkspace()
+	if err != nil {
+		return err
+	}
+	if ws != nil {
+		return installWorkspacePackage(ws, pkgSpec)
+	}
+
 	// Parse package specification
 	name, version := parsePackageSpec(pkgSpec)
-	
+
+	// Dist-tags such as latest or next name a version only the registry
+	// knows; droy.toml and droy.lock record the version itself
+	if !strings.HasPrefix(name, "github.com/") && registry.IsTag(version) {
+		tagged, err := newResolver().Version(name, version)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s@%s: %w", name, version, err)
+		}
+		version = tagged
+	}
+
+	pkg, err := config.ReadPackageConfig("droy.toml")
+	if err != nil {
+		logger.Info("Run 'droy-pm init' to create a new package")
+		return fmt.Errorf("failed to read droy.toml: %w", err)
+	}
+	if name == pkg.Name {
+		return fmt.Errorf("%s cannot depend on itself", name)
+	}
+
 	logger.Info("Installing %s@%s...", name, version)
 
-	// Install the package
-	inst := installer.New("droy_modules")
-	if err := inst.Install(name, version); err != nil {
-		logger.Error("Failed to install %s: %v", name, err)
-		return
+	// The package is resolved along with the rest of the project, keeping
+	// everything else at its locked version, so that droy.lock covers it
+	// and its dependencies
+	previous := readLock()
+	prefer := make(map[string]string)
+	if previous != nil {
+		prefer = resolver.LockedVersions(previous, allDependencies(pkg))
 	}
+	prefer[name] = version
 
-	// Update droy.toml if --save flag is set
 	if installSave {
-		pkg, err := config.ReadPackageConfig("droy.toml")
-		if err != nil {
-			logger.Warning("Could not read droy.toml, skipping save")
+		delete(pkg.Dependencies, name)
+		delete(pkg.DevDependencies, name)
+		if installDev {
+			pkg.DevDependencies[name] = savedSpec(name, version)
 		} else {
-			if installDev {
-				if pkg.DevDependencies == nil {
-					pkg.DevDependencies = make(map[string]string)
-				}
-				pkg.DevDependencies[name] = "^" + version
-			} else {
-				pkg.Dependencies[name] = "^" + version
-			}
-			
+			pkg.Dependencies[name] = savedSpec(name, version)
+		}
+	}
+
+	// Without --save nothing is recorded: droy.toml and droy.lock stay as
+	// they are, and the next install or ci will not keep the package
+	deps := allDependencies(pkg)
+	if !installSave {
+		deps[name] = version
+	}
+
+	lock, err := resolveLock(pkg, deps, nil, previous, prefer)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]string)
+	for dep, spec := range pkg.Dependencies {
+		wanted[dep] = spec
+	}
+	if installDev {
+		for dep, spec := range pkg.DevDependencies {
+			wanted[dep] = spec
+		}
+	}
+	wanted[name] = version
+
+	// droy.toml is written inside the transaction so that a failed
+	// install puts it back too
+	err = inTransaction(nil, func(inst *installer.Installer) error {
+		if installSave {
 			if err := config.WritePackageConfig(pkg, "droy.toml"); err != nil {
-				logger.Warning("Failed to update droy.toml: %v", err)
-			} else {
-				logger.Info("Added to droy.toml")
+				return fmt.Errorf("failed to update droy.toml: %w", err)
 			}
 		}
+		return installLocked(inst, lock, resolver.LockedVersions(lock, wanted), nil, installSave)
+	})
+	if err != nil {
+		return err
 	}
 
-	logger.Success("Installed %s@%s", name, version)
+	if installSave {
+		logger.Info("Added to droy.toml")
+	} else {
+		logger.Warning("%s was not saved to droy.toml or droy.lock", name)
+	}
+	logger.Success("Installed %s@%s", name, lock.Packages[name].Version)
+	return nil
 }
 
 // Common package aliases for Droy
@@ -237,4 +485,7 @@ func init() {
 	installCmd.Flags().BoolVarP(&installGlobal, "global", "g", false, "Install package globally")
 	installCmd.Flags().BoolVarP(&installDev, "dev", "D", false, "Install as dev dependency")
 	installCmd.Flags().BoolVarP(&installSave, "save", "S", true, "Save to droy.toml")
+	installCmd.Flags().BoolVar(&installFrozen, "frozen-lockfile", false, "Fail instead of updating an outdated droy.lock")
+	installCmd.Flags().IntP("concurrency", "j", installer.DefaultConcurrency, "Number of packages to install in parallel")
+	addWorkspaceFlags(installCmd)
 }
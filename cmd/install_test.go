@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/registry"
+)
+
+func TestSavedSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+	}{
+		{"json", "1.2.0", "^1.2.0"},
+		{"json", "2.0.0-beta.1", "^2.0.0-beta.1"},
+		{"json", "^1.2", "^1.2"},
+		{"json", "~1.2.0", "~1.2.0"},
+		{"json", ">=1.0.0 <2.0.0", ">=1.0.0 <2.0.0"},
+		{"json", "1.2", "1.2"},
+		{"json", "*", "*"},
+		{"github.com/droy-go/json", "v1.2.0", "v1.2.0"},
+	}
+
+	for _, tt := range tests {
+		if got := savedSpec(tt.name, tt.version); got != tt.want {
+			t.Errorf("savedSpec(%q, %q) = %q, want %q", tt.name, tt.version, got, tt.want)
+		}
+	}
+}
+
+// useTestProject runs the rest of a test in a new project directory with
+// settings pointing at a temporary cache and store and at registryURL
+func useTestProject(t *testing.T, manifest, registryURL string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "droy.toml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	saved := settings
+	t.Cleanup(func() { settings = saved })
+	settings = config.DefaultSettings()
+	for key, value := range map[string]string{
+		"registry":  registryURL,
+		"cache-dir": t.TempDir(),
+		"store-dir": t.TempDir(),
+	} {
+		if err := settings.Set(key, value, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestInstallPackageRollsBackDroyToml(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lib/versions":
+			json.NewEncoder(w).Encode(registry.VersionList{Versions: []string{"1.0.0"}})
+		case "/lib/1.0.0":
+			json.NewEncoder(w).Encode(registry.PackageInfo{
+				Name:    "lib",
+				Version: "1.0.0",
+				Dist:    &registry.DistInfo{Tarball: srv.URL + "/lib/-/lib-1.0.0.tgz", Integrity: "sha256-AAAA"},
+			})
+		default:
+			// The tarball download fails
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	manifest := "name = \"app\"\nversion = \"1.0.0\"\n"
+	dir := useTestProject(t, manifest, srv.URL)
+
+	if err := installPackage("lib@1.0.0"); err == nil {
+		t.Fatal("installPackage succeeded without a tarball")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "droy.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != manifest {
+		t.Errorf("droy.toml after a failed install:\n%s\nwant it unchanged", data)
+	}
+	for _, name := range []string{"droy.lock", "droy_modules"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s exists after a failed install: %v", name, err)
+		}
+	}
+}
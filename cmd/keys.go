@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/signing"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// defaultKeyName is the key generate and export use when given no name
+const defaultKeyName = "default"
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage package signing keys",
+	Long: `Packages can be signed on publish with an ed25519 key and verified on
+install. Keys live in the keys-dir config key, ~/.droy/keys by default.
+
+To sign, generate a key and set it as the signing key:
+  droy-pm keys generate release
+  droy-pm config set signing-key release
+
+To verify, add the publisher's public key to trusted-keys and choose a
+signature-policy: off (the default), warn or require:
+  droy-pm config set trusted-keys "$(droy-pm keys export release)"
+  droy-pm config set signature-policy require`,
+}
+
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate [name]",
+	Short: "Generate a signing key pair (default name: default)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := defaultKeyName
+		if len(args) == 1 {
+			name = args[0]
+		}
+
+		dir := settings.Path("keys-dir")
+		key, err := signing.Generate(dir, name)
+		if err != nil {
+			logger.Error("Failed to generate key: %v", err)
+			return
+		}
+
+		logger.Success("Generated key %s (%s) in %s", name, key.Public().ID(), dir)
+		if settings.Get("signing-key") == "" {
+			logger.Info("Run 'droy-pm config set signing-key %s' to sign packages with it", name)
+		}
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List signing keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := signing.List(settings.Path("keys-dir"))
+		if err != nil {
+			logger.Error("Failed to list keys: %v", err)
+			return
+		}
+		if len(keys) == 0 {
+			logger.Info("No keys; run 'droy-pm keys generate' to create one")
+			return
+		}
+
+		trusted := make(map[string]bool)
+		if verifier, err := signing.NewVerifier(signing.PolicyOff, settings.List("trusted-keys")); err == nil {
+			for _, key := range verifier.Trusted {
+				trusted[key.ID()] = true
+			}
+		}
+
+		for _, key := range keys {
+			var marks string
+			if key.Name == settings.Get("signing-key") {
+				marks += color.GreenString(" (signing)")
+			}
+			if trusted[key.ID()] {
+				marks += color.GreenString(" (trusted)")
+			}
+			fmt.Printf("%s %s%s\n", color.CyanString("%-16s", key.Name), key.ID(), marks)
+		}
+	},
+}
+
+var keysExportCmd = &cobra.Command{
+	Use:   "export [name]",
+	Short: "Print a public key for others to trust (default name: default)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := defaultKeyName
+		if len(args) == 1 {
+			name = args[0]
+		}
+
+		key, err := signing.Load(settings.Path("keys-dir"), name)
+		if err != nil {
+			logger.Error("%v", err)
+			return
+		}
+
+		fmt.Println(key.Public().String())
+	},
+}
+
+func init() {
+	keysCmd.AddCommand(keysGenerateCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysExportCmd)
+}
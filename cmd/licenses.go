@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/installer"
+	"github.com/droy-go/droy-pm/pkg/licenses"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var licensesFormat string
+
+// errLicensePolicy fails an install whose package violates the license
+// policy
+var errLicensePolicy = errors.New("its license violates the license policy")
+
+var licensesCmd = &cobra.Command{
+	Use:   "licenses",
+	Short: "List the licenses of installed packages",
+	Long: `List the SPDX license of every package in droy_modules, with whether it
+complies with the license policy.
+
+The policy comes from the license-allow and license-deny settings: lists of
+SPDX identifiers, where a trailing * matches a family such as GPL-*. With
+license-allow set, only those licenses are accepted; license-deny rejects
+licenses either way. With either set, packages without a valid SPDX
+license fail, since they cannot be checked. Expressions
+are evaluated, so "MIT OR GPL-3.0-only" passes when MIT is allowed, while
+"MIT AND GPL-3.0-only" fails when GPL-3.0-only is denied.
+
+install fails and rolls back when a package violates the policy.`,
+	Example: `  droy-pm licenses
+  droy-pm licenses --format csv > licenses.csv
+  droy-pm config set license-deny "GPL-*,AGPL-*" --project
+  droy-pm licenses check`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pkgs := installedLicenses()
+		policy := licensePolicy()
+		policy.Apply(pkgs)
+
+		if err := writeLicenses(pkgs, licensesFormat, policy.Enabled()); err != nil {
+			logger.Error("%v", err)
+		}
+	},
+}
+
+var licensesCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Fail if an installed package violates the license policy",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		policy := licensePolicy()
+		if !policy.Enabled() {
+			logger.Warning("No license policy; set license-allow or license-deny")
+			return
+		}
+
+		pkgs := installedLicenses()
+		violations := policy.Apply(pkgs)
+		for _, pkg := range violations {
+			logger.Error("%s@%s: %s", pkg.Name, pkg.Version, pkg.Problem)
+		}
+		if len(violations) > 0 {
+			logger.Error("%d of %d packages violate the license policy", len(violations), len(pkgs))
+			os.Exit(1)
+		}
+
+		logger.Success("All %d packages comply with the license policy", len(pkgs))
+	},
+}
+
+// licensePolicy returns the configured license policy
+func licensePolicy() *licenses.Policy {
+	return &licenses.Policy{
+		Allow: settings.List("license-allow"),
+		Deny:  settings.List("license-deny"),
+	}
+}
+
+// installedLicenses returns the packages in droy_modules with the license
+// their droy.toml declares
+func installedLicenses() []licenses.Package {
+	var pkgs []licenses.Package
+	for _, dir := range installedDirs(modulesDir()) {
+		pkgs = append(pkgs, readLicense(dir, filepath.Join(modulesDir(), dir)))
+	}
+	return pkgs
+}
+
+// readLicense reads the name, version and license of the package in dir
+func readLicense(name, dir string) licenses.Package {
+	pkg, err := config.ReadPackageConfig(filepath.Join(dir, "droy.toml"))
+	if err != nil {
+		return licenses.Package{Name: name}
+	}
+	if pkg.Name != "" {
+		name = pkg.Name
+	}
+	return licenses.Package{Name: name, Version: pkg.Version, License: pkg.License}
+}
+
+// enforceLicenses checks newly installed packages against the license
+// policy, reporting the violations, and returns whether all comply
+func enforceLicenses(inst *installer.Installer, names []string) bool {
+	policy := licensePolicy()
+	if !policy.Enabled() {
+		return true
+	}
+
+	pkgs := make([]licenses.Package, 0, len(names))
+	for _, name := range names {
+		pkgs = append(pkgs, readLicense(name, inst.PackageDir(name)))
+	}
+
+	violations := policy.Apply(pkgs)
+	for _, pkg := range violations {
+		logger.Error("%s@%s: %s", pkg.Name, pkg.Version, pkg.Problem)
+	}
+	return len(violations) == 0
+}
+
+// writeLicenses prints packages and their licenses as a table, CSV or
+// JSON. The status column is only shown when a policy is set
+func writeLicenses(pkgs []licenses.Package, format string, withStatus bool) error {
+	switch format {
+	case "json":
+		if pkgs == nil {
+			pkgs = []licenses.Package{}
+		}
+		data, err := json.MarshalIndent(pkgs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode licenses: %w", err)
+		}
+		fmt.Println(string(data))
+
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		header := []string{"name", "version", "license"}
+		if withStatus {
+			header = append(header, "problem")
+		}
+		w.Write(header)
+		for _, pkg := range pkgs {
+			row := []string{pkg.Name, pkg.Version, pkg.License}
+			if withStatus {
+				row = append(row, pkg.Problem)
+			}
+			w.Write(row)
+		}
+		w.Flush()
+		return w.Error()
+
+	case "table":
+		if len(pkgs) == 0 {
+			logger.Info("No packages installed")
+			return nil
+		}
+		fmt.Printf("%-28s %-12s %s\n", "Package", "Version", "License")
+		for _, pkg := range pkgs {
+			license := pkg.License
+			if license == "" {
+				license = color.YellowString("%-24s", "(none)")
+			} else {
+				license = fmt.Sprintf("%-24s", license)
+			}
+			fmt.Printf("%s %-12s %s", color.CyanString("%-28s", pkg.Name), pkg.Version, license)
+			if withStatus {
+				if pkg.Problem != "" {
+					fmt.Printf(" %s", color.RedString("✗ %s", pkg.Problem))
+				} else {
+					fmt.Printf(" %s", color.GreenString("✓"))
+				}
+			}
+			fmt.Println()
+		}
+
+	default:
+		return fmt.Errorf("unknown format %q, expected table, csv or json", format)
+	}
+	return nil
+}
+
+func init() {
+	licensesCmd.Flags().StringVarP(&licensesFormat, "format", "f", "table", "Output format: table, csv or json")
+
+	licensesCmd.AddCommand(licensesCheckCmd)
+}
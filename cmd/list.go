@@ -8,7 +8,9 @@ import (
 	"strings"
 
 	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/resolver"
 	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/internal/utils"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -42,18 +44,17 @@ var listCmd = &cobra.Command{
 			return
 		}
 
+		if listOutdated {
+			printOutdatedPackages(pkg)
+			return
+		}
+
 		printPackageList(pkg)
 	},
 }
 
 func listGlobalPackages() {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		logger.Error("Failed to get home directory: %v", err)
-		return
-	}
-
-	globalPath := filepath.Join(homeDir, ".droy", "global")
+	globalPath := settings.Path("global-dir")
 	if _, err := os.Stat(globalPath); os.IsNotExist(err) {
 		logger.Info("No global packages installed")
 		return
@@ -92,7 +93,7 @@ func printPackageList(pkg *config.Package) {
 	}
 
 	// Check installed packages
-	if _, err := os.Stat("droy_modules"); !os.IsNotExist(err) {
+	if _, err := os.Stat(modulesDir()); !os.IsNotExist(err) {
 		color.Blue("\nInstalled Packages:\n")
 		printInstalledPackages()
 	}
@@ -112,69 +113,168 @@ func printDeps(deps map[string]string) {
 	}
 }
 
-func printInstalledPackages() {
-	entries, err := os.ReadDir("droy_modules")
+// installedDirs returns the package directories in a modules directory,
+// relative to it. Scoped packages are returned as "@scope/name"
+func installedDirs(modules string) []string {
+	entries, err := os.ReadDir(modules)
 	if err != nil {
-		return
+		return nil
 	}
 
+	var dirs []string
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		name := entry.Name()
+
+		// Packages linked from the store may be symlinks
+		if strings.HasPrefix(name, ".") || !utils.DirExists(filepath.Join(modules, name)) {
+			continue
+		}
+
+		if strings.HasPrefix(name, "@") {
+			for _, sub := range installedDirs(filepath.Join(modules, name)) {
+				if !strings.HasPrefix(sub, "@") {
+					dirs = append(dirs, name+"/"+sub)
+				}
+			}
 			continue
 		}
 
+		dirs = append(dirs, name)
+	}
+
+	return dirs
+}
+
+func printInstalledPackages() {
+	for _, dir := range installedDirs(modulesDir()) {
 		// Try to read package info
-		pkgPath := filepath.Join("droy_modules", entry.Name(), "droy.toml")
+		pkgPath := filepath.Join(modulesDir(), dir, "droy.toml")
 		if pkg, err := config.ReadPackageConfig(pkgPath); err == nil {
 			fmt.Printf("  %s %s\n", 
 				color.CyanString(pkg.Name), 
 				color.WhiteString(pkg.Version))
 		} else {
-			fmt.Printf("  %s\n", color.CyanString(entry.Name()))
+			fmt.Printf("  %s\n", color.CyanString(dir))
 		}
 	}
 }
 
-func printDependencyTree(pkg *config.Package) {
-	color.Cyan("📦 %s@%s\n\n", pkg.Name, pkg.Version)
-	
-	if len(pkg.Dependencies) > 0 {
-		color.Green("Dependency Tree:\n")
-		printTree("droy_modules", "", true)
+func printOutdatedPackages(pkg *config.Package) {
+	deps := make(map[string]string)
+	for name, spec := range pkg.Dependencies {
+		deps[name] = spec
+	}
+	for name, spec := range pkg.DevDependencies {
+		deps[name] = spec
+	}
+
+	var names []string
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reg := getRegistry("")
+	var rows [][4]string
+
+	for _, name := range names {
+		info, err := reg.GetPackage(name)
+		if err != nil {
+			logger.Warning("Could not check %s: %v", name, err)
+			continue
+		}
+
+		wanted, err := resolver.SelectVersion(info, deps[name])
+		if err != nil {
+			wanted = "-"
+		}
+		latest, err := resolver.SelectVersion(info, "latest")
+		if err != nil {
+			latest = "-"
+		}
+
+		current := installedVersion(name)
+		if current == wanted && current == latest {
+			continue
+		}
+
+		rows = append(rows, [4]string{name, displayVersion(current), wanted, latest})
+	}
+
+	if len(rows) == 0 {
+		color.Green("✓ All dependencies are up to date!")
+		return
+	}
+
+	color.Cyan("📦 %s@%s\n", pkg.Name, pkg.Version)
+	fmt.Printf("  %-28s %-14s %-14s %-14s\n", "Package", "Current", "Wanted", "Latest")
+	for _, row := range rows {
+		fmt.Printf("  %s %s %s %s\n",
+			color.CyanString("%-28s", row[0]),
+			color.RedString("%-14s", row[1]),
+			color.GreenString("%-14s", row[2]),
+			color.MagentaString("%-14s", row[3]))
 	}
 }
 
-func printTree(path, prefix string, isLast bool) {
-	entries, err := os.ReadDir(path)
+// installedVersion returns the version of a package in droy_modules,
+// or "" if it is not installed
+func installedVersion(name string) string {
+	pkg, err := config.ReadPackageConfig(filepath.Join(modulesDir(), name, "droy.toml"))
 	if err != nil {
+		return ""
+	}
+	return pkg.Version
+}
+
+func printDependencyTree(pkg *config.Package) {
+	color.Cyan("📦 %s@%s\n\n", pkg.Name, pkg.Version)
+
+	deps := allDependencies(pkg)
+	if len(deps) == 0 {
+		logger.Info("No dependencies")
 		return
 	}
 
-	var dirs []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			dirs = append(dirs, entry.Name())
+	// droy.lock records what install resolved; without an up to date lock
+	// the tree is resolved from the registry instead
+	var tree *resolver.DependencyTree
+	if lock := readLock(); lock != nil && resolver.CheckLock(lock, deps) == nil {
+		tree = resolver.LockTree(lock, deps)
+	} else {
+		res := newResolver()
+		res.Root = pkg.Name
+
+		var err error
+		tree, err = res.ResolveTree(deps)
+		if err != nil {
+			logger.Error("Failed to resolve dependencies: %v", err)
+			return
 		}
+		logger.Warning("droy.lock is missing or out of date; showing the tree 'droy-pm install' would lock")
 	}
 
-	for i, dir := range dirs {
-		isLastItem := i == len(dirs)-1
-		
-		connector := "├── "
-		if isLastItem {
-			connector = "└── "
-		}
+	color.Green("Dependency Tree:\n")
+	printTree(tree.Root, "")
+}
 
-		fmt.Printf("%s%s%s\n", prefix, connector, color.CyanString(dir))
+func printTree(node *resolver.DependencyNode, prefix string) {
+	names := make([]string, 0, len(node.Children))
+	for name := range node.Children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		subPath := filepath.Join(path, dir, "droy_modules")
-		if _, err := os.Stat(subPath); !os.IsNotExist(err) {
-			subPrefix := prefix + "│   "
-			if isLastItem {
-				subPrefix = prefix + "    "
-			}
-			printTree(subPath, subPrefix, isLastItem)
+	for i, name := range names {
+		child := node.Children[name]
+
+		connector, indent := "├── ", "│   "
+		if i == len(names)-1 {
+			connector, indent = "└── ", "    "
 		}
+
+		fmt.Printf("%s%s%s %s\n", prefix, connector, color.CyanString(child.Name), color.WhiteString(child.Version))
+		printTree(child, prefix+indent)
 	}
 }
 
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	authRegistry string
+	loginToken   string
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in to a registry",
+	Long: `Store an API token for a registry in ~/.droy/credentials.
+
+The token is checked against the registry before it is saved and is sent
+only to that registry's host. The DROY_TOKEN environment variable takes
+precedence over the stored token of the configured registry, and is never
+sent to other registries.`,
+	Example: `  droy-pm login                                # Prompt for a token
+  droy-pm login --token $TOKEN                 # Non-interactive
+  droy-pm login --registry https://droy.example.com`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reg := getRegistry(authRegistry)
+
+		token := strings.TrimSpace(loginToken)
+		if token == "" {
+			fmt.Printf("API token for %s: ", reg.URL)
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				logger.Error("Failed to read token: %v", err)
+				return
+			}
+			token = strings.TrimSpace(line)
+		}
+		if token == "" {
+			logger.Error("No token given")
+			return
+		}
+
+		reg.Token = token
+		username, err := reg.Whoami()
+		if err != nil {
+			logger.Error("Login failed: %v", err)
+			return
+		}
+
+		path := config.CredentialsPath()
+		creds, err := config.ReadCredentials(path)
+		if err != nil {
+			logger.Error("Failed to read credentials: %v", err)
+			return
+		}
+
+		creds.Set(reg.URL, &config.Credential{Token: token, Username: username})
+		if err := config.WriteCredentials(creds, path); err != nil {
+			logger.Error("Failed to save credentials: %v", err)
+			return
+		}
+
+		logger.Success("Logged in to %s as %s", reg.URL, color.CyanString(username))
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Log out of a registry",
+	Long:  `Remove the stored API token for a registry from ~/.droy/credentials.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reg := getRegistry(authRegistry)
+
+		path := config.CredentialsPath()
+		creds, err := config.ReadCredentials(path)
+		if err != nil {
+			logger.Error("Failed to read credentials: %v", err)
+			return
+		}
+
+		if !creds.Delete(reg.URL) {
+			logger.Info("Not logged in to %s", reg.URL)
+			return
+		}
+
+		if err := config.WriteCredentials(creds, path); err != nil {
+			logger.Error("Failed to save credentials: %v", err)
+			return
+		}
+
+		if os.Getenv(config.TokenEnv) != "" && config.RegistryHost(reg.URL) == config.RegistryHost(settings.Get("registry")) {
+			logger.Warning("%s is set and will still be used", config.TokenEnv)
+		}
+
+		logger.Success("Logged out of %s", reg.URL)
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the user logged in to a registry",
+	Run: func(cmd *cobra.Command, args []string) {
+		reg := getRegistry(authRegistry)
+
+		username, err := reg.Whoami()
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(username)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{loginCmd, logoutCmd, whoamiCmd} {
+		c.Flags().StringVarP(&authRegistry, "registry", "r", "", "Registry URL (default from config)")
+	}
+	loginCmd.Flags().StringVar(&loginToken, "token", "", "API token (prompted for if not given)")
+}
@@ -128,21 +128,21 @@ A Droy project.
 
 ## Installation
 
-\`\`\`bash
+` + "```" + `bash
 droy-pm install
-\`\`\`
+` + "```" + `
 
 ## Usage
 
-\`\`\`bash
+` + "```" + `bash
 droy-pm run
-\`\`\`
+` + "```" + `
 
 ## Scripts
 
-- \`droy-pm run\` - Run the project
-- \`droy-pm build\` - Build the project
-- \`droy-pm test\` - Run tests
+- ` + "`" + `droy-pm run` + "`" + ` - Run the project
+- ` + "`" + `droy-pm build` + "`" + ` - Build the project
+- ` + "`" + `droy-pm test` + "`" + ` - Run tests
 
 ## License
 
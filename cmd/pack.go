@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/pack"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	packOutDir string
+	packDryRun bool
+)
+
+var packCmd = &cobra.Command{
+	Use:   "pack",
+	Short: "Create a package tarball",
+	Long: `Create the tarball publish would upload, without publishing it.
+
+Packing the same files always gives the same bytes: entries are sorted and
+have a fixed mtime, no owner, and 0644 or 0755 modes.
+
+droy.toml, the README, the LICENSE and the main file are always included.
+If droy.toml has a files list, only those paths, directories and globs are
+added; otherwise the whole project is. Paths matching .droyignore (which
+uses .gitignore syntax), droy_modules, droy.lock and VCS directories are
+left out.`,
+	Example: `  droy-pm pack                  # Write <name>-<version>.tgz here
+  droy-pm pack -o dist          # Write into dist/
+  droy-pm pack --dry-run        # Only list the files`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pkg, err := config.ReadPackageConfig("droy.toml")
+		if err != nil {
+			logger.Error("Failed to read droy.toml: %v", err)
+			return
+		}
+
+		outDir := packOutDir
+		if packDryRun {
+			outDir = ""
+		}
+
+		result, err := packProject(pkg, outDir)
+		if err != nil {
+			logger.Error("Failed to pack: %v", err)
+			return
+		}
+
+		printPackResult(pkg, result)
+
+		if packDryRun {
+			logger.Info("Dry run - no tarball written")
+			return
+		}
+		logger.Success("Created %s", result.Path)
+	},
+}
+
+// packProject packs the project in the current directory into outDir, or
+// only computes the tarball's details if outDir is ""
+func packProject(pkg *config.Package, outDir string) (*pack.Result, error) {
+	files, err := pack.List(".", pkg, projectRelative(modulesDir()))
+	if err != nil {
+		return nil, err
+	}
+
+	if outDir == "" {
+		return pack.Write(io.Discard, ".", files)
+	}
+	return pack.Create(".", files, filepath.Join(outDir, pack.Filename(pkg.Name, pkg.Version)))
+}
+
+// projectRelative returns path relative to the current directory, for
+// matching it against project files
+func projectRelative(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+func printPackResult(pkg *config.Package, result *pack.Result) {
+	fmt.Println()
+	color.Cyan("📦 %s@%s", pkg.Name, pkg.Version)
+	fmt.Println()
+
+	color.Yellow("Tarball Contents:")
+	for _, file := range result.Files {
+		fmt.Printf("  %10s  %s\n", formatFileSize(file.Size), file.Path)
+	}
+	fmt.Println()
+
+	color.Yellow("Tarball Details:")
+	fmt.Printf("  %-14s %s\n", "Filename:", pack.Filename(pkg.Name, pkg.Version))
+	fmt.Printf("  %-14s %s\n", "Package size:", formatFileSize(result.Size))
+	fmt.Printf("  %-14s %s\n", "Unpacked size:", formatFileSize(result.UnpackedSize))
+	fmt.Printf("  %-14s %d\n", "Total files:", len(result.Files))
+	fmt.Printf("  %-14s %s\n", "Shasum:", result.Shasum)
+	fmt.Printf("  %-14s %s\n", "Integrity:", result.Integrity)
+	fmt.Println()
+}
+
+func init() {
+	packCmd.Flags().StringVarP(&packOutDir, "out-dir", "o", ".", "Directory to write the tarball to")
+	packCmd.Flags().BoolVar(&packDryRun, "dry-run", false, "List the files without writing a tarball")
+}
@@ -1,201 +1,232 @@
 package cmd
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"fmt"
-	"io"
 	"os"
-	"path/filepath"
-	"strings"
-	"time"
 
 	"github.com/droy-go/droy-pm/pkg/config"
-	"github.com/droy-go/droy-pm/pkg/registry"
 	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/semver"
+	"github.com/droy-go/droy-pm/pkg/signing"
+	"github.com/droy-go/droy-pm/pkg/validate"
+	"github.com/droy-go/droy-pm/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
 var (
 	publishRegistry string
 	publishDryRun   bool
+	publishTag      string
+	publishAccess   string
 )
 
 var publishCmd = &cobra.Command{
 	Use:   "publish",
 	Short: "Publish package to registry",
 	Long: `Publish your Droy package to the registry.
-This will create a tarball and upload it to the specified registry.`,
+This will create a tarball and upload it to the specified registry.
+
+Before uploading, the package is validated and publishing stops on any
+error:
+  - the name follows the naming rules and the version is strict semver
+  - the package is not private
+  - the license is a valid SPDX expression
+  - main and every bin target are in the tarball
+  - no .env files, keys or credentials are in the tarball
+  - the tarball is within the size and file count limits
+  - the version is not already on the registry
+
+The registry, dist-tag and access level come from [publishConfig] in
+droy.toml unless given as flags. The new version is tagged latest by
+default; prereleases must be published with an explicit tag such as next,
+so that installs without a version keep getting the stable release.
+
+With a signing key configured (the signing-key setting or --signing-key),
+the tarball is signed and the detached signature sent along with it,
+so that installs can verify it. Create a key with 'droy-pm keys generate'.
+
+With --workspace or --filter, publishes each selected workspace member,
+dependencies first, skipping private members and versions that are already
+on the registry.`,
+	Example: `  droy-pm publish
+  droy-pm publish --tag next              # Publish a beta without moving latest
+  droy-pm publish --access public         # Publish a scoped package publicly
+  droy-pm publish --signing-key release   # Sign with ~/.droy/keys/release.key
+  droy-pm publish --dry-run
+  droy-pm publish --workspace             # Publish every changed member`,
 	Run: func(cmd *cobra.Command, args []string) {
-		logger.Info("Preparing package for publication...")
-
-		// Read package config
-		pkg, err := config.ReadPackageConfig("droy.toml")
-		if err != nil {
-			logger.Error("Failed to read droy.toml: %v", err)
-			return
-		}
-
-		// Validate package
-		if err := validatePackage(pkg); err != nil {
-			logger.Error("Validation failed: %v", err)
-			return
-		}
-
-		// Create tarball
-		tarballPath, err := createTarball(pkg)
-		if err != nil {
-			logger.Error("Failed to create tarball: %v", err)
-			return
-		}
-
-		if publishDryRun {
-			logger.Info("Dry run - tarball created at: %s", tarballPath)
-			logger.Success("Package '%s' v%s is ready for publication", pkg.Name, pkg.Version)
+		if workspaceMode() {
+			forEachMember(publishMember)
 			return
 		}
 
-		// Publish to registry
-		reg := registry.New(publishRegistry)
-		if err := reg.Publish(pkg, tarballPath); err != nil {
-			logger.Error("Failed to publish: %v", err)
-			os.Remove(tarballPath)
-			return
-		}
-
-		// Clean up tarball
-		os.Remove(tarballPath)
-
-		logger.Success("Published %s@%s to %s", pkg.Name, pkg.Version, publishRegistry)
+		publishPackage()
 	},
 }
 
-func validatePackage(pkg *config.Package) error {
-	if pkg.Name == "" {
-		return fmt.Errorf("package name is required")
-	}
-	if pkg.Version == "" {
-		return fmt.Errorf("package version is required")
-	}
-	if !isValidVersion(pkg.Version) {
-		return fmt.Errorf("invalid version format: %s", pkg.Version)
+// publishMember publishes a workspace member, skipping private members and
+// versions that are already published so that only what changed goes out
+func publishMember(m *workspace.Member) bool {
+	pkg := m.Package
+	if pkg.Private {
+		logger.Info("Skipping %s: private", pkg.Name)
+		return true
 	}
 
-	// Check if src directory exists
-	if _, err := os.Stat("src"); os.IsNotExist(err) {
-		return fmt.Errorf("src directory not found")
+	reg := targetRegistry(pkg.Name, publishRegistry)
+	if validate.Unpublished(reg, pkg).Errors() > 0 {
+		logger.Info("Skipping %s@%s: already published", pkg.Name, pkg.Version)
+		return true
 	}
 
-	return nil
+	return publishPackage()
 }
 
-func isValidVersion(version string) bool {
-	// Simple semver validation
-	parts := strings.Split(version, ".")
-	if len(parts) != 3 {
+// publishPackage validates, packs and publishes the package in the current
+// directory, and reports whether it succeeded
+func publishPackage() bool {
+	logger.Info("Preparing package for publication...")
+
+	// Read package config
+	pkg, err := config.ReadPackageConfig("droy.toml")
+	if err != nil {
+		logger.Error("Failed to read droy.toml: %v", err)
 		return false
 	}
-	return true
-}
 
-func createTarball(pkg *config.Package) (string, error) {
-	filename := fmt.Sprintf("%s-%s.tgz", pkg.Name, pkg.Version)
-	path := filepath.Join(os.TempDir(), filename)
+	problems := validate.Manifest(pkg)
+	if problems.Errors() > 0 {
+		reportProblems(problems)
+		return false
+	}
 
-	file, err := os.Create(path)
+	opts, err := publishOptions(pkg)
 	if err != nil {
-		return "", err
+		logger.Error("%v", err)
+		return false
 	}
-	defer file.Close()
-
-	gzWriter := gzip.NewWriter(file)
-	defer gzWriter.Close()
 
-	tarWriter := tar.NewWriter(gzWriter)
-	defer tarWriter.Close()
-
-	// Add files to tarball
-	files := []string{
-		"droy.toml",
-		"README.md",
-		"LICENSE",
+	key, err := publishSigningKey()
+	if err != nil {
+		logger.Error("Failed to load signing key: %v", err)
+		return false
 	}
 
-	// Add src directory
-	err = filepath.Walk("src", func(file string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	// Create tarball
+	result, err := packProject(pkg, os.TempDir())
+	if err != nil {
+		logger.Error("Failed to create tarball: %v", err)
+		return false
+	}
+	tarballPath := result.Path
+	defer os.Remove(tarballPath)
 
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
-			return err
-		}
+	reg := targetRegistry(pkg.Name, publishRegistry)
+	problems = append(problems, validate.Contents(".", pkg, result)...)
+	problems = append(problems, validate.Unpublished(reg, pkg)...)
+	if !reportProblems(problems) {
+		return false
+	}
 
-		header.Name = filepath.ToSlash(file)
+	if key != nil {
+		opts.Signature = signing.Sign(key, pkg.Name, pkg.Version, result.Integrity)
+	}
 
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
+	if publishDryRun {
+		printPackResult(pkg, result)
+		if opts.Signature != nil {
+			logger.Info("Would sign with key %s (%s)", key.Name, opts.Signature.KeyID)
 		}
+		logger.Info("Dry run - nothing was published to %s", reg.URL)
+		logger.Success("Package '%s' v%s is ready for publication", pkg.Name, pkg.Version)
+		return true
+	}
 
-		if !info.IsDir() {
-			data, err := os.Open(file)
-			if err != nil {
-				return err
-			}
-			defer data.Close()
+	// Publish to registry
+	if err := reg.Publish(pkg, tarballPath, opts); err != nil {
+		logger.Error("Failed to publish: %v", err)
+		return false
+	}
 
-			if _, err := io.Copy(tarWriter, data); err != nil {
-				return err
-			}
-		}
+	tag := opts.Tag
+	if tag == "" {
+		tag = registry.DefaultTag
+	}
+	logger.Success("Published %s@%s to %s with tag %s", pkg.Name, pkg.Version, reg.URL, tag)
 
-		return nil
-	})
+	if opts.Signature != nil {
+		logger.Success("Signed with key %s (%s)", key.Name, opts.Signature.KeyID)
+	}
+	return true
+}
 
-	if err != nil {
-		return "", err
+// publishSigningKey loads the configured signing key, or returns nil if
+// packages are not signed
+func publishSigningKey() (*signing.Key, error) {
+	name := settings.Get("signing-key")
+	if name == "" {
+		return nil, nil
 	}
 
-	// Add other files
-	for _, file := range files {
-		if _, err := os.Stat(file); os.IsNotExist(err) {
-			continue
-		}
+	return signing.Load(settings.Path("keys-dir"), name)
+}
 
-		info, err := os.Stat(file)
-		if err != nil {
-			continue
-		}
+// publishOptions returns the dist-tag and access level to publish with,
+// from the flags or else publishConfig
+func publishOptions(pkg *config.Package) (registry.PublishOptions, error) {
+	var opts registry.PublishOptions
+	if pkg.PublishConfig != nil {
+		opts.Tag = pkg.PublishConfig.Tag
+		opts.Access = pkg.PublishConfig.Access
+	}
+	if publishTag != "" {
+		opts.Tag = publishTag
+	}
+	if publishAccess != "" {
+		opts.Access = publishAccess
+	}
 
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
-			continue
+	if opts.Tag != "" {
+		if err := registry.ValidTag(opts.Tag); err != nil {
+			return opts, err
 		}
-
-		header.Name = file
-		header.ModTime = time.Now()
-
-		if err := tarWriter.WriteHeader(header); err != nil {
-			continue
+	}
+	if opts.Access != "" {
+		if err := registry.ValidAccess(opts.Access); err != nil {
+			return opts, err
 		}
+	}
 
-		data, err := os.Open(file)
-		if err != nil {
-			continue
-		}
+	if v, err := semver.Parse(pkg.Version); err == nil && v.IsPrerelease() && opts.Tag == "" {
+		return opts, fmt.Errorf("%s is a prerelease; publish it with a tag such as --tag next so latest is not moved", pkg.Version)
+	}
+	return opts, nil
+}
 
-		if _, err := io.Copy(tarWriter, data); err != nil {
-			data.Close()
-			continue
+// reportProblems prints validation problems and reports whether the
+// package may be published
+func reportProblems(problems validate.Problems) bool {
+	for _, problem := range problems {
+		if problem.Severity == validate.Error {
+			logger.Error("%s", problem.Message)
+		} else {
+			logger.Warning("%s", problem.Message)
 		}
-		data.Close()
 	}
 
-	return path, nil
+	if n := problems.Errors(); n > 0 {
+		logger.Error("Validation failed with %d error(s)", n)
+		return false
+	}
+	return true
 }
 
 func init() {
-	publishCmd.Flags().StringVarP(&publishRegistry, "registry", "r", "https://registry.droy-lang.org", "Registry URL")
+	publishCmd.Flags().StringVarP(&publishRegistry, "registry", "r", "", "Registry URL (default from config)")
 	publishCmd.Flags().BoolVarP(&publishDryRun, "dry-run", "d", false, "Prepare but don't publish")
+	publishCmd.Flags().StringVarP(&publishTag, "tag", "t", "", "Dist-tag to point at the new version (default latest)")
+	publishCmd.Flags().StringVar(&publishAccess, "access", "", "Access level: public or restricted")
+	publishCmd.Flags().String("signing-key", "", "Name of the key to sign the package with (default from config)")
+	addWorkspaceFlags(publishCmd)
 }
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/installer"
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/resolver"
+	"github.com/droy-go/droy-pm/pkg/signing"
+	"github.com/droy-go/droy-pm/pkg/store"
+)
+
+// registries holds one client per registry URL, shared by every command,
+// resolver and installer in the process
+var registries = make(map[string]*registry.Registry)
+
+// getRegistry returns the shared client for a registry URL ("" for the
+// configured registry), honouring the offline, prefer-offline, cache-ttl
+// and cache-dir settings. Scoped packages are routed to the registries
+// configured with "@scope:registry" keys
+func getRegistry(url string) *registry.Registry {
+	if url == "" {
+		url = settings.Get("registry")
+	}
+	url = strings.TrimSuffix(url, "/")
+
+	if reg, ok := registries[url]; ok {
+		return reg
+	}
+
+	reg := newRegistryClient(url)
+	registries[url] = reg
+
+	reg.Scopes = make(map[string]*registry.Registry)
+	for scope, scopeURL := range settings.ScopeRegistries() {
+		reg.Scopes[scope] = getRegistry(scopeURL)
+	}
+
+	return reg
+}
+
+// newRegistryClient creates a client for url configured like getRegistry's,
+// except that it sends every request to url, scoped packages included. It
+// uses the token stored for the host of url, or DROY_TOKEN when url is on
+// the host of the registry setting, which a project's .droyrc cannot set
+func newRegistryClient(url string) *registry.Registry {
+	reg := registry.New(strings.TrimSuffix(url, "/"))
+	reg.Token = config.RegistryToken(url, settings.Get("registry"))
+	reg.CacheDir = filepath.Join(settings.Path("cache-dir"), "metadata")
+	reg.CacheTTL = settings.Duration("cache-ttl")
+	reg.Offline = settings.Bool("offline")
+	reg.PreferOffline = settings.Bool("prefer-offline")
+	return reg
+}
+
+// targetRegistry returns the registry a package is published to and
+// tagged on: url if given, else publishConfig.registry when name is the
+// project in the current directory, else the registry that serves name
+func targetRegistry(name, url string) *registry.Registry {
+	if url == "" {
+		pkg, err := config.ReadPackageConfig("droy.toml")
+		if err == nil && pkg.Name == name && pkg.PublishConfig != nil {
+			url = pkg.PublishConfig.Registry
+		}
+	}
+
+	if url == "" {
+		return getRegistry("").ForPackage(name)
+	}
+	return newRegistryClient(url)
+}
+
+// newInstaller creates an installer for the configured modules directory,
+// using the shared registry client, the configured cache and store and the
+// signature policy
+func newInstaller() *installer.Installer {
+	inst := installer.New(modulesDir())
+	inst.CachePath = settings.Path("cache-dir")
+	inst.Registry = getRegistry("")
+	inst.Store = newStore()
+	inst.Verifier = newVerifier()
+	return inst
+}
+
+// newVerifier returns the configured signature policy and trusted keys.
+// Invalid keys are reported and trust nothing, so require still fails closed
+func newVerifier() *signing.Verifier {
+	policy := settings.Get("signature-policy")
+	verifier, err := signing.NewVerifier(policy, settings.List("trusted-keys"))
+	if err != nil {
+		logger.Warning("Ignoring trusted-keys: %v", err)
+		return &signing.Verifier{Policy: policy}
+	}
+	return verifier
+}
+
+// reportWarnings prints the warnings an installer recorded, such as
+// unverified signatures under the warn policy
+func reportWarnings(inst *installer.Installer) {
+	for _, warning := range inst.Warnings() {
+		logger.Warning("%s", warning)
+	}
+}
+
+// newStore returns the configured package store
+func newStore() *store.Store {
+	return store.New(settings.Path("store-dir"))
+}
+
+// newResolver creates a resolver using the shared registry client
+func newResolver() *resolver.Resolver {
+	return resolver.NewWithRegistry(getRegistry(""))
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 
+	"github.com/droy-go/droy-pm/pkg/registry"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -33,6 +34,7 @@ var (
 
 func Execute() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.SilenceErrors = true
 	if err := rootCmd.Execute(); err != nil {
 		color.Red("Error: %v", err)
 		os.Exit(1)
@@ -40,18 +42,42 @@ func Execute() {
 }
 
 func init() {
+	// Flags named after config keys override the configuration files and
+	// environment; loadSettings applies them
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return loadSettings(cmd)
+	}
+	rootCmd.PersistentFlags().Bool("offline", false, "Use only cached registry metadata and packages")
+	rootCmd.PersistentFlags().Bool("prefer-offline", false, "Use cached registry metadata and packages when available")
+	rootCmd.PersistentFlags().Duration("cache-ttl", registry.DefaultCacheTTL, "How long cached registry metadata is used before revalidating")
+
 	// Project management
 	rootCmd.AddCommand(newCmd)
 	rootCmd.AddCommand(initCmd)
 
 	// Package management
 	rootCmd.AddCommand(installCmd)
+	rootCmd.AddCommand(ciCmd)
 	rootCmd.AddCommand(uninstallCmd)
 	rootCmd.AddCommand(updateCmd)
 	rootCmd.AddCommand(searchCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(packCmd)
 	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(distTagCmd)
+	rootCmd.AddCommand(keysCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(licensesCmd)
+	rootCmd.AddCommand(sbomCmd)
 	rootCmd.AddCommand(cleanCmd)
+	rootCmd.AddCommand(storeCmd)
+	rootCmd.AddCommand(configCmd)
+
+	// Registry account
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+	rootCmd.AddCommand(whoamiCmd)
 
 	// Development
 	rootCmd.AddCommand(runCmd)
@@ -1,15 +1,13 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/workspace"
 	"github.com/droy-go/droy-pm/internal/logger"
-	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
@@ -24,78 +22,95 @@ var runCmd = &cobra.Command{
 	Short: "Run a Droy program",
 	Long: `Execute a Droy program file.
 If no file is specified, looks for the main file defined in droy.toml
-or defaults to src/main.droy.`,
+or defaults to src/main.droy.
+
+With --workspace or --filter, runs in each selected workspace member.`,
 	Example: `  droy-pm run                    # Run default main file
   droy-pm run app.droy           # Run specific file
-  droy-pm run app.droy -- arg1   # Run with arguments`,
+  droy-pm run app.droy -- arg1   # Run with arguments
+  droy-pm run --filter cli       # Run the cli member's main file`,
 	Run: func(cmd *cobra.Command, args []string) {
-		var targetFile string
-		
-		if len(args) > 0 {
-			targetFile = args[0]
-		} else {
-			// Try to get from droy.toml
-			pkg, err := config.ReadPackageConfig("droy.toml")
-			if err == nil && pkg.Main != "" {
-				targetFile = pkg.Main
-			} else {
-				// Default to src/main.droy
-				targetFile = "src/main.droy"
-			}
-		}
-
-		// Check if file exists
-		if _, err := os.Stat(targetFile); os.IsNotExist(err) {
-			logger.Error("File not found: %s", targetFile)
-			logger.Info("Make sure the file exists or specify a different file")
+		if workspaceMode() {
+			forEachMember(func(m *workspace.Member) bool {
+				return runProgram(args)
+			})
 			return
 		}
 
-		logger.Info("Running %s...", targetFile)
-		
-		// Find the Droy interpreter
-		droyPath := findDroyInterpreter()
-		if droyPath == "" {
-			logger.Error("Droy interpreter not found")
-			logger.Info("Make sure Droy is installed and in your PATH")
-			logger.Info("Install from: https://github.com/droy-go/droy-lang")
-			return
-		}
+		runProgram(args)
+	},
+}
 
-		// Build command
-		execCmd := exec.Command(droyPath, targetFile)
-		
-		// Pass additional arguments
-		if len(args) > 1 {
-			execCmd.Args = append(execCmd.Args, args[1:]...)
+// runProgram runs a Droy file, or the main file of the package in the
+// current directory, and reports whether it succeeded
+func runProgram(args []string) bool {
+	var targetFile string
+	
+	if len(args) > 0 {
+		targetFile = args[0]
+	} else {
+		// Try to get from droy.toml
+		pkg, err := config.ReadPackageConfig("droy.toml")
+		if err == nil && pkg.Main != "" {
+			targetFile = pkg.Main
+		} else {
+			// Default to src/main.droy
+			targetFile = "src/main.droy"
 		}
+	}
 
-		// Set environment
-		execCmd.Env = os.Environ()
-		if runDebug {
-			execCmd.Env = append(execCmd.Env, "DROY_DEBUG=1")
-		}
+	// Check if file exists
+	if _, err := os.Stat(targetFile); os.IsNotExist(err) {
+		logger.Error("File not found: %s", targetFile)
+		logger.Info("Make sure the file exists or specify a different file")
+		return false
+	}
+
+	logger.Info("Running %s...", targetFile)
+	
+	// Find the Droy interpreter
+	droyPath := findDroyInterpreter()
+	if droyPath == "" {
+		logger.Error("Droy interpreter not found")
+		logger.Info("Make sure Droy is installed and in your PATH")
+		logger.Info("Install from: https://github.com/droy-go/droy-lang")
+		return false
+	}
 
-		// Set working directory
-		execCmd.Dir = "."
+	// Build command
+	execCmd := exec.Command(droyPath, targetFile)
+	
+	// Pass additional arguments
+	if len(args) > 1 {
+		execCmd.Args = append(execCmd.Args, args[1:]...)
+	}
 
-		// Connect stdio
-		execCmd.Stdin = os.Stdin
-		execCmd.Stdout = os.Stdout
-		execCmd.Stderr = os.Stderr
+	// Set environment
+	execCmd.Env = os.Environ()
+	if runDebug {
+		execCmd.Env = append(execCmd.Env, "DROY_DEBUG=1")
+	}
 
-		// Run the program
-		if err := execCmd.Run(); err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				logger.Error("Program exited with code %d", exitErr.ExitCode())
-			} else {
-				logger.Error("Failed to run program: %v", err)
-			}
-			return
+	// Set working directory
+	execCmd.Dir = "."
+
+	// Connect stdio
+	execCmd.Stdin = os.Stdin
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+
+	// Run the program
+	if err := execCmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			logger.Error("Program exited with code %d", exitErr.ExitCode())
+		} else {
+			logger.Error("Failed to run program: %v", err)
 		}
+		return false
+	}
 
-		logger.Success("Program completed successfully")
-	},
+	logger.Success("Program completed successfully")
+	return true
 }
 
 var scriptCmd = &cobra.Command{
@@ -179,6 +194,7 @@ func findDroyInterpreter() string {
 
 func init() {
 	runCmd.Flags().BoolVarP(&runDebug, "debug", "d", false, "Enable debug mode")
+	addWorkspaceFlags(runCmd)
 	
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(scriptCmd)
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/installer"
+	"github.com/droy-go/droy-pm/pkg/resolver"
+	"github.com/droy-go/droy-pm/pkg/sbom"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sbomFormat string
+	sbomOutput string
+)
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom",
+	Short: "Generate a software bill of materials",
+	Long: `Generate a software bill of materials (SBOM) for the project from
+droy.lock, in CycloneDX 1.5 or SPDX 2.3 JSON.
+
+Every locked package is listed with its version, package URL (purl),
+download URL, hashes from its integrity and the license it declares, along
+with the dependency relationships between packages. Licenses and
+descriptions are read from droy_modules, or from the registry for packages
+that are not installed.
+
+droy.lock must be up to date with droy.toml; run 'droy-pm install' first.`,
+	Example: `  droy-pm sbom > bom.json
+  droy-pm sbom --format spdx-json --output sbom.spdx.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if sbomFormat != sbom.FormatCycloneDX && sbomFormat != sbom.FormatSPDX {
+			logger.Error("Unknown format %q, expected %s or %s", sbomFormat, sbom.FormatCycloneDX, sbom.FormatSPDX)
+			os.Exit(1)
+		}
+
+		pkg, err := config.ReadPackageConfig("droy.toml")
+		if err != nil {
+			logger.Error("Failed to read droy.toml: %v", err)
+			os.Exit(1)
+		}
+
+		lock, err := config.ReadLockFile("droy.lock")
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Error("droy.lock not found; run 'droy-pm install' first")
+			os.Exit(1)
+		}
+		if err != nil {
+			logger.Error("Failed to read droy.lock: %v", err)
+			os.Exit(1)
+		}
+		deps, local, err := projectDependencies(pkg)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		err = resolver.CheckLock(lock, deps)
+		if err == nil {
+			err = resolver.CheckLocal(lock, local)
+		}
+		if err != nil {
+			logger.Error("droy.lock is out of date: %v; run 'droy-pm install'", err)
+			os.Exit(1)
+		}
+
+		bom := sbom.FromLock(pkg, lock)
+		bom.Tool, bom.ToolVersion = "droy-pm", version
+		addMetadata(bom)
+
+		if sbomOutput == "" {
+			if err := sbom.Encode(os.Stdout, bom, sbomFormat); err != nil {
+				logger.Error("Failed to write SBOM: %v", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if err := writeSBOM(bom, sbomOutput); err != nil {
+			logger.Error("Failed to write SBOM: %v", err)
+			os.Exit(1)
+		}
+		logger.Success("Wrote %s SBOM with %d packages to %s", sbomFormat, len(bom.Components), sbomOutput)
+	},
+}
+
+// addMetadata fills in the license and description of each component from
+// its installed droy.toml, falling back to the registry's metadata for
+// that version. Packages whose metadata cannot be found are left without
+// a license, which the SBOM records as unknown
+func addMetadata(bom *sbom.BOM) {
+	inst := installer.New(modulesDir())
+	reg := getRegistry("")
+
+	for i := range bom.Components {
+		c := &bom.Components[i]
+
+		manifest, err := config.ReadPackageConfig(filepath.Join(inst.PackageDir(c.Name), "droy.toml"))
+		if err == nil && manifest.Version == c.Version {
+			c.License, c.Description = manifest.License, manifest.Description
+			continue
+		}
+
+		if strings.HasPrefix(c.Name, "github.com/") {
+			continue
+		}
+		if info, err := reg.GetPackageVersion(c.Name, c.Version); err == nil {
+			c.License, c.Description = info.License, info.Description
+		}
+	}
+}
+
+// writeSBOM writes the BOM to a file, removing it again if encoding fails
+func writeSBOM(bom *sbom.BOM, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := sbom.Encode(f, bom, sbomFormat); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func init() {
+	sbomCmd.Flags().StringVarP(&sbomFormat, "format", "f", sbom.FormatCycloneDX, "SBOM format: cyclonedx-json or spdx-json")
+	sbomCmd.Flags().StringVarP(&sbomOutput, "output", "o", "", "Write the SBOM to a file instead of stdout")
+}
@@ -1,47 +1,109 @@
 package cmd
 
 import (
-	"github.com/droy-go/droy-pm/pkg/registry"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/registry"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
-var searchRegistry string
+var (
+	searchRegistry string
+	searchSize     int
+	searchFrom     int
+	searchKeywords []string
+	searchAuthor   string
+	searchSort     string
+	searchJSON     bool
+)
 
 var searchCmd = &cobra.Command{
-	Use:   "search <query>",
-	Short: "Search for packages",
-	Long:  `Search for packages in the Droy registry.`,
-	Args:  cobra.ExactArgs(1),
+	Use:     "search [query]",
+	Aliases: []string{"find", "s"},
+	Short:   "Search for packages",
+	Long:    `Search for packages in the Droy registry.`,
+	Example: `  droy-pm search http
+  droy-pm search http --size 50 --from 50       # Second page of 50
+  droy-pm search --keywords json,parser --sort name
+  droy-pm search --author droy-team --json`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		query := args[0]
-		
-		logger.Info("Searching for '%s'...", query)
+		query := strings.Join(args, " ")
+		if query == "" && len(searchKeywords) == 0 && searchAuthor == "" {
+			logger.Error("Give a query, --keywords or --author")
+			return
+		}
+		if searchSize < 1 || searchFrom < 0 {
+			logger.Error("--size must be positive and --from must not be negative")
+			return
+		}
 
-		reg := registry.New(searchRegistry)
-		results, err := reg.Search(query)
+		if !searchJSON {
+			logger.Info("Searching for '%s'...", query)
+		}
+
+		reg := getRegistry(searchRegistry)
+		result, err := reg.Search(query, registry.SearchOptions{
+			Size:     searchSize,
+			From:     searchFrom,
+			Keywords: searchKeywords,
+			Author:   searchAuthor,
+			Sort:     searchSort,
+		})
 		if err != nil {
 			logger.Error("Search failed: %v", err)
+			os.Exit(1)
+		}
+
+		if searchJSON {
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				logger.Error("Failed to encode results: %v", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
 			return
 		}
 
-		if len(results) == 0 {
+		if len(result.Packages) == 0 {
 			logger.Warning("No packages found matching '%s'", query)
 			return
 		}
 
-		logger.Success("Found %d packages:\n", len(results))
-		
-		for _, pkg := range results {
+		total := result.Total
+		if total < searchFrom+len(result.Packages) {
+			total = searchFrom + len(result.Packages)
+		}
+		logger.Success("Found %d packages, showing %d-%d:\n",
+			total, searchFrom+1, searchFrom+len(result.Packages))
+
+		for _, pkg := range result.Packages {
 			color.Cyan("  %s", pkg.Name)
 			color.White("    %s", pkg.Description)
-			color.Yellow("    Version: %s | Author: %s | License: %s\n", 
+			if len(pkg.Keywords) > 0 {
+				color.White("    Keywords: %s", strings.Join(pkg.Keywords, ", "))
+			}
+			color.Yellow("    Version: %s | Author: %s | License: %s\n",
 				pkg.Version, pkg.Author, pkg.License)
 		}
+
+		if next := searchFrom + len(result.Packages); next < total {
+			logger.Info("Use --from %d to see more", next)
+		}
 	},
 }
 
 func init() {
-	searchCmd.Flags().StringVarP(&searchRegistry, "registry", "r", "https://registry.droy-lang.org", "Registry URL")
+	searchCmd.Flags().StringVarP(&searchRegistry, "registry", "r", "", "Registry URL (default from config)")
+	searchCmd.Flags().IntVarP(&searchSize, "size", "n", registry.DefaultSearchSize, "Number of results per page")
+	searchCmd.Flags().IntVar(&searchFrom, "from", 0, "Offset of the first result")
+	searchCmd.Flags().StringSliceVarP(&searchKeywords, "keywords", "k", nil, "Only packages with all of these keywords")
+	searchCmd.Flags().StringVarP(&searchAuthor, "author", "a", "", "Only packages by this author")
+	searchCmd.Flags().StringVar(&searchSort, "sort", "relevance", "Sort by "+strings.Join(registry.SearchSorts, ", "))
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "Print results as JSON")
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Manage the global package store",
+	Long: `Registry packages are extracted once into the store (the store-dir config
+key, ~/.droy/store by default), keyed by their integrity hash, and linked
+into each project's droy_modules.`,
+}
+
+var storeStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the size and usage of the package store",
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := newStore().Status()
+		if err != nil {
+			logger.Error("Failed to read store: %v", err)
+			return
+		}
+
+		fmt.Printf("%s %s\n", color.CyanString("Path:"), status.Path)
+		fmt.Printf("%s %d\n", color.CyanString("Packages:"), status.Packages)
+		fmt.Printf("%s %d (%s)\n", color.CyanString("Files:"), status.Files, formatFileSize(status.Size))
+		fmt.Printf("%s %d\n", color.CyanString("Projects:"), status.Projects)
+
+		if status.Unused > 0 {
+			logger.Info("%d packages are no longer used; run 'droy-pm store prune' to remove them", status.Unused)
+		}
+	},
+}
+
+var storePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove packages no project uses",
+	Long: `Remove packages from the store that are not referenced by the droy.lock
+of any project installed from it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		removed, err := newStore().Prune()
+		if err != nil {
+			logger.Error("Failed to prune store: %v", err)
+			return
+		}
+
+		logger.Success("Removed %d packages from the store", len(removed))
+	},
+}
+
+func init() {
+	storeCmd.AddCommand(storeStatusCmd)
+	storeCmd.AddCommand(storePruneCmd)
+}
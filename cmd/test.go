@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/workspace"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -24,66 +25,85 @@ var testCmd = &cobra.Command{
 	Aliases: []string{"t"},
 	Short:   "Run tests",
 	Long: `Run Droy tests in the current project.
-If a pattern is specified, only tests matching the pattern will run.`,
+If a pattern is specified, only tests matching the pattern will run.
+
+With --workspace or --filter, runs the tests of each selected workspace
+member and fails if any member's tests fail.`,
 	Example: `  droy-pm test              # Run all tests
   droy-pm test math         # Run tests matching "math"
   droy-pm test -v           # Verbose output
-  droy-pm test --coverage   # With coverage report`,
+  droy-pm test --coverage   # With coverage report
+  droy-pm test --workspace  # Test every workspace member`,
 	Run: func(cmd *cobra.Command, args []string) {
 		pattern := ""
 		if len(args) > 0 {
 			pattern = args[0]
 		}
 
-		logger.Info("Running tests...")
-
-		// Find test files
-		testFiles, err := findTestFiles(pattern)
-		if err != nil {
-			logger.Error("Failed to find test files: %v", err)
-			return
+		var ok bool
+		if workspaceMode() {
+			ok = forEachMember(func(m *workspace.Member) bool {
+				return runTests(pattern)
+			})
+		} else {
+			ok = runTests(pattern)
 		}
 
-		if len(testFiles) == 0 {
-			logger.Warning("No test files found")
-			if pattern != "" {
-				logger.Info("No tests match pattern: %s", pattern)
-			}
-			return
+		if !ok {
+			os.Exit(1)
 		}
+	},
+}
 
-		logger.Info("Found %d test file(s)", len(testFiles))
-
-		// Run tests
-		passed := 0
-		failed := 0
+// runTests runs the test files of the project in the current directory
+// and reports whether they all passed
+func runTests(pattern string) bool {
+	logger.Info("Running tests...")
 
-		for _, file := range testFiles {
-			if testVerbose {
-				logger.Info("Running: %s", file)
-			}
+	// Find test files
+	testFiles, err := findTestFiles(pattern)
+	if err != nil {
+		logger.Error("Failed to find test files: %v", err)
+		return false
+	}
 
-			result := runTestFile(file)
-			if result {
-				passed++
-			} else {
-				failed++
-			}
+	if len(testFiles) == 0 {
+		logger.Warning("No test files found")
+		if pattern != "" {
+			logger.Info("No tests match pattern: %s", pattern)
 		}
+		return true
+	}
+
+	logger.Info("Found %d test file(s)", len(testFiles))
 
-		// Print summary
-		fmt.Println()
-		color.Cyan("Test Results:")
-		color.Green("  Passed: %d", passed)
-		if failed > 0 {
-			color.Red("  Failed: %d", failed)
+	// Run tests
+	passed := 0
+	failed := 0
+
+	for _, file := range testFiles {
+		if testVerbose {
+			logger.Info("Running: %s", file)
 		}
-		fmt.Printf("  Total:  %d\n", passed+failed)
 
-		if failed > 0 {
-			os.Exit(1)
+		result := runTestFile(file)
+		if result {
+			passed++
+		} else {
+			failed++
 		}
-	},
+	}
+
+	// Print summary
+	fmt.Println()
+	color.Cyan("Test Results:")
+	color.Green("  Passed: %d", passed)
+	if failed > 0 {
+		color.Red("  Failed: %d", failed)
+	}
+	fmt.Printf("  Total:  %d\n", passed+failed)
+
+	return failed == 0
 }
 
 var benchCmd = &cobra.Command{
@@ -253,6 +273,7 @@ func init() {
 	testCmd.Flags().StringVarP(&testPattern, "pattern", "p", "", "Test pattern")
 	testCmd.Flags().BoolVar(&testCoverage, "coverage", false, "Generate coverage report")
 	testCmd.Flags().BoolVarP(&testWatch, "watch", "w", false, "Watch for changes")
+	addWorkspaceFlags(testCmd)
 
 	rootCmd.AddCommand(testCmd)
 	rootCmd.AddCommand(benchCmd)
@@ -1,9 +1,6 @@
 package cmd
 
 import (
-	"os"
-	"path/filepath"
-
 	"github.com/droy-go/droy-pm/pkg/config"
 	"github.com/droy-go/droy-pm/internal/logger"
 	"github.com/spf13/cobra"
@@ -20,9 +17,8 @@ var uninstallCmd = &cobra.Command{
 		
 		logger.Info("Uninstalling %s...", name)
 
-		// Remove from droy_modules
-		modulePath := filepath.Join("droy_modules", name)
-		if err := os.RemoveAll(modulePath); err != nil {
+		// Remove from droy_modules, including an emptied scope directory
+		if err := newInstaller().Uninstall(name); err != nil {
 			logger.Error("Failed to remove package directory: %v", err)
 			return
 		}
@@ -42,6 +38,7 @@ var uninstallCmd = &cobra.Command{
 		lock, err := config.ReadLockFile("droy.lock")
 		if err == nil {
 			delete(lock.Dependencies, name)
+			delete(lock.Packages, name)
 			
 			if err := config.WriteLockFile(lock, "droy.lock"); err != nil {
 				logger.Warning("Failed to update lock file: %v", err)
@@ -1,113 +1,212 @@
 package cmd
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/droy-go/droy-pm/pkg/config"
 	"github.com/droy-go/droy-pm/pkg/installer"
 	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/resolver"
 	"github.com/droy-go/droy-pm/internal/logger"
 	"github.com/spf13/cobra"
 )
 
+var updateLatest bool
+
 var updateCmd = &cobra.Command{
 	Use:   "update [package]",
 	Short: "Update packages",
-	Long: `Update packages to their latest versions.
-If no package is specified, updates all dependencies.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Update packages to the newest versions allowed by droy.toml.
+If no package is specified, updates all dependencies.
+Use --latest to ignore the version ranges and move to the latest release.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			updateAll()
-		} else {
-			updatePackage(args[0])
+			return updateAll()
 		}
+		return updatePackage(args[0])
 	},
 }
 
-func updateAll() {
+func updateAll() error {
 	logger.Info("Updating all dependencies...")
 
-	pkg, err := config.ReadPackageConfig("droy.toml")
+	pkg, err := readUpdateConfig()
 	if err != nil {
-		logger.Error("Failed to read droy.toml: %v", err)
-		return
+		return err
 	}
 
-	reg := registry.New("")
-	inst := installer.New("droy_modules")
-	updated := 0
-
-	for name, currentVersion := range pkg.Dependencies {
-		latest, err := reg.GetLatestVersion(name)
-		if err != nil {
-			logger.Warning("Could not check updates for %s: %v", name, err)
-			continue
-		}
-
-		if latest != currentVersion {
-			logger.Info("Updating %s: %s -> %s", name, currentVersion, latest)
-			
-			// Remove old version
-			inst.Uninstall(name)
-			
-			// Install new version
-			if err := inst.Install(name, latest); err != nil {
-				logger.Error("Failed to update %s: %v", name, err)
-				continue
+	if updateLatest {
+		reg := getRegistry("")
+		for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+			for _, name := range sortedDependencyNames(deps) {
+				target, err := updateTarget(reg, name, deps[name])
+				if err != nil {
+					return fmt.Errorf("could not check updates for %s: %w", name, err)
+				}
+				deps[name] = savedSpec(name, target)
 			}
-
-			pkg.Dependencies[name] = latest
-			updated++
 		}
 	}
 
-	// Update droy.toml
-	if err := config.WritePackageConfig(pkg, "droy.toml"); err != nil {
-		logger.Warning("Failed to update droy.toml: %v", err)
+	// Nothing is preferred, so every package, dependencies of dependencies
+	// included, moves to the newest version its ranges allow
+	updated, err := applyUpdate(pkg, nil)
+	if err != nil {
+		return err
 	}
 
 	logger.Success("Updated %d packages", updated)
+	return nil
 }
 
-func updatePackage(name string) {
+func updatePackage(name string) error {
 	logger.Info("Checking for updates to %s...", name)
 
-	pkg, err := config.ReadPackageConfig("droy.toml")
+	pkg, err := readUpdateConfig()
 	if err != nil {
-		logger.Error("Failed to read droy.toml: %v", err)
-		return
+		return err
 	}
 
-	currentVersion, exists := pkg.Dependencies[name]
+	deps := pkg.Dependencies
+	spec, exists := deps[name]
 	if !exists {
-		logger.Error("Package %s not found in dependencies", name)
-		return
+		deps = pkg.DevDependencies
+		spec, exists = deps[name]
+	}
+	if !exists {
+		return fmt.Errorf("package %s not found in dependencies", name)
 	}
 
-	reg := registry.New("")
-	latest, err := reg.GetLatestVersion(name)
+	reg := getRegistry("")
+	target, err := updateTarget(reg, name, spec)
 	if err != nil {
-		logger.Error("Could not check updates: %v", err)
-		return
+		return fmt.Errorf("could not check updates: %w", err)
 	}
 
-	if latest == currentVersion {
-		logger.Info("%s is already up to date (%s)", name, currentVersion)
-		return
+	previous := readLock()
+	current := currentVersion(previous, name)
+	if target == current {
+		logger.Info("%s is already up to date (%s)", name, current)
+		return nil
 	}
 
-	logger.Info("Updating %s: %s -> %s", name, currentVersion, latest)
+	if updateLatest {
+		deps[name] = savedSpec(name, target)
+	}
 
-	inst := installer.New("droy_modules")
-	inst.Uninstall(name)
-	
-	if err := inst.Install(name, latest); err != nil {
-		logger.Error("Failed to update %s: %v", name, err)
-		return
+	// Everything else stays at its locked version where it can
+	prefer := make(map[string]string)
+	if previous != nil {
+		prefer = resolver.LockedVersions(previous, allDependencies(pkg))
 	}
+	prefer[name] = target
 
-	pkg.Dependencies[name] = latest
-	if err := config.WritePackageConfig(pkg, "droy.toml"); err != nil {
-		logger.Warning("Failed to update droy.toml: %v", err)
+	if _, err := applyUpdate(pkg, prefer); err != nil {
+		return err
+	}
+
+	logger.Success("Updated %s to %s", name, target)
+	return nil
+}
+
+// readUpdateConfig reads droy.toml for update, which does not handle
+// workspaces
+func readUpdateConfig() (*config.Package, error) {
+	ws, err := findWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	if ws != nil {
+		return nil, fmt.Errorf("update does not support workspaces; change the ranges in droy.toml and run 'droy-pm install'")
+	}
+
+	pkg, err := config.ReadPackageConfig("droy.toml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read droy.toml: %w", err)
+	}
+	return pkg, nil
+}
+
+// applyUpdate resolves the project again, trying the versions in prefer
+// first, and installs the result, recording it in droy.lock and, with
+// --latest, the new ranges in droy.toml. It returns how many direct
+// dependencies changed version; a failed update leaves the project as it
+// was
+func applyUpdate(pkg *config.Package, prefer map[string]string) (int, error) {
+	previous := readLock()
+	deps := allDependencies(pkg)
+
+	lock, err := resolveLock(pkg, deps, nil, previous, prefer)
+	if err != nil {
+		return 0, err
 	}
 
-	logger.Success("Updated %s to %s", name, latest)
+	updated := 0
+	for _, name := range sortedDependencyNames(deps) {
+		current := currentVersion(previous, name)
+		if target := lock.Dependencies[name]; target != current {
+			logger.Info("Updating %s: %s -> %s", name, displayVersion(current), target)
+			updated++
+		}
+	}
+
+	err = inTransaction(nil, func(inst *installer.Installer) error {
+		if updateLatest {
+			if err := config.WritePackageConfig(pkg, "droy.toml"); err != nil {
+				return fmt.Errorf("failed to update droy.toml: %w", err)
+			}
+		}
+		return installLocked(inst, lock, resolver.LockedVersions(lock, deps), nil, true)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// currentVersion returns the version of a dependency in droy.lock, or the
+// installed version when it is not locked
+func currentVersion(lock *config.LockFile, name string) string {
+	if lock != nil {
+		if version, ok := lock.Dependencies[name]; ok {
+			return version
+		}
+	}
+	return installedVersion(name)
+}
+
+// updateTarget returns the version a dependency should be updated to:
+// the highest version matching its range, or the latest with --latest
+func updateTarget(reg *registry.Registry, name, spec string) (string, error) {
+	info, err := reg.GetPackage(name)
+	if err != nil {
+		return "", err
+	}
+
+	if updateLatest {
+		spec = "latest"
+	}
+
+	return resolver.SelectVersion(info, spec)
+}
+
+func sortedDependencyNames(deps map[string]string) []string {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func displayVersion(version string) string {
+	if version == "" {
+		return "not installed"
+	}
+	return version
+}
+
+func init() {
+	updateCmd.Flags().BoolVarP(&updateLatest, "latest", "L", false, "Update to the latest version, ignoring ranges in droy.toml")
 }
@@ -1,18 +1,55 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 
 	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/semver"
+	"github.com/droy-go/droy-pm/pkg/validate"
+	"github.com/droy-go/droy-pm/pkg/vcs"
 	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/spf13/cobra"
 )
 
+var (
+	versionPreid         string
+	versionMessage       string
+	versionNoGit         bool
+	versionIgnoreScripts bool
+)
+
 var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Show version information",
-	Long:  `Display the version of droy-pm and related information.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:   "version [major|minor|patch|prerelease|<version>]",
+	Short: "Show version information or bump the package version",
+	Long: `Display the version of droy-pm and related information.
+
+With an argument, bump the version in droy.toml instead: major, minor, patch
+or prerelease increment it, anything else is used as the new version. The
+preversion, version and postversion scripts from droy.toml run before the
+bump, after droy.toml is written and after the commit, with
+DROY_PACKAGE_VERSION set. Files the version script stages with git add are
+committed too.
+
+In a git repository droy.toml and droy.lock are then committed and tagged
+with an annotated v<version> tag. The working tree must be clean, apart
+from untracked files.`,
+	Example: `  droy-pm version                        # Show droy-pm's version
+  droy-pm version patch                  # 1.2.3 -> 1.2.4
+  droy-pm version minor -m "Release %s"  # 1.2.3 -> 1.3.0
+  droy-pm version prerelease --preid rc  # 1.2.3 -> 1.2.4-rc.0
+  droy-pm version 2.0.0 --no-git         # Only update droy.toml`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			return bumpVersion(args[0])
+		}
+
 		fmt.Println()
 		color.Cyan(`
 ╔══════════════════════════════════════════════════════════════╗
@@ -37,5 +74,230 @@ var versionCmd = &cobra.Command{
 		color.Cyan("  For Droy Programming Language")
 		color.Cyan("  https://github.com/droy-go/droy-lang")
 		fmt.Println()
+		return nil
 	},
 }
+
+// bumpVersion sets the package version, running the version scripts and
+// committing and tagging the change. If the version script, the commit or
+// the tag fails, droy.toml, droy.lock and the git index and branch are put
+// back as they were
+func bumpVersion(release string) error {
+	pkg, err := config.ReadPackageConfig("droy.toml")
+	if err != nil {
+		return fmt.Errorf("failed to read droy.toml: %w", err)
+	}
+
+	next, err := nextVersion(pkg.Version, release, versionPreid)
+	if err != nil {
+		return err
+	}
+	tag := "v" + next
+
+	var repo *vcs.Repo
+	if !versionNoGit {
+		repo, err = vcs.Open(".")
+		switch {
+		case errors.Is(err, vcs.ErrNotRepository):
+			logger.Info("Not a git repository; only droy.toml will be updated")
+		case err != nil:
+			return fmt.Errorf("failed to open git repository: %w", err)
+		}
+	}
+
+	var head plumbing.Hash
+	if repo != nil {
+		dirty, err := repo.Dirty()
+		if err != nil {
+			return err
+		}
+		if len(dirty) > 0 {
+			logger.Error("Git working tree is not clean; commit or stash these first:")
+			for _, file := range dirty {
+				fmt.Printf("  %s\n", file)
+			}
+			return fmt.Errorf("git working tree is not clean")
+		}
+
+		exists, err := repo.HasTag(tag)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("tag %s already exists", tag)
+		}
+
+		if head, err = repo.Head(); err != nil {
+			return err
+		}
+	}
+
+	if err := runVersionScript(pkg, "preversion", pkg.Version); err != nil {
+		return err
+	}
+
+	restore, err := snapshotFiles("droy.toml", "droy.lock")
+	if err != nil {
+		return err
+	}
+	rollback := func(err error) error {
+		if rerr := restore(); rerr != nil {
+			logger.Warning("Failed to restore droy.toml and droy.lock: %v", rerr)
+		}
+		if repo != nil && !head.IsZero() {
+			if rerr := repo.Reset(head); rerr != nil {
+				logger.Warning("%v", rerr)
+			}
+		}
+		return fmt.Errorf("%w; the version is still %s", err, pkg.Version)
+	}
+
+	files, err := writeVersion(next)
+	if err != nil {
+		return rollback(fmt.Errorf("failed to update version: %w", err))
+	}
+
+	if err := runVersionScript(pkg, "version", next); err != nil {
+		return rollback(err)
+	}
+
+	if repo != nil {
+		message := strings.ReplaceAll(versionMessage, "%s", next)
+
+		hash, err := repo.Commit(message, files...)
+		if err != nil {
+			return rollback(err)
+		}
+		if err := repo.Tag(tag, message, hash); err != nil {
+			return rollback(err)
+		}
+		logger.Info("Committed and tagged %s", tag)
+	}
+
+	// The release is done by now, so a failing postversion script only
+	// fails the command
+	if err := runVersionScript(pkg, "postversion", next); err != nil {
+		return fmt.Errorf("%w; %s was released", err, next)
+	}
+
+	logger.Success("%s -> %s", pkg.Version, next)
+	return nil
+}
+
+// snapshotFiles records the contents of files and returns a function that
+// puts them back, removing those that did not exist
+func snapshotFiles(files ...string) (func() error, error) {
+	saved := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to back up %s: %w", file, err)
+		}
+		saved[file] = data
+	}
+
+	return func() error {
+		var errs []error
+		for file, data := range saved {
+			var err error
+			if data == nil {
+				if err = os.Remove(file); os.IsNotExist(err) {
+					err = nil
+				}
+			} else {
+				err = os.WriteFile(file, data, 0644)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
+// nextVersion returns the version a release type or explicit version
+// bumps current to
+func nextVersion(current, release, preid string) (string, error) {
+	var next *semver.Version
+	switch release {
+	case "major", "minor", "patch", "prerelease":
+		v, err := semver.Parse(current)
+		if err != nil {
+			return "", fmt.Errorf("current version: %w", err)
+		}
+		switch release {
+		case "major":
+			next = v.IncMajor()
+		case "minor":
+			next = v.IncMinor()
+		case "patch":
+			next = v.IncPatch()
+		default:
+			next = v.IncPrerelease(preid)
+		}
+	default:
+		if err := validate.Version(release); err != nil {
+			return "", fmt.Errorf("expected major, minor, patch, prerelease or a version: %w", err)
+		}
+		next = semver.MustParse(release)
+	}
+
+	if next.String() == current {
+		return "", fmt.Errorf("version is already %s", current)
+	}
+	return next.String(), nil
+}
+
+// writeVersion writes the new version to droy.toml and droy.lock, and
+// returns the files it changed
+func writeVersion(version string) ([]string, error) {
+	if err := config.SetPackageVersion("droy.toml", version); err != nil {
+		return nil, err
+	}
+	files := []string{"droy.toml"}
+
+	lock, err := config.ReadLockFile("droy.lock")
+	if errors.Is(err, os.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lock.Version = version
+	if err := config.WriteLockFile(lock, "droy.lock"); err != nil {
+		return nil, err
+	}
+	return append(files, "droy.lock"), nil
+}
+
+// runVersionScript runs one of the version lifecycle scripts, if defined
+func runVersionScript(pkg *config.Package, name, version string) error {
+	script := pkg.Scripts[name]
+	if script == "" || versionIgnoreScripts {
+		return nil
+	}
+
+	logger.Info("Running %s script: %s", name, script)
+
+	execCmd := exec.Command("sh", "-c", script)
+	execCmd.Env = append(os.Environ(),
+		"DROY_PACKAGE_NAME="+pkg.Name,
+		"DROY_PACKAGE_VERSION="+version,
+	)
+	execCmd.Stdin = os.Stdin
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+
+	if err := execCmd.Run(); err != nil {
+		return fmt.Errorf("%s script failed: %w", name, err)
+	}
+	return nil
+}
+
+func init() {
+	versionCmd.Flags().StringVar(&versionPreid, "preid", "", "Prerelease identifier, e.g. beta for 1.2.4-beta.0")
+	versionCmd.Flags().StringVarP(&versionMessage, "message", "m", "%s", "Commit and tag message; %s is replaced by the version")
+	versionCmd.Flags().BoolVar(&versionNoGit, "no-git", false, "Do not commit or tag")
+	versionCmd.Flags().BoolVar(&versionIgnoreScripts, "ignore-scripts", false, "Do not run the version scripts")
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/droy-go/droy-pm/pkg/vcs"
+)
+
+// useGitProject runs the rest of a test in a git repository whose first
+// commit holds a droy.toml with the given scripts
+func useGitProject(t *testing.T, scripts string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	gitconfig := "[user]\n\tname = Test\n\temail = test@example.com\n"
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitconfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := "name = \"app\"\nversion = \"1.0.0\"\n"
+	if scripts != "" {
+		manifest += "\n[scripts]\n" + scripts
+	}
+	dir := useTestProject(t, manifest, "https://registry.example.com")
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "droy.toml"},
+		{"commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	preid, message, noGit, ignoreScripts := versionPreid, versionMessage, versionNoGit, versionIgnoreScripts
+	t.Cleanup(func() {
+		versionPreid, versionMessage, versionNoGit, versionIgnoreScripts = preid, message, noGit, ignoreScripts
+	})
+	versionPreid, versionMessage, versionNoGit, versionIgnoreScripts = "", "%s", false, false
+	return dir
+}
+
+func gitOutput(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestBumpVersionCommitsAndTags(t *testing.T) {
+	useGitProject(t, "")
+
+	if err := bumpVersion("patch"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile("droy.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `version = "1.0.1"`) {
+		t.Errorf("droy.toml was not bumped:\n%s", data)
+	}
+	if got := gitOutput(t, "tag", "--points-at", "HEAD"); got != "v1.0.1" {
+		t.Errorf("tags on HEAD = %q, want v1.0.1", got)
+	}
+	if got := gitOutput(t, "status", "--porcelain"); got != "" {
+		t.Errorf("working tree is not clean:\n%s", got)
+	}
+}
+
+func TestBumpVersionRollsBackWhenVersionScriptFails(t *testing.T) {
+	// The script stages a file before failing; the index is reset too
+	useGitProject(t, "version = \"echo notes > notes.txt && git add notes.txt && exit 3\"\n")
+	before := gitOutput(t, "rev-parse", "HEAD")
+	manifest, err := os.ReadFile("droy.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bumpVersion("minor"); err == nil {
+		t.Fatal("bumpVersion succeeded although the version script failed")
+	}
+
+	data, err := os.ReadFile("droy.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(manifest) {
+		t.Errorf("droy.toml after the failed bump:\n%s\nwant it unchanged", data)
+	}
+	if _, err := os.Stat("droy.lock"); !os.IsNotExist(err) {
+		t.Errorf("droy.lock was created: %v", err)
+	}
+	if got := gitOutput(t, "rev-parse", "HEAD"); got != before {
+		t.Errorf("HEAD moved from %s to %s", before, got)
+	}
+	if got := gitOutput(t, "tag"); got != "" {
+		t.Errorf("tags = %q, want none", got)
+	}
+
+	repo, err := vcs.Open(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirty, err := repo.Dirty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirty) > 0 {
+		t.Errorf("working tree is dirty after the rollback: %v", dirty)
+	}
+}
+
+func TestBumpVersionRestoresLockWithoutGit(t *testing.T) {
+	useGitProject(t, "version = \"exit 1\"\n")
+	versionNoGit = true
+
+	lock := "version = \"1.0.0\"\n"
+	if err := os.WriteFile("droy.lock", []byte(lock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bumpVersion("2.0.0"); err == nil {
+		t.Fatal("bumpVersion succeeded although the version script failed")
+	}
+
+	data, err := os.ReadFile("droy.lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != lock {
+		t.Errorf("droy.lock after the failed bump:\n%s\nwant it unchanged", data)
+	}
+}
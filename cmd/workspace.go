@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/droy-go/droy-pm/internal/logger"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/installer"
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/resolver"
+	"github.com/droy-go/droy-pm/pkg/workspace"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	workspaceAll    bool
+	workspaceFilter []string
+)
+
+// addWorkspaceFlags adds --workspace and --filter to a command that can
+// run for workspace members
+func addWorkspaceFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&workspaceAll, "workspace", false, "Run for every workspace member")
+	cmd.Flags().StringArrayVarP(&workspaceFilter, "filter", "F", nil, "Run for workspace members whose name or directory matches a glob (repeatable)")
+}
+
+// workspaceMode reports whether --workspace or --filter was given
+func workspaceMode() bool {
+	return workspaceAll || len(workspaceFilter) > 0
+}
+
+// findWorkspace returns the workspace the current directory belongs to,
+// or nil if there is none
+func findWorkspace() (*workspace.Workspace, error) {
+	ws, err := workspace.Find(".")
+	if errors.Is(err, workspace.ErrNoWorkspace) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	return ws, nil
+}
+
+// selectedMembers returns the workspace and the members --filter selects,
+// or every member with --workspace
+func selectedMembers() (*workspace.Workspace, []*workspace.Member, error) {
+	ws, err := workspace.Find(".")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members, err := ws.Filter(workspaceFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(members) == 0 {
+		return nil, nil, fmt.Errorf("the workspace has no members")
+	}
+	return ws, members, nil
+}
+
+// forEachMember runs fn in the directory of each selected member, members
+// before the members that depend on them, and returns whether it succeeded
+// for all of them
+func forEachMember(fn func(m *workspace.Member) bool) bool {
+	ws, members, err := selectedMembers()
+	if err != nil {
+		logger.Error("%v", err)
+		return false
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		logger.Error("%v", err)
+		return false
+	}
+	defer os.Chdir(cwd)
+
+	var failed []string
+	for _, m := range members {
+		color.Cyan("\n▸ %s (%s)", m.Name(), m.Dir)
+
+		if err := os.Chdir(ws.Path(m)); err != nil {
+			logger.Error("%v", err)
+			failed = append(failed, m.Name())
+			continue
+		}
+		if !fn(m) {
+			failed = append(failed, m.Name())
+		}
+	}
+
+	fmt.Println()
+	if len(failed) > 0 {
+		logger.Error("Failed for %d of %d workspace members: %s", len(failed), len(members), strings.Join(failed, ", "))
+		return false
+	}
+	logger.Success("Done for %d workspace members", len(members))
+	return true
+}
+
+// projectDependencies returns what droy.lock covers for a project: its
+// dependencies and dev dependencies or, at a workspace root, those of the
+// root and every member. local holds the workspace members, if any
+func projectDependencies(pkg *config.Package) (deps map[string]string, local map[string]*config.Package, err error) {
+	if pkg.Workspace == nil {
+		return allDependencies(pkg), nil, nil
+	}
+
+	ws, err := workspace.Load(".")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	return ws.Dependencies(), ws.Local(), nil
+}
+
+// enterWorkspace changes to the root of the workspace containing the
+// current directory, where everything is installed, and returns it. It
+// returns nil outside a workspace
+func enterWorkspace() (*workspace.Workspace, error) {
+	ws, err := findWorkspace()
+	if err != nil || ws == nil {
+		return nil, err
+	}
+	if err := os.Chdir(ws.Root); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// installWorkspace installs the dependencies of a workspace into the
+// droy_modules at its root, recording them in the root droy.lock, and
+// links the members into it. With --filter only what the selected members
+// need is installed. It must run at the workspace root
+func installWorkspace(inst *installer.Installer, ws *workspace.Workspace) error {
+	members, err := ws.Filter(workspaceFilter)
+	if err != nil {
+		return err
+	}
+
+	name := ws.Package.Name
+	if name == "" {
+		name = filepath.Base(ws.Root)
+	}
+	logger.Info("Installing dependencies for workspace '%s' (%d members)...", name, len(ws.Members))
+
+	lock, err := lockForInstall(ws.Package, ws.Dependencies(), ws.Local())
+	if err != nil {
+		return err
+	}
+
+	links := make(map[string]string)
+	for _, m := range ws.Members {
+		if entry := lock.Packages[m.Name()]; entry != nil {
+			entry.Resolved = workspaceResolved(m)
+			entry.Integrity = ""
+		}
+		links[m.Name()] = ws.Path(m)
+	}
+
+	wanted := make(map[string]string)
+	if len(workspaceFilter) == 0 {
+		for name, spec := range ws.Package.Dependencies {
+			wanted[name] = spec
+		}
+		if installDev {
+			for name, spec := range ws.Package.DevDependencies {
+				wanted[name] = spec
+			}
+		}
+	}
+	for _, m := range members {
+		wanted[m.Name()] = m.Package.Version
+	}
+
+	return installLocked(inst, lock, resolver.LockedVersions(lock, wanted), links, true)
+}
+
+// workspaceResolved is what droy.lock records as the location of a member
+func workspaceResolved(m *workspace.Member) string {
+	return "file:" + m.Dir
+}
+
+// installWorkspacePackage adds a package to the members selected with
+// --workspace or --filter, to the member containing the current directory
+// or else to the root droy.toml, then installs the workspace. The
+// manifests are restored if the install fails
+func installWorkspacePackage(ws *workspace.Workspace, spec string) error {
+	name, version := parsePackageSpec(spec)
+
+	if m := ws.Member(name); m != nil {
+		version = m.Package.Version
+	} else if !strings.HasPrefix(name, "github.com/") && registry.IsTag(version) {
+		tagged, err := newResolver().Version(name, version)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s@%s: %w", name, version, err)
+		}
+		version = tagged
+	}
+
+	if !installSave {
+		logger.Warning("--save=false is ignored in a workspace; packages are always added to droy.toml")
+	}
+
+	targets, err := workspaceTargets(ws)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chdir(ws.Root); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(targets))
+	for path, pkg := range targets {
+		if pkg.Name == name {
+			return fmt.Errorf("%s cannot depend on itself", name)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// The manifests are edited inside the transaction so that a failed
+	// install puts them back too
+	return inTransaction(paths, func(inst *installer.Installer) error {
+		for _, path := range paths {
+			pkg := targets[path]
+			if installDev {
+				pkg.DevDependencies[name] = savedSpec(name, version)
+			} else {
+				pkg.Dependencies[name] = savedSpec(name, version)
+			}
+			if err := config.WritePackageConfig(pkg, path); err != nil {
+				return fmt.Errorf("failed to update %s: %w", path, err)
+			}
+			logger.Info("Added %s@%s to %s", name, savedSpec(name, version), path)
+		}
+		return installWorkspace(inst, ws)
+	})
+}
+
+// workspaceTargets returns the droy.toml files, keyed by absolute path,
+// that install <package> adds to in a workspace
+func workspaceTargets(ws *workspace.Workspace) (map[string]*config.Package, error) {
+	targets := make(map[string]*config.Package)
+
+	if workspaceMode() {
+		members, err := ws.Filter(workspaceFilter)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			targets[filepath.Join(ws.Path(m), "droy.toml")] = m.Package
+		}
+		return targets, nil
+	}
+
+	cwd, err := filepath.Abs(".")
+	if err != nil {
+		return nil, err
+	}
+	if m := ws.MemberAt(cwd); m != nil {
+		targets[filepath.Join(ws.Path(m), "droy.toml")] = m.Package
+		return targets, nil
+	}
+
+	targets[filepath.Join(ws.Root, "droy.toml")] = ws.Package
+	return targets, nil
+}
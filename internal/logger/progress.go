@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// MultiProgress renders progress for several tasks running at once: one
+// line per task in flight below an overall progress bar. On a terminal the
+// block is redrawn in place; otherwise only finished tasks are printed.
+// All methods are safe for concurrent use.
+type MultiProgress struct {
+	mu     sync.Mutex
+	out    io.Writer
+	tty    bool
+	total  int
+	done   int
+	active map[string]string
+	drawn  int
+}
+
+// NewMultiProgress creates a renderer for total tasks writing to stdout
+func NewMultiProgress(total int) *MultiProgress {
+	return &MultiProgress{
+		out:    os.Stdout,
+		tty:    isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()),
+		total:  total,
+		active: make(map[string]string),
+	}
+}
+
+// Update sets the status line of a task
+func (p *MultiProgress) Update(task, status string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.active[task] = status
+	p.redraw()
+}
+
+// Finish removes a task from the live block and prints its outcome
+func (p *MultiProgress) Finish(task string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.active, task)
+	p.done++
+
+	p.clear()
+	if err != nil {
+		fmt.Fprintf(p.out, "%s %s: %v\n", color.RedString("✗"), task, err)
+	} else {
+		fmt.Fprintf(p.out, "%s %s\n", color.GreenString("✓"), task)
+	}
+	p.redraw()
+}
+
+// Stop clears the live block. Nothing is drawn after Stop
+func (p *MultiProgress) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	p.tty = false
+}
+
+// clear erases the previously drawn block
+func (p *MultiProgress) clear() {
+	if !p.tty || p.drawn == 0 {
+		return
+	}
+	fmt.Fprintf(p.out, "\033[%dA", p.drawn)
+	for n := 0; n < p.drawn; n++ {
+		fmt.Fprint(p.out, "\033[2K\n")
+	}
+	fmt.Fprintf(p.out, "\033[%dA", p.drawn)
+	p.drawn = 0
+}
+
+func (p *MultiProgress) redraw() {
+	if !p.tty {
+		return
+	}
+	p.clear()
+
+	percent := 100.0
+	if p.total > 0 {
+		percent = float64(p.done) / float64(p.total) * 100
+	}
+	fmt.Fprintf(p.out, "%s [%s] %d/%d\n", color.CyanString("→"), renderProgressBar(percent), p.done, p.total)
+
+	tasks := make([]string, 0, len(p.active))
+	for task := range p.active {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	for _, task := range tasks {
+		fmt.Fprintf(p.out, "  %s %s\n", task, color.New(color.Faint).Sprint(strings.TrimSpace(p.active[task])))
+	}
+
+	p.drawn = len(tasks) + 1
+}
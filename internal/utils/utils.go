@@ -2,12 +2,18 @@ package utils
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/droy-go/droy-pm/pkg/semver"
 )
 
 // FileExists checks if a file exists
@@ -91,6 +97,86 @@ func CalculateSHA256(path string) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
+// ErrIntegrityMismatch is returned when a file does not match its
+// expected integrity hash
+var ErrIntegrityMismatch = errors.New("integrity mismatch")
+
+// CalculateIntegrity calculates the SHA-512 Subresource Integrity string
+// ("sha512-<base64>") of a file
+func CalculateIntegrity(path string) (string, error) {
+	return calculateIntegrity(path, "sha512")
+}
+
+// VerifyIntegrity checks a file against a Subresource Integrity string such
+// as "sha512-<base64>" or "sha256-<base64>". Several space-separated hashes
+// may be given, in which case the file must match one of them
+func VerifyIntegrity(path, integrity string) error {
+	var actual []string
+	for _, expected := range strings.Fields(integrity) {
+		algo, _, ok := strings.Cut(expected, "-")
+		if !ok {
+			return fmt.Errorf("invalid integrity %q", expected)
+		}
+
+		got, err := calculateIntegrity(path, algo)
+		if err != nil {
+			return err
+		}
+		if got == expected {
+			return nil
+		}
+		actual = append(actual, got)
+	}
+
+	if len(actual) == 0 {
+		return fmt.Errorf("invalid integrity %q", integrity)
+	}
+
+	return fmt.Errorf("%w: expected %s, got %s", ErrIntegrityMismatch, integrity, strings.Join(actual, " "))
+}
+
+// IntegrityFromShasum converts a hex SHA-256 or SHA-512 digest to a
+// Subresource Integrity string. Returns "" for other digests
+func IntegrityFromShasum(shasum string) string {
+	digest, err := hex.DecodeString(shasum)
+	if err != nil {
+		return ""
+	}
+
+	switch len(digest) {
+	case sha256.Size:
+		return "sha256-" + base64.StdEncoding.EncodeToString(digest)
+	case sha512.Size:
+		return "sha512-" + base64.StdEncoding.EncodeToString(digest)
+	default:
+		return ""
+	}
+}
+
+func calculateIntegrity(path, algo string) (string, error) {
+	var h hash.Hash
+	switch algo {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported integrity algorithm %q", algo)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return algo + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
 // SanitizePackageName sanitizes a package name
 func SanitizePackageName(name string) string {
 	// Replace invalid characters
@@ -151,15 +237,10 @@ func GetGlobalDir() string {
 // VersionCompare compares two version strings
 // Returns -1 if v1 < v2, 0 if v1 == v2, 1 if v1 > v2
 func VersionCompare(v1, v2 string) int {
-	// Simple version comparison
-	// In a real implementation, this would handle semver properly
-	if v1 == v2 {
-		return 0
-	}
-	
-	// For now, just do string comparison
-	if v1 < v2 {
-		return -1
+	if cmp, err := semver.Compare(v1, v2); err == nil {
+		return cmp
 	}
-	return 1
+
+	// Fall back to string comparison for non-semver versions (e.g. git refs)
+	return strings.Compare(v1, v2)
 }
@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/semver"
+)
+
+// Severity ranks how serious a vulnerability is
+type Severity int
+
+// Severities from least to most serious
+const (
+	Low Severity = iota
+	Moderate
+	High
+	Critical
+)
+
+var severityNames = []string{"low", "moderate", "high", "critical"}
+
+// String returns the severity's name
+func (s Severity) String() string {
+	if s < Low || s > Critical {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+// MarshalText encodes the severity as its name, for JSON reports
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses a severity name such as "high"
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return Low, fmt.Errorf("invalid severity %q, expected one of %s", name, strings.Join(severityNames, ", "))
+}
+
+// Advisories holds the advisories known for each package
+type Advisories map[string][]registry.Advisory
+
+// Merge adds advisories from another source, skipping those already known
+// by ID
+func (a Advisories) Merge(other map[string][]registry.Advisory) {
+	for name, list := range other {
+		for _, adv := range list {
+			if !a.has(name, adv.ID) {
+				adv.Package = name
+				a[name] = append(a[name], adv)
+			}
+		}
+	}
+}
+
+func (a Advisories) has(name, id string) bool {
+	for _, adv := range a[name] {
+		if id != "" && adv.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Affecting returns the advisories that affect a package version.
+// Prereleases within a vulnerable range are affected too, and a version
+// or advisory range that cannot be parsed is an error rather than a clean
+// result
+func (a Advisories) Affecting(name, version string) ([]registry.Advisory, error) {
+	if len(a[name]) == 0 {
+		return nil, nil
+	}
+	v, err := semver.Parse(version)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check %s@%s against its advisories: %w", name, version, err)
+	}
+
+	var result []registry.Advisory
+	for _, adv := range a[name] {
+		rng, err := semver.ParseRange(adv.VulnerableVersions)
+		if err != nil {
+			return nil, fmt.Errorf("advisory %s for %s has an invalid vulnerable range: %w", adv.ID, name, err)
+		}
+		if rng.ContainsPrerelease(v) {
+			result = append(result, adv)
+		}
+	}
+	return result, nil
+}
+
+// ReadFile reads advisories from a JSON file in the format of the
+// registry's bulk endpoint: an object mapping package names to lists of
+// advisories
+func ReadFile(path string) (Advisories, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string][]registry.Advisory
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	advisories := make(Advisories)
+	advisories.Merge(raw)
+	return advisories, nil
+}
+
+// Finding is an advisory affecting an installed package version
+type Finding struct {
+	Name     string            `json:"package"`
+	Version  string            `json:"version"`
+	Severity Severity          `json:"severity"`
+	Advisory registry.Advisory `json:"advisory"`
+
+	// Path is the shortest chain of dependencies from the project to the
+	// package, starting with a direct dependency
+	Path []string `json:"path"`
+}
+
+// Report is the result of an audit
+type Report struct {
+	Findings []Finding `json:"findings"`
+
+	// Packages is the number of packages audited
+	Packages int `json:"packages"`
+}
+
+// Count returns the number of findings at or above a severity
+func (r *Report) Count(min Severity) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity >= min {
+			n++
+		}
+	}
+	return n
+}
+
+// Vulnerable returns the names of the packages with findings, sorted
+func (r *Report) Vulnerable() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, f := range r.Findings {
+		if !seen[f.Name] {
+			seen[f.Name] = true
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions returns the registry packages reachable from deps in a lock
+// file, mapped to their locked version, as the advisory endpoint expects.
+// GitHub packages and local workspace members are left out
+func Versions(lock *config.LockFile, deps map[string]string) map[string][]string {
+	versions := make(map[string][]string)
+	for name := range paths(lock, deps) {
+		if !strings.HasPrefix(name, "github.com/") && !strings.HasPrefix(lock.Packages[name].Resolved, "file:") {
+			versions[name] = []string{lock.Packages[name].Version}
+		}
+	}
+	return versions
+}
+
+// Check matches the packages reachable from deps in a lock file against
+// advisories. Findings are sorted by severity, most serious first, then
+// by package name
+func Check(lock *config.LockFile, deps map[string]string, advisories Advisories) (*Report, error) {
+	report := &Report{Findings: []Finding{}}
+
+	for name, path := range paths(lock, deps) {
+		report.Packages++
+		version := lock.Packages[name].Version
+
+		affecting, err := advisories.Affecting(name, version)
+		if err != nil {
+			return nil, err
+		}
+		for _, adv := range affecting {
+			// Unknown severities are treated as the most serious
+			severity, err := ParseSeverity(adv.Severity)
+			if err != nil {
+				severity = Critical
+			}
+			report.Findings = append(report.Findings, Finding{
+				Name:     name,
+				Version:  version,
+				Advisory: adv,
+				Severity: severity,
+				Path:     path,
+			})
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Advisory.ID < b.Advisory.ID
+	})
+	return report, nil
+}
+
+// paths finds the shortest dependency path to every locked package
+// reachable from deps
+func paths(lock *config.LockFile, deps map[string]string) map[string][]string {
+	result := make(map[string][]string)
+
+	var queue []string
+	for _, name := range sortedKeys(deps) {
+		if _, ok := lock.Packages[name]; ok && result[name] == nil {
+			result[name] = []string{name}
+			queue = append(queue, name)
+		}
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		for _, dep := range sortedKeys(lock.Packages[name].Dependencies) {
+			if _, ok := lock.Packages[dep]; !ok || result[dep] != nil {
+				continue
+			}
+			result[dep] = append(append([]string{}, result[name]...), dep)
+			queue = append(queue, dep)
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,53 @@
+package audit
+
+import "testing"
+
+func TestAffecting(t *testing.T) {
+	advisories := Advisories{"lib": {{ID: "DROY-1", VulnerableVersions: "<2.0.0"}}}
+
+	tests := []struct {
+		version  string
+		affected bool
+	}{
+		{"1.5.0", true},
+		{"1.5.0-beta", true},
+		{"2.0.0-rc.1", true},
+		{"2.0.0", false},
+		{"2.1.0-beta", false},
+	}
+	for _, tt := range tests {
+		got, err := advisories.Affecting("lib", tt.version)
+		if err != nil {
+			t.Fatalf("Affecting(%s): %v", tt.version, err)
+		}
+		if (len(got) > 0) != tt.affected {
+			t.Errorf("Affecting(%s) = %v, want affected %v", tt.version, got, tt.affected)
+		}
+	}
+}
+
+func TestAffectingInvalidRange(t *testing.T) {
+	advisories := Advisories{"lib": {
+		{ID: "DROY-1", VulnerableVersions: "<2.0.0"},
+		{ID: "DROY-2", VulnerableVersions: "before 1.4"},
+	}}
+
+	if got, err := advisories.Affecting("lib", "3.0.0"); err == nil {
+		t.Errorf("Affecting = %v, want an error for DROY-2's range", got)
+	}
+	if _, err := (Advisories{"other": advisories["lib"]}).Affecting("lib", "3.0.0"); err != nil {
+		t.Errorf("advisories for other packages are checked: %v", err)
+	}
+}
+
+func TestAffectingInvalidVersion(t *testing.T) {
+	advisories := Advisories{"lib": {{ID: "DROY-1", VulnerableVersions: "<2.0.0"}}}
+
+	if got, err := advisories.Affecting("lib", "main"); err == nil {
+		t.Errorf("Affecting(main) = %v, want an error instead of a clean result", got)
+	}
+	// Packages without advisories, such as GitHub refs, have nothing to check
+	if _, err := advisories.Affecting("github.com/acme/lib", "main"); err != nil {
+		t.Errorf("Affecting for a package without advisories: %v", err)
+	}
+}
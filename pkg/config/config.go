@@ -1,8 +1,10 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"regexp"
 
 	"github.com/BurntSushi/toml"
 )
@@ -20,6 +22,7 @@ type Package struct {
 	DroyVersion     string            `toml:"droy_version,omitempty"`
 	Main            string            `toml:"main,omitempty"`
 	Bin             map[string]string `toml:"bin,omitempty"`
+	Files           []string          `toml:"files,omitempty"`
 	Scripts         map[string]string `toml:"scripts,omitempty"`
 	Dependencies    map[string]string `toml:"dependencies,omitempty"`
 	DevDependencies map[string]string `toml:"devDependencies,omitempty"`
@@ -29,6 +32,7 @@ type Package struct {
 	CPU             []string          `toml:"cpu,omitempty"`
 	Private         bool              `toml:"private,omitempty"`
 	PublishConfig   *PublishConfig    `toml:"publishConfig,omitempty"`
+	Workspace       *Workspace        `toml:"workspace,omitempty"`
 }
 
 // PublishConfig contains publishing configuration
@@ -38,7 +42,20 @@ type PublishConfig struct {
 	Tag         string `toml:"tag,omitempty"`
 }
 
-// LockFile represents the lock file structure
+// Workspace lists the member packages of a monorepo
+type Workspace struct {
+	// Members are directories relative to the workspace root, and may be
+	// globs such as "packages/*"
+	Members []string `toml:"members"`
+}
+
+// LockfileVersion is the version of the lock file format written by droy-pm
+const LockfileVersion = 1
+
+// LockFile represents the lock file structure.
+// Dependencies maps the project's direct dependencies to their locked
+// versions; Packages holds every package in the graph, including
+// transitive ones
 type LockFile struct {
 	Version      string            `toml:"version"`
 	LockfileVersion int           `toml:"lockfileVersion"`
@@ -61,6 +78,11 @@ func ReadPackageConfig(path string) (*Package, error) {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	return ParsePackageConfig(data)
+}
+
+// ParsePackageConfig parses a package configuration from TOML data
+func ParsePackageConfig(data []byte) (*Package, error) {
 	var pkg Package
 	if err := toml.Unmarshal(data, &pkg); err != nil {
 		return nil, fmt.Errorf("failed to parse TOML: %w", err)
@@ -108,6 +130,44 @@ func WritePackageConfig(pkg *Package, path string) error {
 	return nil
 }
 
+var (
+	// topLevelVersion matches a version key and its string value
+	topLevelVersion = regexp.MustCompile(`(?m)^(\s*version\s*=\s*)("[^"\n]*"|'[^'\n]*')`)
+
+	// tableHeader matches a [table] or [[array]] header
+	tableHeader = regexp.MustCompile(`(?m)^\s*\[`)
+)
+
+// SetPackageVersion changes the version in a droy.toml file in place,
+// keeping its comments and layout, unlike WritePackageConfig
+func SetPackageVersion(path, version string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// Only the part before the first table header belongs to the package
+	head := data
+	if loc := tableHeader.FindIndex(data); loc != nil {
+		head = data[:loc[0]]
+	}
+
+	loc := topLevelVersion.FindSubmatchIndex(head)
+	if loc == nil {
+		return fmt.Errorf("no version in %s", path)
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:loc[4]])
+	fmt.Fprintf(&out, "%q", version)
+	out.Write(data[loc[5]:])
+
+	if _, err := ParsePackageConfig(out.Bytes()); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
 // ReadLockFile reads a lock file
 func ReadLockFile(path string) (*LockFile, error) {
 	data, err := os.ReadFile(path)
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// TokenEnv names the environment variable that overrides the stored token
+// of the default registry
+const TokenEnv = "DROY_TOKEN"
+
+// Credentials holds registry tokens, keyed by registry host
+type Credentials struct {
+	Registries map[string]*Credential `toml:"registries"`
+}
+
+// Credential is the token stored for one registry
+type Credential struct {
+	Token    string `toml:"token"`
+	Username string `toml:"username,omitempty"`
+}
+
+// CredentialsPath returns the location of the credentials file,
+// ~/.droy/credentials
+func CredentialsPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".droy", "credentials")
+	}
+	return filepath.Join(homeDir, ".droy", "credentials")
+}
+
+// ReadCredentials reads a credentials file. A missing file yields empty
+// credentials
+func ReadCredentials(path string) (*Credentials, error) {
+	creds := &Credentials{Registries: make(map[string]*Credential)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if err := toml.Unmarshal(data, creds); err != nil {
+		return nil, fmt.Errorf("failed to parse TOML: %w", err)
+	}
+	if creds.Registries == nil {
+		creds.Registries = make(map[string]*Credential)
+	}
+
+	return creds, nil
+}
+
+// WriteCredentials writes a credentials file readable only by its owner
+func WriteCredentials(creds *Credentials, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	fmt.Fprintln(file, "# Droy registry credentials")
+	fmt.Fprintln(file, "# Keep this file private.")
+	fmt.Fprintln(file)
+
+	if err := toml.NewEncoder(file).Encode(creds); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to encode TOML: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Tighten permissions of files created by older versions too
+	if err := os.Chmod(tmp, 0600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get returns the credential stored for a registry URL or host
+func (c *Credentials) Get(registry string) *Credential {
+	return c.Registries[RegistryHost(registry)]
+}
+
+// Set stores a credential for a registry URL or host
+func (c *Credentials) Set(registry string, cred *Credential) {
+	c.Registries[RegistryHost(registry)] = cred
+}
+
+// Delete removes the credential of a registry URL or host and reports
+// whether there was one
+func (c *Credentials) Delete(registry string) bool {
+	host := RegistryHost(registry)
+	_, ok := c.Registries[host]
+	delete(c.Registries, host)
+	return ok
+}
+
+// RegistryToken returns the token to send to a registry: DROY_TOKEN if it
+// is set and the registry is on the host of defaultRegistry, otherwise the
+// token stored in the credentials file. DROY_TOKEN holds a single token, so
+// it is never sent to scoped or other registries. defaultRegistry must come
+// from the user, not from a project, or any project could collect the token
+func RegistryToken(registry, defaultRegistry string) string {
+	if token := strings.TrimSpace(os.Getenv(TokenEnv)); token != "" && RegistryHost(registry) == RegistryHost(defaultRegistry) {
+		return token
+	}
+
+	creds, err := ReadCredentials(CredentialsPath())
+	if err != nil {
+		return ""
+	}
+	if cred := creds.Get(registry); cred != nil {
+		return cred.Token
+	}
+	return ""
+}
+
+// RegistryHost returns the host credentials for a registry URL are stored
+// under, e.g. "registry.droy-lang.org" for "https://registry.droy-lang.org/"
+func RegistryHost(registry string) string {
+	if u, err := url.Parse(registry); err == nil && u.Host != "" {
+		return strings.ToLower(u.Host)
+	}
+	return strings.ToLower(strings.TrimSuffix(registry, "/"))
+}
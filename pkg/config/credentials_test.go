@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialsAreKeyedByHost(t *testing.T) {
+	creds := &Credentials{Registries: make(map[string]*Credential)}
+	creds.Set("https://Registry.Example.com/", &Credential{Token: "example"})
+	creds.Set("https://droy.acme.internal", &Credential{Token: "acme"})
+
+	tests := []struct {
+		registry string
+		want     string
+	}{
+		{"https://registry.example.com", "example"},
+		{"http://registry.example.com/api", "example"},
+		{"registry.example.com", "example"},
+		{"https://droy.acme.internal/", "acme"},
+		{"https://other.example.com", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if cred := creds.Get(tt.registry); cred != nil {
+			got = cred.Token
+		}
+		if got != tt.want {
+			t.Errorf("Get(%q) = %q, want %q", tt.registry, got, tt.want)
+		}
+	}
+
+	if !creds.Delete("https://droy.acme.internal") || creds.Get("droy.acme.internal") != nil {
+		t.Error("Delete did not remove the acme token")
+	}
+	if creds.Delete("https://droy.acme.internal") {
+		t.Error("Delete reported a token that was already removed")
+	}
+}
+
+func TestCredentialsFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".droy", "credentials")
+
+	creds := &Credentials{Registries: make(map[string]*Credential)}
+	creds.Set("https://registry.example.com", &Credential{Token: "secret", Username: "alice"})
+	if err := WriteCredentials(creds, path); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("credentials file mode = %v, want 0600", mode)
+	}
+
+	read, err := ReadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := read.Get("registry.example.com")
+	if cred == nil || cred.Token != "secret" || cred.Username != "alice" {
+		t.Errorf("read back %+v", cred)
+	}
+
+	missing, err := ReadCredentials(filepath.Join(t.TempDir(), "none"))
+	if err != nil || len(missing.Registries) != 0 {
+		t.Errorf("ReadCredentials of a missing file = %+v, %v, want empty credentials", missing, err)
+	}
+}
+
+func TestRegistryToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	creds := &Credentials{Registries: make(map[string]*Credential)}
+	creds.Set("https://registry.example.com", &Credential{Token: "stored"})
+	creds.Set("https://droy.acme.internal", &Credential{Token: "acme"})
+	if err := WriteCredentials(creds, CredentialsPath()); err != nil {
+		t.Fatal(err)
+	}
+
+	const defaultRegistry = "https://registry.example.com"
+	tests := []struct {
+		registry string
+		env      string
+		want     string
+	}{
+		{"https://registry.example.com", "", "stored"},
+		{"https://droy.acme.internal", "", "acme"},
+		{"https://other.example.com", "", ""},
+		// DROY_TOKEN wins for the default registry only
+		{"https://registry.example.com/", "from-env", "from-env"},
+		{"https://droy.acme.internal", "from-env", "acme"},
+		{"https://other.example.com", "from-env", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv(TokenEnv, tt.env)
+		if got := RegistryToken(tt.registry, defaultRegistry); got != tt.want {
+			t.Errorf("RegistryToken(%q) with DROY_TOKEN=%q = %q, want %q", tt.registry, tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ProjectSettingsFile is the name of the per-project settings file
+const ProjectSettingsFile = ".droyrc"
+
+// Setting kinds, used to validate values and to write them to TOML with
+// the right type
+const (
+	kindString   = "string"
+	kindPath     = "path"
+	kindModules  = "modules"
+	kindInt      = "int"
+	kindBool     = "bool"
+	kindDuration = "duration"
+	kindList     = "list"
+)
+
+// Setting describes a configuration key
+type Setting struct {
+	Key     string
+	Default string
+	Usage   string
+	kind    string
+	choices []string
+
+	// userOnly keys decide what is trusted or deleted, or where tokens are
+	// sent, so a cloned project's .droyrc must not set them
+	userOnly bool
+}
+
+// UserOnly reports whether the key may only be set in the user
+// configuration, the environment or a flag, not in a project's .droyrc
+func (s *Setting) UserOnly() bool {
+	return s.userOnly
+}
+
+// settingDefs lists every configuration key with its built-in default.
+// Paths may start with ~/, which is expanded to the home directory, except
+// modules-dir, which must be inside the project
+var settingDefs = []Setting{
+	{Key: "registry", Default: "https://registry.droy-lang.org", Usage: "Registry URL", kind: kindString, userOnly: true},
+	{Key: "modules-dir", Default: "droy_modules", Usage: "Directory packages are installed into, relative to the project", kind: kindModules},
+	{Key: "cache-dir", Default: "~/.droy/cache", Usage: "Directory for downloaded tarballs and registry metadata", kind: kindPath, userOnly: true},
+	{Key: "store-dir", Default: "~/.droy/store", Usage: "Directory of the content-addressable package store", kind: kindPath, userOnly: true},
+	{Key: "global-dir", Default: "~/.droy/global", Usage: "Directory of globally installed packages", kind: kindPath, userOnly: true},
+	{Key: "cache-ttl", Default: "5m", Usage: "How long cached registry metadata is used before revalidating", kind: kindDuration},
+	{Key: "concurrency", Default: "8", Usage: "Number of packages to install in parallel", kind: kindInt},
+	{Key: "offline", Default: "false", Usage: "Use only cached registry metadata and packages", kind: kindBool},
+	{Key: "prefer-offline", Default: "false", Usage: "Use cached registry metadata and packages when available", kind: kindBool},
+	{Key: "keys-dir", Default: "~/.droy/keys", Usage: "Directory of signing keys", kind: kindPath, userOnly: true},
+	{Key: "signing-key", Default: "", Usage: "Name of the key publish signs packages with", kind: kindString, userOnly: true},
+	{Key: "trusted-keys", Default: "", Usage: "Public keys trusted to sign packages, comma-separated", kind: kindList, userOnly: true},
+	{Key: "signature-policy", Default: "off", Usage: "Package signature check on install: off, warn or require", kind: kindString, choices: []string{"off", "warn", "require"}, userOnly: true},
+	{Key: "audit-level", Default: "low", Usage: "Lowest advisory severity that makes audit fail", kind: kindString, choices: []string{"low", "moderate", "high", "critical"}},
+	{Key: "advisory-file", Default: "", Usage: "Local JSON advisory database used by audit, e.g. when offline", kind: kindPath},
+	{Key: "license-allow", Default: "", Usage: "SPDX licenses dependencies may use, comma-separated; * matches a family", kind: kindList},
+	{Key: "license-deny", Default: "", Usage: "SPDX licenses dependencies must not use, comma-separated; * matches a family", kind: kindList},
+}
+
+// scopePattern matches a package scope such as "@acme"
+var scopePattern = regexp.MustCompile(`^@[a-z0-9][a-z0-9._-]*$`)
+
+// scopeRegistrySuffix ends the keys that map a scope to a registry, e.g.
+// "@acme:registry"
+const scopeRegistrySuffix = ":registry"
+
+// ValidScope reports whether scope is a valid package scope such as "@acme"
+func ValidScope(scope string) bool {
+	return scopePattern.MatchString(scope)
+}
+
+// ScopeRegistryKey returns the key that maps a scope to a registry
+func ScopeRegistryKey(scope string) string {
+	return scope + scopeRegistrySuffix
+}
+
+// LookupSetting returns the definition of a configuration key. Besides
+// the fixed keys, "@scope:registry" keys map a scope to a registry
+func LookupSetting(key string) (*Setting, bool) {
+	for i := range settingDefs {
+		if settingDefs[i].Key == key {
+			return &settingDefs[i], true
+		}
+	}
+
+	if scope, ok := strings.CutSuffix(key, scopeRegistrySuffix); ok && ValidScope(scope) {
+		return &Setting{Key: key, Usage: "Registry URL for " + scope + " packages", kind: kindString, userOnly: true}, true
+	}
+	return nil, false
+}
+
+// SettingKeys returns every configuration key in alphabetical order
+func SettingKeys() []string {
+	keys := make([]string, 0, len(settingDefs))
+	for _, def := range settingDefs {
+		keys = append(keys, def.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EnvName returns the environment variable that overrides a key,
+// e.g. DROY_MODULES_DIR for modules-dir
+func EnvName(key string) string {
+	return "DROY_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// ValidateSetting checks that a value is valid for a configuration key
+func ValidateSetting(key, value string) error {
+	def, ok := LookupSetting(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+
+	switch def.kind {
+	case kindInt:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%s must be a positive integer, got %q", key, value)
+		}
+	case kindBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", key, value)
+		}
+	case kindDuration:
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("%s must be a duration such as 10m, got %q", key, value)
+		}
+	case kindModules:
+		clean := filepath.ToSlash(filepath.Clean(value))
+		if value == "" || filepath.IsAbs(value) || strings.HasPrefix(value, "~") ||
+			clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("%s must be a directory inside the project, got %q", key, value)
+		}
+	case kindList:
+		for _, item := range splitList(value) {
+			if item == "" {
+				return fmt.Errorf("%s must not contain empty items", key)
+			}
+		}
+	default:
+		if value == "" {
+			return fmt.Errorf("%s must not be empty", key)
+		}
+	}
+
+	if len(def.choices) > 0 && !containsString(def.choices, value) {
+		return fmt.Errorf("%s must be one of %s, got %q", key, strings.Join(def.choices, ", "), value)
+	}
+	return nil
+}
+
+// Settings holds the effective configuration, built from layers that
+// override each other in this order: built-in defaults,
+// ~/.droy/config.toml, the project's .droyrc, DROY_* environment variables
+// and command-line flags. The .droyrc may not set user-only keys
+type Settings struct {
+	values  map[string]string
+	sources map[string]string
+}
+
+// DefaultSettings returns the built-in defaults
+func DefaultSettings() *Settings {
+	s := &Settings{
+		values:  make(map[string]string),
+		sources: make(map[string]string),
+	}
+	for _, def := range settingDefs {
+		s.values[def.Key] = def.Default
+		s.sources[def.Key] = "default"
+	}
+	return s
+}
+
+// LoadSettings reads every configuration layer except flags, which the
+// caller applies with Set
+func LoadSettings() (*Settings, error) {
+	s := DefaultSettings()
+
+	for _, path := range []string{UserSettingsPath(), ProjectSettingsFile} {
+		values, err := ReadSettingsFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for key, value := range values {
+			if def, ok := LookupSetting(key); ok && def.UserOnly() && path == ProjectSettingsFile {
+				return nil, fmt.Errorf("%s: %s can only be set in %s, the environment or a flag", path, key, UserSettingsPath())
+			}
+			if err := s.Set(key, value, path); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	for _, key := range SettingKeys() {
+		env := EnvName(key)
+		if value, ok := os.LookupEnv(env); ok && value != "" {
+			if err := s.Set(key, value, env); err != nil {
+				return nil, fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Set overrides a key, recording where the value came from
+func (s *Settings) Set(key, value, source string) error {
+	if err := ValidateSetting(key, value); err != nil {
+		return err
+	}
+	s.values[key] = value
+	s.sources[key] = source
+	return nil
+}
+
+// Keys returns every fixed key followed by the scope registry keys that
+// are set, in alphabetical order
+func (s *Settings) Keys() []string {
+	keys := SettingKeys()
+
+	var scoped []string
+	for key := range s.values {
+		if _, ok := LookupSetting(key); ok && strings.HasSuffix(key, scopeRegistrySuffix) {
+			scoped = append(scoped, key)
+		}
+	}
+	sort.Strings(scoped)
+
+	return append(keys, scoped...)
+}
+
+// ScopeRegistries returns the registry URL configured for each scope
+func (s *Settings) ScopeRegistries() map[string]string {
+	scopes := make(map[string]string)
+	for key, value := range s.values {
+		if scope, ok := strings.CutSuffix(key, scopeRegistrySuffix); ok && ValidScope(scope) {
+			scopes[scope] = value
+		}
+	}
+	return scopes
+}
+
+// Get returns the value of a key as written
+func (s *Settings) Get(key string) string {
+	return s.values[key]
+}
+
+// Source returns the layer a key's value came from: "default", a file
+// path, an environment variable or a flag
+func (s *Settings) Source(key string) string {
+	return s.sources[key]
+}
+
+// Path returns a path-valued key with a leading ~ expanded
+func (s *Settings) Path(key string) string {
+	return ExpandHome(s.values[key])
+}
+
+// Int returns an integer-valued key
+func (s *Settings) Int(key string) int {
+	n, _ := strconv.Atoi(s.values[key])
+	return n
+}
+
+// Bool returns a boolean-valued key
+func (s *Settings) Bool(key string) bool {
+	b, _ := strconv.ParseBool(s.values[key])
+	return b
+}
+
+// Duration returns a duration-valued key
+func (s *Settings) Duration(key string) time.Duration {
+	d, _ := time.ParseDuration(s.values[key])
+	return d
+}
+
+// List returns the items of a list-valued key
+func (s *Settings) List(key string) []string {
+	return splitList(s.values[key])
+}
+
+// splitList splits a comma-separated list, trimming spaces. An empty
+// string is an empty list
+func splitList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	items := strings.Split(value, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandHome replaces a leading ~ in a path with the home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+}
+
+// UserSettingsPath returns the location of the user configuration file,
+// ~/.droy/config.toml
+func UserSettingsPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".droy", "config.toml")
+	}
+	return filepath.Join(homeDir, ".droy", "config.toml")
+}
+
+// ReadSettingsFile reads the keys set in a configuration file. A missing
+// file sets nothing. Arrays of strings are read as comma-separated lists
+func ReadSettingsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse TOML: %w", err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string, int64, bool:
+			values[key] = fmt.Sprint(v)
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s must be an array of strings", key)
+				}
+				items[i] = s
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			return nil, fmt.Errorf("%s must be a string, number, boolean or array", key)
+		}
+	}
+	return values, nil
+}
+
+// WriteSettingsFile writes configuration keys to a file, encoding numbers,
+// booleans and lists as native TOML values
+func WriteSettingsFile(path string, values map[string]string) error {
+	raw := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		raw[key] = value
+
+		def, ok := LookupSetting(key)
+		if !ok {
+			continue
+		}
+		switch def.kind {
+		case kindInt:
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				raw[key] = n
+			}
+		case kindBool:
+			if b, err := strconv.ParseBool(value); err == nil {
+				raw[key] = b
+			}
+		case kindList:
+			raw[key] = splitList(value)
+		}
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	if err := toml.NewEncoder(file).Encode(raw); err != nil {
+		return fmt.Errorf("failed to encode TOML: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inProject runs the rest of a test in a project directory whose .droyrc
+// holds droyrc, with an empty home directory and no DROY_* variables
+func inProject(t *testing.T, droyrc string) {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	for _, key := range SettingKeys() {
+		t.Setenv(EnvName(key), "")
+	}
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	if droyrc != "" {
+		if err := os.WriteFile(ProjectSettingsFile, []byte(droyrc), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProjectSettingsCannotChooseRegistries(t *testing.T) {
+	for _, droyrc := range []string{
+		"registry = \"https://evil.example.com\"\n",
+		"\"@acme:registry\" = \"https://evil.example.com\"\n",
+	} {
+		inProject(t, droyrc)
+		_, err := LoadSettings()
+		if err == nil || !strings.Contains(err.Error(), "can only be set in") {
+			t.Errorf("LoadSettings with .droyrc %q = %v, want it rejected", droyrc, err)
+		}
+	}
+}
+
+func TestSettingsPrecedence(t *testing.T) {
+	inProject(t, "concurrency = 3\ncache-ttl = \"1m\"\n")
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := "concurrency = 2\ncache-ttl = \"2m\"\naudit-level = \"high\"\noffline = true\n"
+	if err := os.MkdirAll(filepath.Join(home, ".droy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(UserSettingsPath(), []byte(user), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DROY_CACHE_TTL", "3m")
+	t.Setenv("DROY_OFFLINE", "false")
+
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A flag is applied last, with Set
+	if err := s.Set("offline", "true", "--offline"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, value, source string
+	}{
+		{"license-deny", "", "default"},
+		{"audit-level", "high", UserSettingsPath()},
+		{"concurrency", "3", ProjectSettingsFile},
+		{"cache-ttl", "3m", "DROY_CACHE_TTL"},
+		{"offline", "true", "--offline"},
+	}
+	for _, tt := range tests {
+		if got := s.Get(tt.key); got != tt.value {
+			t.Errorf("%s = %q, want %q", tt.key, got, tt.value)
+		}
+		if got := s.Source(tt.key); got != tt.source {
+			t.Errorf("%s comes from %q, want %q", tt.key, got, tt.source)
+		}
+	}
+}
+
+func TestProjectSettingsRejectUserOnlyKeys(t *testing.T) {
+	values := map[string]string{
+		"registry":         "\"https://evil.example.com\"",
+		"cache-dir":        "\"/tmp/cache\"",
+		"store-dir":        "\"/tmp/store\"",
+		"global-dir":       "\"/tmp/global\"",
+		"keys-dir":         "\"/tmp/keys\"",
+		"signing-key":      "\"release\"",
+		"trusted-keys":     "\"ed25519:AAAA\"",
+		"signature-policy": "\"off\"",
+	}
+	for key, value := range values {
+		def, ok := LookupSetting(key)
+		if !ok || !def.UserOnly() {
+			t.Errorf("%s is not user-only", key)
+			continue
+		}
+
+		inProject(t, key+" = "+value+"\n")
+		if _, err := LoadSettings(); err == nil || !strings.Contains(err.Error(), key+" can only be set in") {
+			t.Errorf("LoadSettings with %s in .droyrc = %v, want it rejected", key, err)
+		}
+	}
+
+	// The same keys are fine in the user's own configuration
+	inProject(t, "")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".droy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var user strings.Builder
+	for key, value := range values {
+		user.WriteString(key + " = " + value + "\n")
+	}
+	if err := os.WriteFile(UserSettingsPath(), []byte(user.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings with user-only keys in %s: %v", UserSettingsPath(), err)
+	}
+	if got := s.Get("signing-key"); got != "release" {
+		t.Errorf("signing-key = %q, want release", got)
+	}
+}
+
+func TestProjectSettingsKeepModulesDirInProject(t *testing.T) {
+	for _, dir := range []string{"/tmp/elsewhere", "..", "../sibling", ".", "~/modules"} {
+		inProject(t, "modules-dir = \""+dir+"\"\n")
+		if _, err := LoadSettings(); err == nil {
+			t.Errorf("LoadSettings accepted modules-dir %q", dir)
+		}
+	}
+
+	inProject(t, "modules-dir = \"vendor/droy\"\n")
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get("modules-dir"); got != "vendor/droy" {
+		t.Errorf("modules-dir = %q, want vendor/droy", got)
+	}
+}
+
+func TestScopeRegistries(t *testing.T) {
+	s := DefaultSettings()
+	if err := s.Set("@acme:registry", "https://droy.acme.internal", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("@Acme:registry", "https://droy.acme.internal", "test"); err == nil {
+		t.Error("Set accepted a key for an invalid scope")
+	}
+
+	scopes := s.ScopeRegistries()
+	if len(scopes) != 1 || scopes["@acme"] != "https://droy.acme.internal" {
+		t.Errorf("ScopeRegistries() = %v, want only @acme", scopes)
+	}
+}
@@ -0,0 +1,299 @@
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Default limits for extracting a package tarball
+const (
+	DefaultMaxUnpackedSize = 512 << 20 // 512 MB
+	DefaultMaxFiles        = 20000
+)
+
+// packagePrefix is the top-level directory registry tarballs wrap their
+// contents in
+const packagePrefix = "package/"
+
+// extractTarball unpacks a gzipped package tarball into targetDir.
+//
+// Entries with absolute paths or ".." components, and links pointing outside
+// the package root, are rejected rather than skipped so that a malicious
+// package fails loudly. A leading "package/" directory is stripped.
+func (i *Installer) extractTarball(tarballPath, targetDir string) error {
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+
+	root, err := filepath.Abs(targetDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+
+	var unpacked int64
+	files := 0
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := entryPath(header.Name)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+
+		target := filepath.Join(root, name)
+
+		// Writing through a previously extracted symlink could land
+		// outside the package even when the entry name looks harmless
+		if err := checkParents(root, name); err != nil {
+			return fmt.Errorf("unsafe entry %q: %w", header.Name, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, dirMode(header.Mode)); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			files++
+			unpacked += header.Size
+			if err := i.checkLimits(files, unpacked); err != nil {
+				return err
+			}
+
+			if err := writeFile(target, tarReader, fileMode(header.Mode)); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			if err := checkSymlink(root, target, header.Linkname); err != nil {
+				return fmt.Errorf("unsafe entry %q: %w", header.Name, err)
+			}
+
+			files++
+			if err := i.checkLimits(files, unpacked); err != nil {
+				return err
+			}
+
+			if err := prepareTarget(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+
+		case tar.TypeLink:
+			linkName, err := entryPath(header.Linkname)
+			if err != nil || linkName == "" {
+				return fmt.Errorf("unsafe entry %q: hardlink to %q", header.Name, header.Linkname)
+			}
+
+			// The source is opened by path, so a symlinked parent
+			// would let it name a file outside the package
+			if err := checkParents(root, linkName); err != nil {
+				return fmt.Errorf("unsafe entry %q: hardlink to %q: %w", header.Name, header.Linkname, err)
+			}
+
+			source := filepath.Join(root, linkName)
+			info, err := os.Lstat(source)
+			if err != nil || !info.Mode().IsRegular() {
+				return fmt.Errorf("unsafe entry %q: hardlink target %q is not a file in the package", header.Name, header.Linkname)
+			}
+
+			files++
+			if err := i.checkLimits(files, unpacked); err != nil {
+				return err
+			}
+
+			if err := prepareTarget(target); err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+
+		default:
+			// Devices, FIFOs and other special files have no place in a
+			// package and are skipped
+		}
+	}
+
+	return nil
+}
+
+func (i *Installer) checkLimits(files int, unpacked int64) error {
+	if i.MaxFiles > 0 && files > i.MaxFiles {
+		return fmt.Errorf("package contains more than %d files", i.MaxFiles)
+	}
+	if i.MaxUnpackedSize > 0 && unpacked > i.MaxUnpackedSize {
+		return fmt.Errorf("package unpacks to more than %d bytes", i.MaxUnpackedSize)
+	}
+	return nil
+}
+
+// entryPath validates a tar entry name and returns it as a relative path
+// inside the package, or "" for the package root itself
+func entryPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("unsafe entry %q: absolute path", name)
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("unsafe entry %q: path escapes the package root", name)
+	}
+
+	if cleaned+"/" == packagePrefix {
+		return "", nil
+	}
+	cleaned = strings.TrimPrefix(cleaned, packagePrefix)
+
+	if cleaned == "." {
+		return "", nil
+	}
+
+	return filepath.FromSlash(cleaned), nil
+}
+
+// checkSymlink ensures a symlink created at target stays inside root.
+//
+// Comparing paths as text is only sound if every ".." steps out of a real
+// directory. A ".." after a name, as in "a/d/..", steps out of whatever
+// a/d is when the link is followed, which may be a symlink, possibly one a
+// later entry creates, so such targets are rejected. Leading ".." steps out
+// of the link's own directory, which checkParents has shown to be real
+func checkSymlink(root, target, linkname string) error {
+	if linkname == "" {
+		return fmt.Errorf("empty symlink target")
+	}
+	if path.IsAbs(linkname) || filepath.IsAbs(linkname) || filepath.VolumeName(linkname) != "" {
+		return fmt.Errorf("symlink to absolute path %q", linkname)
+	}
+
+	named := false
+	for _, part := range strings.Split(strings.ReplaceAll(linkname, "\\", "/"), "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if named {
+				return fmt.Errorf("symlink to %q has \"..\" after a directory name", linkname)
+			}
+		default:
+			named = true
+		}
+	}
+
+	resolved := filepath.Join(filepath.Dir(target), filepath.FromSlash(linkname))
+	if !withinDir(root, resolved) {
+		return fmt.Errorf("symlink to %q points outside the package", linkname)
+	}
+
+	return nil
+}
+
+// checkParents ensures no directory between root and the entry is a symlink
+func checkParents(root, name string) error {
+	dir := root
+	parts := strings.Split(filepath.Dir(name), string(filepath.Separator))
+	for _, part := range parts {
+		if part == "." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("path goes through symlink %q", part)
+		}
+	}
+	return nil
+}
+
+func withinDir(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// prepareTarget creates the parent directory of target and removes any
+// existing entry, so that a file never gets written through an old link
+func prepareTarget(target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := prepareTarget(target); err != nil {
+		return err
+	}
+
+	outFile, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(outFile, r); err != nil {
+		outFile.Close()
+		return err
+	}
+	if err := outFile.Close(); err != nil {
+		return err
+	}
+
+	// Apply the mode exactly, regardless of umask
+	return os.Chmod(target, mode)
+}
+
+// fileMode keeps the permission bits of a tar entry, dropping setuid,
+// setgid and sticky bits, and makes sure the owner can read and write it
+func fileMode(mode int64) os.FileMode {
+	return os.FileMode(mode)&os.ModePerm | 0600
+}
+
+func dirMode(mode int64) os.FileMode {
+	return os.FileMode(mode)&os.ModePerm | 0700
+}
@@ -0,0 +1,184 @@
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// entry is a tar entry for writeTarball; typeflag defaults to a regular file
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+}
+
+func writeTarball(t *testing.T, entries []entry) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "pkg.tgz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0644}
+		switch e.typeflag {
+		case 0:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(e.body))
+		case tar.TypeDir:
+			header.Mode = 0755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if e.body != "" {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractTarball(t *testing.T) {
+	tarball := writeTarball(t, []entry{
+		{name: "package/", typeflag: tar.TypeDir},
+		{name: "package/droy.toml", body: "name = \"pkg\"\n"},
+		{name: "package/lib/util.droy", body: "util"},
+		{name: "package/lib/current", typeflag: tar.TypeSymlink, linkname: "util.droy"},
+		{name: "package/docs/config", typeflag: tar.TypeSymlink, linkname: "../droy.toml"},
+		{name: "package/copy.droy", typeflag: tar.TypeLink, linkname: "package/lib/util.droy"},
+	})
+
+	dir := filepath.Join(t.TempDir(), "pkg")
+	if err := New(dir).extractTarball(tarball, dir); err != nil {
+		t.Fatalf("extractTarball: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"droy.toml":   "name = \"pkg\"\n",
+		"lib/current": "util",
+		"docs/config": "name = \"pkg\"\n",
+		"copy.droy":   "util",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("reading %s: %v", name, err)
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestExtractTarballRejectsEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		want    string
+	}{
+		{
+			name: "absolute path",
+			entries: []entry{
+				{name: "/etc/passwd", body: "x"},
+			},
+			want: "absolute path",
+		},
+		{
+			name: "dot-dot path",
+			entries: []entry{
+				{name: "package/../../evil", body: "x"},
+			},
+			want: "escapes the package root",
+		},
+		{
+			name: "symlink outside",
+			entries: []entry{
+				{name: "package/up", typeflag: tar.TypeSymlink, linkname: "../.."},
+			},
+			want: "points outside the package",
+		},
+		{
+			name: "absolute symlink",
+			entries: []entry{
+				{name: "package/etc", typeflag: tar.TypeSymlink, linkname: "/etc"},
+			},
+			want: "absolute path",
+		},
+		{
+			name: "dot-dot through an earlier symlink",
+			entries: []entry{
+				{name: "package/a/", typeflag: tar.TypeDir},
+				{name: "package/a/d", typeflag: tar.TypeSymlink, linkname: ".."},
+				{name: "package/x", typeflag: tar.TypeSymlink, linkname: "a/d/.."},
+			},
+			want: "after a directory name",
+		},
+		{
+			name: "dot-dot through a later symlink",
+			entries: []entry{
+				{name: "package/a/", typeflag: tar.TypeDir},
+				{name: "package/x", typeflag: tar.TypeSymlink, linkname: "a/d/.."},
+				{name: "package/a/d", typeflag: tar.TypeSymlink, linkname: ".."},
+			},
+			want: "after a directory name",
+		},
+		{
+			name: "file through a symlink",
+			entries: []entry{
+				{name: "package/sub/", typeflag: tar.TypeDir},
+				{name: "package/link", typeflag: tar.TypeSymlink, linkname: "sub"},
+				{name: "package/link/file", body: "x"},
+			},
+			want: "path goes through symlink",
+		},
+		{
+			name: "hardlink through a symlink",
+			entries: []entry{
+				{name: "package/sub/file", body: "x"},
+				{name: "package/link", typeflag: tar.TypeSymlink, linkname: "sub"},
+				{name: "package/copy", typeflag: tar.TypeLink, linkname: "package/link/file"},
+			},
+			want: "path goes through symlink",
+		},
+		{
+			name: "hardlink outside",
+			entries: []entry{
+				{name: "package/copy", typeflag: tar.TypeLink, linkname: "../../etc/passwd"},
+			},
+			want: "hardlink to",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tarball := writeTarball(t, tt.entries)
+			dir := filepath.Join(t.TempDir(), "pkg")
+
+			err := New(dir).extractTarball(tarball, dir)
+			if err == nil {
+				t.Fatal("extractTarball succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,199 +1,431 @@
 package installer
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/droy-go/droy-pm/internal/utils"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/signing"
+	"github.com/droy-go/droy-pm/pkg/store"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 // Installer handles package installation
 type Installer struct {
 	ModulesPath string
 	CachePath   string
+	Registry    *registry.Registry
+
+	// Store holds extracted registry packages shared between projects;
+	// nil extracts every package straight into ModulesPath
+	Store *store.Store
+
+	// Output receives git clone progress; nil keeps clones quiet
+	Output io.Writer
+
+	// Limits applied when extracting package tarballs
+	MaxUnpackedSize int64
+	MaxFiles        int
+
+	// Verifier checks registry packages' signatures; nil skips the check
+	Verifier *signing.Verifier
+
+	tx *Transaction
+
+	mu       sync.Mutex
+	warnings []string
 }
 
 // New creates a new installer
 func New(modulesPath string) *Installer {
-	homeDir, _ := os.UserHomeDir()
-	cachePath := filepath.Join(homeDir, ".droy", "cache")
-	
 	return &Installer{
 		ModulesPath: modulesPath,
-		CachePath:   cachePath,
+		CachePath:   utils.GetCacheDir(),
+		Registry:    registry.New(""),
+		Store:       store.Default(),
+		Output:      os.Stdout,
+
+		MaxUnpackedSize: DefaultMaxUnpackedSize,
+		MaxFiles:        DefaultMaxFiles,
 	}
 }
 
 // Install installs a package
 func (i *Installer) Install(name, version string) error {
+	_, err := i.InstallLocked(name, &config.LockPackage{Version: version})
+	return err
+}
+
+// InstallLocked installs a package as described by its droy.lock entry,
+// reusing the recorded source when present, and returns the entry for what
+// was actually installed
+func (i *Installer) InstallLocked(name string, locked *config.LockPackage) (*config.LockPackage, error) {
 	// Create modules directory if needed
 	if err := os.MkdirAll(i.ModulesPath, 0755); err != nil {
-		return fmt.Errorf("failed to create modules directory: %w", err)
+		return nil, fmt.Errorf("failed to create modules directory: %w", err)
 	}
 
 	// Determine package source
 	if strings.HasPrefix(name, "github.com/") {
-		return i.installFromGitHub(name, version)
+		return i.installFromGitHub(name, locked)
+	}
+	if _, _, err := registry.SplitName(name); err != nil {
+		return nil, err
 	}
 
-	return i.installFromRegistry(name, version)
+	return i.installFromRegistry(name, locked)
 }
 
 // Uninstall removes a package
 func (i *Installer) Uninstall(name string) error {
-	pkgPath := filepath.Join(i.ModulesPath, name)
+	pkgPath := i.PackageDir(name)
+	if i.tx != nil {
+		if err := i.tx.setAside(pkgPath); err != nil {
+			return fmt.Errorf("failed to remove package: %w", err)
+		}
+		return nil
+	}
+
 	if err := os.RemoveAll(pkgPath); err != nil {
 		return fmt.Errorf("failed to remove package: %w", err)
 	}
+
+	// Drop the scope directory along with its last package
+	if registry.Scope(name) != "" {
+		os.Remove(filepath.Dir(pkgPath))
+	}
 	return nil
 }
 
-func (i *Installer) installFromGitHub(repo, version string) error {
+// Link makes a local package, such as a workspace member, available in
+// the modules directory as a relative symlink to dir, replacing whatever
+// was installed under its name
+func (i *Installer) Link(name, dir string) error {
+	target, err := filepath.Abs(i.PackageDir(name))
+	if err != nil {
+		return err
+	}
+	source, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	linkname, err := filepath.Rel(filepath.Dir(target), source)
+	if err != nil {
+		return err
+	}
+
+	return i.stage(name, func(staged string) error {
+		if err := os.Symlink(linkname, staged); err != nil {
+			return fmt.Errorf("failed to link %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// stage fills a temporary directory next to the package's final location
+// and swaps it into place, so that a failed install never leaves a missing
+// or half-written package behind
+func (i *Installer) stage(name string, fill func(dir string) error) error {
+	staging, err := os.MkdirTemp(i.ModulesPath, ".staging-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	staged := filepath.Join(staging, "package")
+	if err := fill(staged); err != nil {
+		return err
+	}
+
+	return i.swap(i.PackageDir(name), staged, filepath.Join(staging, "previous"))
+}
+
+// swap replaces target with staged. The previous contents are moved to old,
+// or handed to the active transaction, and restored if the swap fails
+func (i *Installer) swap(target, staged, old string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	if i.tx != nil {
+		if err := i.tx.setAside(target); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", target, err)
+		}
+		return os.Rename(staged, target)
+	}
+
+	hadPrevious := false
+	if _, err := os.Lstat(target); err == nil {
+		if err := os.Rename(target, old); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", target, err)
+		}
+		hadPrevious = true
+	}
+
+	if err := os.Rename(staged, target); err != nil {
+		if hadPrevious {
+			os.Rename(old, target)
+		}
+		return err
+	}
+	return nil
+}
+
+func (i *Installer) installFromGitHub(repo string, locked *config.LockPackage) (*config.LockPackage, error) {
+	version := locked.Version
+
 	// Parse repository URL
 	parts := strings.Split(repo, "/")
 	if len(parts) < 3 {
-		return fmt.Errorf("invalid GitHub repository format")
+		return nil, fmt.Errorf("invalid GitHub repository format")
 	}
 
 	owner := parts[1]
 	repoName := parts[2]
-	
-	// Target directory
-	targetDir := filepath.Join(i.ModulesPath, repoName)
-	
-	// Remove existing installation
-	os.RemoveAll(targetDir)
+
+	if err := i.verifyUnsigned(repo, version); err != nil {
+		return nil, err
+	}
+
+	if i.Registry.Offline {
+		return nil, fmt.Errorf("cannot clone %s: %w", repo, registry.ErrNotCached)
+	}
 
 	// Clone repository
 	cloneURL := fmt.Sprintf("https://github.com/%s/%s.git", owner, repoName)
 	
 	cloneOptions := &git.CloneOptions{
 		URL:      cloneURL,
-		Progress: os.Stdout,
+		Progress: i.Output,
 	}
 
+	// A locked commit is checked out after a full clone
+	_, commit, _ := strings.Cut(locked.Resolved, "#")
+
 	// Checkout specific version if not latest
-	if version != "latest" && version != "*" {
-		cloneOptions.ReferenceName = "refs/tags/" + version
+	if commit == "" && version != "latest" && version != "*" {
+		cloneOptions.ReferenceName = plumbing.NewTagReferenceName(version)
 		cloneOptions.SingleBranch = true
 	}
 
-	_, err := git.PlainClone(targetDir, false, cloneOptions)
+	var head *plumbing.Reference
+	err := i.stage(repo, func(dir string) error {
+		repository, err := git.PlainClone(dir, false, cloneOptions)
+		if err != nil {
+			return fmt.Errorf("failed to clone repository: %w", err)
+		}
+
+		if commit != "" {
+			worktree, err := repository.Worktree()
+			if err != nil {
+				return fmt.Errorf("failed to open worktree: %w", err)
+			}
+			if err := worktree.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(commit)}); err != nil {
+				return fmt.Errorf("failed to checkout %s: %w", commit, err)
+			}
+		}
+
+		head, err = repository.Head()
+		if err != nil {
+			return fmt.Errorf("failed to read HEAD: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
+		return nil, err
 	}
 
-	return nil
+	return &config.LockPackage{
+		Version:  version,
+		Resolved: fmt.Sprintf("git+%s#%s", cloneURL, head.Hash()),
+	}, nil
 }
 
-func (i *Installer) installFromRegistry(name, version string) error {
-	// For now, assume registry returns a tarball URL
-	// In a real implementation, this would query the registry API
-	
-	tarballURL := fmt.Sprintf("https://registry.droy-lang.org/%s/-/"+name+"-%s.tgz", name, version)
-	
-	// Download tarball
+func (i *Installer) installFromRegistry(name string, locked *config.LockPackage) (*config.LockPackage, error) {
+	version := locked.Version
+	reg := i.Registry.ForPackage(name)
+
+	// The lock file is authoritative; otherwise the version's metadata
+	// says where the tarball lives and what it hashes to
+	tarballURL, expected := locked.Resolved, locked.Integrity
+	if tarballURL == "" || expected == "" {
+		info, err := reg.GetPackageVersion(name, version)
+		if err != nil {
+			return nil, err
+		}
+		dist, err := distribution(reg, name, version, info)
+		if err != nil {
+			return nil, err
+		}
+		if tarballURL == "" {
+			tarballURL = dist
+		}
+		if expected == "" {
+			expected = distIntegrity(info)
+		}
+	}
+
+	// Without a hash neither a download nor the cache can be checked, and
+	// whatever was installed would be locked as if it had been
+	if expected == "" {
+		return nil, fmt.Errorf("refusing to install %s@%s: the registry publishes no integrity hash for it", name, version)
+	}
+
+	// Download tarball; scoped packages are cached under their scope
 	tarballPath := filepath.Join(i.CachePath, fmt.Sprintf("%s-%s.tgz", name, version))
-	
-	if err := os.MkdirAll(i.CachePath, 0755); err != nil {
-		return fmt.Errorf("failed to create cache directory: %w", err)
+
+	if err := os.MkdirAll(filepath.Dir(tarballPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	// Check cache first
-	if _, err := os.Stat(tarballPath); os.IsNotExist(err) {
-		if err := downloadFile(tarballURL, tarballPath); err != nil {
-			return fmt.Errorf("failed to download package: %w", err)
+	// Packages already in the store need no tarball at all
+	if i.Store != nil && i.Store.Has(expected) {
+		if err := i.verifySignature(reg, name, version, expected); err != nil {
+			return nil, err
+		}
+		if err := i.unpack(name, expected, ""); err != nil {
+			return nil, err
 		}
+		return &config.LockPackage{
+			Version:   version,
+			Resolved:  tarballURL,
+			Integrity: expected,
+		}, nil
 	}
 
-	// Extract tarball
-	targetDir := filepath.Join(i.ModulesPath, name)
-	if err := extractTarball(tarballPath, targetDir); err != nil {
-		return fmt.Errorf("failed to extract package: %w", err)
+	// Check cache first, evicting entries that fail verification
+	if _, err := os.Stat(tarballPath); err == nil {
+		if err := utils.VerifyIntegrity(tarballPath, expected); err != nil {
+			os.Remove(tarballPath)
+		}
 	}
 
-	return nil
-}
+	if _, err := os.Stat(tarballPath); os.IsNotExist(err) {
+		if reg.Offline {
+			return nil, fmt.Errorf("%s@%s is not in the cache: %w", name, version, registry.ErrNotCached)
+		}
 
-func downloadFile(url, path string) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+		if err := downloadFile(reg, tarballURL, tarballPath); err != nil {
+			return nil, fmt.Errorf("failed to download package: %w", err)
+		}
+
+		if err := utils.VerifyIntegrity(tarballPath, expected); err != nil {
+			os.Remove(tarballPath)
+			return nil, fmt.Errorf("refusing to install %s@%s: %w", name, version, err)
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: %s", resp.Status)
+	if err := i.verifySignature(reg, name, version, expected); err != nil {
+		return nil, err
 	}
 
-	out, err := os.Create(path)
-	if err != nil {
-		return err
+	// Extract tarball
+	if err := i.unpack(name, expected, tarballPath); err != nil {
+		return nil, err
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return &config.LockPackage{
+		Version:   version,
+		Resolved:  tarballURL,
+		Integrity: expected,
+	}, nil
 }
 
-func extractTarball(tarballPath, targetDir string) error {
-	file, err := os.Open(tarballPath)
+// unpack installs a verified package tarball. With a store, the package is
+// extracted into the store once and linked into the modules directory
+func (i *Installer) unpack(name, integrity, tarballPath string) error {
+	extract := func(dir string) error {
+		if err := i.extractTarball(tarballPath, dir); err != nil {
+			return fmt.Errorf("failed to extract package: %w", err)
+		}
+		return nil
+	}
+
+	if i.Store == nil {
+		return i.stage(name, extract)
+	}
+
+	entry, err := i.Store.Add(integrity, extract)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	gzReader, err := gzip.NewReader(file)
+	err = i.stage(name, func(dir string) error {
+		_, err := store.Link(entry, dir)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	defer gzReader.Close()
 
-	tarReader := tar.NewReader(gzReader)
+	// Registration only matters to "store prune", so it never fails an install
+	i.Store.RegisterProject(i.ModulesPath)
+	return nil
+}
 
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return err
+// distribution returns the tarball URL of a package version from its
+// metadata
+func distribution(reg *registry.Registry, name, version string, info *registry.PackageInfo) (string, error) {
+	if info.Name == "" {
+		info.Name = name
 	}
+	if info.Version == "" {
+		info.Version = version
+	}
+	return reg.Tarball(info)
+}
 
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
+// distIntegrity returns the integrity the registry publishes for a
+// package version, or "" if it publishes none
+func distIntegrity(info *registry.PackageInfo) string {
+	if info.Dist == nil {
+		return ""
+	}
 
-		target := filepath.Join(targetDir, header.Name)
+	if info.Dist.Integrity != "" {
+		return info.Dist.Integrity
+	}
+	return utils.IntegrityFromShasum(info.Dist.Shasum)
+}
 
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0755); err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return err
-			}
+// downloadFile downloads url to path through the registry client, so that
+// private packages are fetched with the registry token. The body is written
+// to a temporary file first so that interrupted downloads never end up in
+// the cache
+func downloadFile(reg *registry.Registry, url, path string) error {
+	resp, err := reg.Fetch(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-			outFile, err := os.Create(target)
-			if err != nil {
-				return err
-			}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
 
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				outFile.Close()
-				return err
-			}
-			outFile.Close()
-		}
+	tmpPath := path + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
 }
@@ -0,0 +1,98 @@
+package installer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/droy-go/droy-pm/internal/utils"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/registry"
+)
+
+// newTestInstaller returns an installer without a store, reading package
+// metadata and the tarball of lib@1.0.0 from a test registry. integrity is
+// what the registry publishes for it
+func newTestInstaller(t *testing.T, tarball, integrity string) *Installer {
+	t.Helper()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lib/1.0.0":
+			json.NewEncoder(w).Encode(registry.PackageInfo{
+				Name:    "lib",
+				Version: "1.0.0",
+				Dist:    &registry.DistInfo{Tarball: srv.URL + "/lib/-/lib-1.0.0.tgz", Integrity: integrity},
+			})
+		case "/lib/-/lib-1.0.0.tgz":
+			http.ServeFile(w, r, tarball)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	inst := New(filepath.Join(t.TempDir(), "droy_modules"))
+	inst.CachePath = t.TempDir()
+	inst.Store = nil
+	inst.Registry = registry.New(srv.URL)
+	inst.Registry.CacheDir = t.TempDir()
+	return inst
+}
+
+func TestInstallVerifiesIntegrity(t *testing.T) {
+	tarball := writeTarball(t, []entry{{name: "package/droy.toml", body: "name = \"lib\"\n"}})
+	integrity, err := utils.CalculateIntegrity(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst := newTestInstaller(t, tarball, integrity)
+	resolved := inst.Registry.URL + "/lib/-/lib-1.0.0.tgz"
+	locked, err := inst.InstallLocked("lib", &config.LockPackage{Version: "1.0.0", Resolved: resolved})
+	if err != nil {
+		t.Fatalf("InstallLocked: %v", err)
+	}
+	if locked.Integrity != integrity {
+		t.Errorf("locked integrity = %s, want %s", locked.Integrity, integrity)
+	}
+	if _, err := os.Stat(filepath.Join(inst.ModulesPath, "lib", "droy.toml")); err != nil {
+		t.Error(err)
+	}
+
+	other := writeTarball(t, []entry{{name: "package/droy.toml", body: "name = \"evil\"\n"}})
+	inst = newTestInstaller(t, other, integrity)
+	resolved = inst.Registry.URL + "/lib/-/lib-1.0.0.tgz"
+	if _, err := inst.InstallLocked("lib", &config.LockPackage{Version: "1.0.0", Resolved: resolved}); err == nil {
+		t.Error("installed a tarball that does not match its integrity")
+	}
+}
+
+func TestInstallRefusesPackagesWithoutIntegrity(t *testing.T) {
+	tarball := writeTarball(t, []entry{{name: "package/droy.toml", body: "name = \"lib\"\n"}})
+	inst := newTestInstaller(t, tarball, "")
+
+	// A cached tarball is no more trustworthy than a download
+	cached := filepath.Join(inst.CachePath, "lib-1.0.0.tgz")
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cached, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := &config.LockPackage{Version: "1.0.0", Resolved: inst.Registry.URL + "/lib/-/lib-1.0.0.tgz"}
+	_, err = inst.InstallLocked("lib", locked)
+	if err == nil || !strings.Contains(err.Error(), "no integrity") {
+		t.Errorf("InstallLocked = %v, want it refused for having no integrity", err)
+	}
+	if _, err := os.Stat(filepath.Join(inst.ModulesPath, "lib")); !os.IsNotExist(err) {
+		t.Errorf("lib was installed: %v", err)
+	}
+}
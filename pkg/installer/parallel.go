@@ -0,0 +1,179 @@
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// DefaultConcurrency is the number of packages installed at once when no
+// concurrency is given
+const DefaultConcurrency = 8
+
+// Job is a package to install with InstallAll
+type Job struct {
+	Name   string
+	Locked *config.LockPackage
+}
+
+// Result is the outcome of installing one package. Package is nil when
+// Err is set
+type Result struct {
+	Name    string
+	Package *config.LockPackage
+	Err     error
+}
+
+// Event reports progress of a package during InstallAll. Status is a short
+// description of the current step; Done is set once the package is
+// finished, with Err holding the failure if any
+type Event struct {
+	Name    string
+	Version string
+	Status  string
+	Done    bool
+	Err     error
+}
+
+// InstallAll installs packages with at most concurrency of them in flight.
+//
+// Downloading and extracting never depend on other packages, so they run
+// fully in parallel. Packages are then completed in dependency order: a
+// package is only reported done once all of its dependencies in jobs are,
+// and fails if any of them failed to install. Events are delivered to
+// notify, which may be called from several goroutines at once. Results are
+// returned in the order of jobs.
+func (i *Installer) InstallAll(jobs []Job, concurrency int, notify func(Event)) []Result {
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+	if notify == nil {
+		notify = func(Event) {}
+	}
+
+	results := make([]Result, len(jobs))
+	index := make(map[string]int, len(jobs))
+	for n, job := range jobs {
+		index[job.Name] = n
+		results[n].Name = job.Name
+	}
+
+	// Fetch and extract everything
+	runPool(len(jobs), concurrency, func(n int) {
+		job := jobs[n]
+		notify(Event{Name: job.Name, Version: job.Locked.Version, Status: "fetching"})
+
+		pkg, err := i.InstallLocked(job.Name, job.Locked)
+		if err != nil {
+			results[n].Err = err
+			return
+		}
+		results[n].Package = pkg
+	})
+
+	// Complete packages in dependency order
+	complete := make(map[string]bool, len(jobs))
+	for len(complete) < len(jobs) {
+		var ready []int
+		for n, job := range jobs {
+			if !complete[job.Name] && dependenciesComplete(job, index, complete) {
+				ready = append(ready, n)
+			}
+		}
+
+		// A dependency cycle leaves nothing ready; complete the rest in name order
+		if len(ready) == 0 {
+			for n, job := range jobs {
+				if !complete[job.Name] {
+					ready = append(ready, n)
+				}
+			}
+			sort.Slice(ready, func(a, b int) bool { return jobs[ready[a]].Name < jobs[ready[b]].Name })
+		}
+
+		for _, n := range ready {
+			job := jobs[n]
+			if results[n].Err == nil {
+				if failed := failedDependency(job, index, results); failed != "" {
+					results[n].Err = fmt.Errorf("dependency %s failed to install", failed)
+					results[n].Package = nil
+				}
+			}
+			notify(Event{Name: job.Name, Version: job.Locked.Version, Done: true, Err: results[n].Err})
+		}
+
+		for _, n := range ready {
+			complete[jobs[n].Name] = true
+		}
+	}
+
+	return results
+}
+
+// runPool calls fn for 0..n-1 using at most workers goroutines
+func runPool(n, workers int, fn func(int)) {
+	if workers > n {
+		workers = n
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range next {
+				fn(k)
+			}
+		}()
+	}
+
+	for k := 0; k < n; k++ {
+		next <- k
+	}
+	close(next)
+	wg.Wait()
+}
+
+// dependenciesComplete reports whether every dependency of job that is part
+// of the same InstallAll call has completed
+func dependenciesComplete(job Job, index map[string]int, complete map[string]bool) bool {
+	for dep := range job.Locked.Dependencies {
+		if _, ok := index[dep]; ok && !complete[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// failedDependency returns the first dependency of job, by name, that
+// failed to install in the same InstallAll call, or ""
+func failedDependency(job Job, index map[string]int, results []Result) string {
+	var failed []string
+	for dep := range job.Locked.Dependencies {
+		if n, ok := index[dep]; ok && results[n].Err != nil {
+			failed = append(failed, dep)
+		}
+	}
+	if len(failed) == 0 {
+		return ""
+	}
+	sort.Strings(failed)
+	return failed[0]
+}
+
+// PackageDir returns the directory a package is installed into. Scoped
+// packages are nested under their scope, e.g. droy_modules/@acme/utils
+func (i *Installer) PackageDir(name string) string {
+	if strings.HasPrefix(name, "github.com/") {
+		parts := strings.Split(name, "/")
+		if len(parts) >= 3 {
+			return filepath.Join(i.ModulesPath, parts[2])
+		}
+	}
+	return filepath.Join(i.ModulesPath, name)
+}
@@ -0,0 +1,99 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/droy-go/droy-pm/internal/utils"
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/registry"
+)
+
+func TestInstallAllCompletesInDependencyOrder(t *testing.T) {
+	tarball := writeTarball(t, []entry{{name: "package/droy.toml", body: "name = \"pkg\"\n"}})
+	integrity, err := utils.CalculateIntegrity(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/broken/") {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, tarball)
+	}))
+	t.Cleanup(srv.Close)
+
+	inst := New(filepath.Join(t.TempDir(), "droy_modules"))
+	inst.CachePath = t.TempDir()
+	inst.Store = nil
+	inst.Registry = registry.New(srv.URL)
+	inst.Registry.CacheDir = t.TempDir()
+
+	locked := func(name string, deps ...string) *config.LockPackage {
+		pkg := &config.LockPackage{
+			Version:      "1.0.0",
+			Resolved:     srv.URL + "/" + name + "/-/" + name + "-1.0.0.tgz",
+			Integrity:    integrity,
+			Dependencies: make(map[string]string),
+		}
+		for _, dep := range deps {
+			pkg.Dependencies[dep] = "^1.0.0"
+		}
+		return pkg
+	}
+
+	// app -> mid -> lib, and tool -> broken
+	jobs := []Job{
+		{Name: "app", Locked: locked("app", "mid")},
+		{Name: "tool", Locked: locked("tool", "broken")},
+		{Name: "mid", Locked: locked("mid", "lib")},
+		{Name: "lib", Locked: locked("lib")},
+		{Name: "broken", Locked: locked("broken")},
+	}
+
+	var mu sync.Mutex
+	var done []string
+	results := inst.InstallAll(jobs, 4, func(e Event) {
+		if e.Done {
+			mu.Lock()
+			done = append(done, e.Name)
+			mu.Unlock()
+		}
+	})
+
+	position := make(map[string]int)
+	for n, name := range done {
+		position[name] = n
+	}
+	if len(position) != len(jobs) {
+		t.Fatalf("done events = %v, want one per job", done)
+	}
+	for _, pair := range [][2]string{{"lib", "mid"}, {"mid", "app"}, {"broken", "tool"}} {
+		if position[pair[0]] > position[pair[1]] {
+			t.Errorf("%s completed before its dependency %s: %v", pair[1], pair[0], done)
+		}
+	}
+
+	for n, job := range jobs {
+		if results[n].Name != job.Name {
+			t.Errorf("results[%d] = %s, want %s", n, results[n].Name, job.Name)
+		}
+	}
+	for _, n := range []int{0, 2, 3} {
+		if results[n].Err != nil || results[n].Package == nil {
+			t.Errorf("%s: err = %v, package = %v", results[n].Name, results[n].Err, results[n].Package)
+		}
+	}
+	if results[4].Err == nil {
+		t.Error("broken installed without a tarball")
+	}
+	if err := results[1].Err; err == nil || !strings.Contains(err.Error(), "dependency broken failed") {
+		t.Errorf("tool: err = %v, want its dependency reported as failed", err)
+	}
+}
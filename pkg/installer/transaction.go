@@ -0,0 +1,151 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Transaction records everything an install or update changes so that it
+// can be undone as a whole. Packages replaced or removed while a transaction
+// is active are kept aside instead of deleted until Commit.
+type Transaction struct {
+	mu        sync.Mutex
+	installer *Installer
+	dir       string
+
+	// files holds the project files as they were at Begin; nil means the
+	// file did not exist
+	files map[string][]byte
+
+	// modules maps each package directory touched to its backup, or "" if
+	// there was nothing installed there before
+	modules map[string]string
+
+	modulesExisted bool
+}
+
+// Begin starts a transaction covering the installer's modules directory and
+// the given project files, such as droy.toml and droy.lock
+func (i *Installer) Begin(files ...string) (*Transaction, error) {
+	if i.tx != nil {
+		return nil, fmt.Errorf("a transaction is already in progress")
+	}
+
+	tx := &Transaction{
+		installer: i,
+		files:     make(map[string][]byte),
+		modules:   make(map[string]string),
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to back up %s: %w", file, err)
+		}
+		tx.files[file] = data
+	}
+
+	if _, err := os.Stat(i.ModulesPath); err == nil {
+		tx.modulesExisted = true
+	}
+	if err := os.MkdirAll(i.ModulesPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create modules directory: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(i.ModulesPath, ".backup-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	tx.dir = dir
+
+	i.tx = tx
+	return tx, nil
+}
+
+// Commit makes the transaction's changes permanent
+func (tx *Transaction) Commit() error {
+	tx.installer.tx = nil
+	return os.RemoveAll(tx.dir)
+}
+
+// Rollback restores the project files and every package directory touched
+// since Begin
+func (tx *Transaction) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.installer.tx = nil
+
+	var errs []error
+
+	for target, backup := range tx.modules {
+		if err := os.RemoveAll(target); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if backup != "" {
+			if err := os.Rename(backup, target); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	for file, data := range tx.files {
+		var err error
+		if data == nil {
+			err = os.Remove(file)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			err = os.WriteFile(file, data, 0644)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", file, err))
+		}
+	}
+
+	if err := os.RemoveAll(tx.dir); err != nil {
+		errs = append(errs, err)
+	}
+	if !tx.modulesExisted && len(errs) == 0 {
+		if err := os.RemoveAll(tx.installer.ModulesPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// setAside moves an existing package directory out of the way. The first
+// time a directory is touched its previous contents are kept for Rollback;
+// anything installed over it later in the same transaction is discarded
+func (tx *Transaction) setAside(target string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	_, statErr := os.Lstat(target)
+	exists := statErr == nil
+
+	if _, touched := tx.modules[target]; touched {
+		if exists {
+			return os.RemoveAll(target)
+		}
+		return nil
+	}
+
+	if !exists {
+		tx.modules[target] = ""
+		return nil
+	}
+
+	backup := filepath.Join(tx.dir, strconv.Itoa(len(tx.modules)))
+	if err := os.Rename(target, backup); err != nil {
+		return err
+	}
+	tx.modules[target] = backup
+	return nil
+}
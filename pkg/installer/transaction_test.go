@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/droy-go/droy-pm/internal/utils"
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+func TestRollbackAfterFailedInstall(t *testing.T) {
+	tarball := writeTarball(t, []entry{{name: "package/droy.toml", body: "name = \"lib\"\nversion = \"1.0.0\"\n"}})
+	integrity, err := utils.CalculateIntegrity(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := newTestInstaller(t, tarball, integrity)
+
+	project := t.TempDir()
+	manifest := filepath.Join(project, "droy.toml")
+	lockFile := filepath.Join(project, "droy.lock")
+	writeTestFile(t, manifest, "name = \"app\"\n")
+
+	// An older lib is installed and is replaced during the transaction
+	oldLib := filepath.Join(inst.ModulesPath, "lib", "droy.toml")
+	writeTestFile(t, oldLib, "name = \"lib\"\nversion = \"0.9.0\"\n")
+
+	tx, err := inst.Begin(manifest, lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files edited after Begin belong to the transaction
+	writeTestFile(t, manifest, "name = \"app\"\n\n[dependencies]\nlib = \"^1.0.0\"\n")
+	writeTestFile(t, lockFile, "version = 1\n")
+
+	resolved := inst.Registry.URL + "/lib/-/lib-1.0.0.tgz"
+	if _, err := inst.InstallLocked("lib", &config.LockPackage{Version: "1.0.0", Resolved: resolved, Integrity: integrity}); err != nil {
+		t.Fatalf("InstallLocked(lib): %v", err)
+	}
+	if got := readTestFile(t, oldLib); got != "name = \"lib\"\nversion = \"1.0.0\"\n" {
+		t.Fatalf("lib was not replaced: %q", got)
+	}
+
+	missing := &config.LockPackage{Version: "1.0.0", Resolved: inst.Registry.URL + "/missing/-/missing-1.0.0.tgz", Integrity: integrity}
+	if _, err := inst.InstallLocked("missing", missing); err == nil {
+		t.Fatal("InstallLocked(missing) succeeded")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	if got := readTestFile(t, oldLib); got != "name = \"lib\"\nversion = \"0.9.0\"\n" {
+		t.Errorf("lib after rollback = %q, want the version set aside", got)
+	}
+	if got := readTestFile(t, manifest); got != "name = \"app\"\n" {
+		t.Errorf("droy.toml after rollback = %q", got)
+	}
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Errorf("droy.lock did not exist before and was kept: %v", err)
+	}
+
+	entries, err := os.ReadDir(inst.ModulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "lib" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("droy_modules after rollback = %v, want only lib", names)
+	}
+}
+
+func TestRollbackRemovesNewModulesDir(t *testing.T) {
+	tarball := writeTarball(t, []entry{{name: "package/droy.toml", body: "name = \"lib\"\n"}})
+	integrity, err := utils.CalculateIntegrity(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := newTestInstaller(t, tarball, integrity)
+
+	tx, err := inst.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved := inst.Registry.URL + "/lib/-/lib-1.0.0.tgz"
+	if _, err := inst.InstallLocked("lib", &config.LockPackage{Version: "1.0.0", Resolved: resolved, Integrity: integrity}); err != nil {
+		t.Fatalf("InstallLocked: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	if _, err := os.Stat(inst.ModulesPath); !os.IsNotExist(err) {
+		t.Errorf("droy_modules was created by the transaction and kept: %v", err)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
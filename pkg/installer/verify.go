@@ -0,0 +1,64 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/signing"
+)
+
+// verifySignature checks the registry signature of a package version
+// against the installer's Verifier. Under the warn policy a missing or bad
+// signature is recorded as a warning and the install goes ahead; under
+// require it fails the install
+func (i *Installer) verifySignature(reg *registry.Registry, name, version, integrity string) error {
+	if i.Verifier == nil || i.Verifier.Policy == signing.PolicyOff {
+		return nil
+	}
+
+	sig, err := reg.GetSignature(name, version)
+	if err == nil {
+		_, err = signing.Verify(sig, i.Verifier.Trusted, name, version, integrity)
+	}
+	if err == nil {
+		return nil
+	}
+	return i.signatureFailed(name, version, err)
+}
+
+// verifyUnsigned applies the signature policy to a package that cannot
+// have a registry signature, such as a GitHub package
+func (i *Installer) verifyUnsigned(name, version string) error {
+	if i.Verifier == nil || i.Verifier.Policy == signing.PolicyOff {
+		return nil
+	}
+	return i.signatureFailed(name, version, errors.New("GitHub packages are not signed"))
+}
+
+// signatureFailed fails the install of a package whose signature could
+// not be verified under the require policy, and warns under warn
+func (i *Installer) signatureFailed(name, version string, err error) error {
+	if i.Verifier.Policy == signing.PolicyRequire {
+		return fmt.Errorf("refusing to install %s@%s: %w", name, version, err)
+	}
+	i.warn(fmt.Sprintf("%s@%s: %v", name, version, err))
+	return nil
+}
+
+// warn records a warning for the caller to report
+func (i *Installer) warn(msg string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.warnings = append(i.warnings, msg)
+}
+
+// Warnings returns the warnings recorded since the last call, such as
+// packages that failed signature verification under the warn policy
+func (i *Installer) Warnings() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	warnings := i.warnings
+	i.warnings = nil
+	return warnings
+}
@@ -0,0 +1,31 @@
+package installer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/signing"
+)
+
+func TestGitHubPackagesFollowSignaturePolicy(t *testing.T) {
+	inst := New(filepath.Join(t.TempDir(), "droy_modules"))
+	inst.Registry.Offline = true
+
+	inst.Verifier = &signing.Verifier{Policy: signing.PolicyRequire}
+	_, err := inst.InstallLocked("github.com/droy-go/example", &config.LockPackage{Version: "v1.0.0"})
+	if err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("require policy: error = %v, want the package refused as unsigned", err)
+	}
+
+	// Under warn the install goes ahead, failing here only because the
+	// registry is offline
+	inst.Verifier = &signing.Verifier{Policy: signing.PolicyWarn}
+	if _, err := inst.InstallLocked("github.com/droy-go/example", &config.LockPackage{Version: "v1.0.0"}); err == nil || strings.Contains(err.Error(), "not signed") {
+		t.Errorf("warn policy: error = %v, want only the offline clone to fail", err)
+	}
+	if warnings := inst.Warnings(); len(warnings) != 1 {
+		t.Errorf("warn policy: warnings = %q, want one", warnings)
+	}
+}
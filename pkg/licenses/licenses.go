@@ -0,0 +1,118 @@
+package licenses
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/droy-go/droy-pm/pkg/spdx"
+)
+
+// Policy decides which licenses dependencies may use. Entries are SPDX
+// identifiers, matched case-insensitively, and may end in * to match a
+// family such as GPL-*
+type Policy struct {
+	// Allow lists the only licenses dependencies may use; empty allows
+	// every license that is not denied
+	Allow []string
+
+	// Deny lists licenses dependencies must not use
+	Deny []string
+}
+
+// Enabled reports whether the policy restricts anything
+func (p *Policy) Enabled() bool {
+	return len(p.Allow) > 0 || len(p.Deny) > 0
+}
+
+// Check reports whether a package's license expression complies with the
+// policy. An expression passes if some choice of its OR alternatives uses
+// only accepted licenses. Missing and invalid licenses always fail, since
+// a deny list cannot tell whether a string such as "GPLv3" names a denied
+// license
+func (p *Policy) Check(license string) error {
+	if !p.Enabled() {
+		return nil
+	}
+
+	expr, err := parse(license)
+	if err != nil {
+		return fmt.Errorf("%w, so it cannot be checked against the license policy", err)
+	}
+
+	if spdx.Satisfies(expr, p.accepts) {
+		return nil
+	}
+
+	var denied []string
+	for _, id := range expr.Licenses() {
+		if matches(p.Deny, id) {
+			denied = append(denied, id)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("%s is not allowed: %s denied by license-deny", license, strings.Join(denied, ", "))
+	}
+	return fmt.Errorf("%s is not allowed: not in license-allow", license)
+}
+
+// accepts reports whether a single license is acceptable on its own
+func (p *Policy) accepts(l *spdx.License) bool {
+	if matches(p.Deny, l.ID) {
+		return false
+	}
+	return len(p.Allow) == 0 || matches(p.Allow, l.ID)
+}
+
+// parse parses a package's license, treating UNLICENSED as a license of
+// its own so that policies can allow or deny it
+func parse(license string) (spdx.Expr, error) {
+	switch {
+	case license == "":
+		return nil, fmt.Errorf("no license")
+	case license == spdx.Unlicensed:
+		return &spdx.License{ID: spdx.Unlicensed}, nil
+	}
+
+	expr, err := spdx.Parse(license)
+	if err != nil {
+		return nil, fmt.Errorf("invalid license %q: %w", license, err)
+	}
+	return expr, nil
+}
+
+// matches reports whether id matches one of the patterns
+func matches(patterns []string, id string) bool {
+	id = strings.ToLower(id)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Package is an installed package and the license it declares
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	License string `json:"license"`
+
+	// Problem explains why the policy rejects the license; empty if it
+	// complies
+	Problem string `json:"problem,omitempty"`
+}
+
+// Apply checks packages against the policy, setting their Problem, and
+// returns the ones that do not comply
+func (p *Policy) Apply(pkgs []Package) []Package {
+	var violations []Package
+	for i := range pkgs {
+		pkgs[i].Problem = ""
+		if err := p.Check(pkgs[i].License); err != nil {
+			pkgs[i].Problem = err.Error()
+			violations = append(violations, pkgs[i])
+		}
+	}
+	return violations
+}
@@ -0,0 +1,34 @@
+package licenses
+
+import "testing"
+
+func TestPolicyCheck(t *testing.T) {
+	deny := &Policy{Deny: []string{"GPL-*"}}
+	allow := &Policy{Allow: []string{"MIT", "Apache-2.0"}}
+
+	tests := []struct {
+		policy  *Policy
+		license string
+		ok      bool
+	}{
+		{&Policy{}, "", true},
+		{&Policy{}, "GPLv3", true},
+		{deny, "MIT", true},
+		{deny, "GPL-3.0-only", false},
+		{deny, "MIT OR GPL-3.0-only", true},
+		{deny, "MIT AND GPL-3.0-only", false},
+		{deny, "", false},
+		{deny, "GPL", false},
+		{deny, "GPLv3", false},
+		{allow, "Apache-2.0", true},
+		{allow, "ISC", false},
+		{allow, "", false},
+	}
+
+	for _, tt := range tests {
+		err := tt.policy.Check(tt.license)
+		if (err == nil) != tt.ok {
+			t.Errorf("%+v.Check(%q) = %v, want ok %v", *tt.policy, tt.license, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package pack
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFile lists files to leave out of a package tarball, using
+// .gitignore syntax
+const IgnoreFile = ".droyignore"
+
+// ignoreRule is one line of an ignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// Ignore matches paths against ignore rules. Later rules override earlier
+// ones, so "!keep.droy" re-includes a file an earlier rule excluded
+type Ignore struct {
+	rules []ignoreRule
+}
+
+// ParseIgnore parses rules in .gitignore syntax: one pattern per line,
+// "#" comments, "!" negation, a trailing "/" for directories only and a
+// leading or inner "/" to anchor a pattern to the project root. Patterns
+// may use *, ? and [...] within a path segment and ** across segments
+func ParseIgnore(lines []string) *Ignore {
+	ig := &Ignore{}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		rule.pattern = line
+		ig.rules = append(ig.rules, rule)
+	}
+	return ig
+}
+
+// ReadIgnore reads an ignore file. A missing file ignores nothing
+func ReadIgnore(file string) (*Ignore, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return &Ignore{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ParseIgnore(lines), nil
+}
+
+// Match reports whether a slash-separated path relative to the project
+// root is ignored
+func (ig *Ignore) Match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range ig.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.matches(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(rel string) bool {
+	if r.anchored {
+		return matchGlob(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+	}
+	ok, _ := path.Match(r.pattern, path.Base(rel))
+	return ok
+}
+
+// matchGlob matches path segments against pattern segments, where a "**"
+// segment matches any number of path segments
+func matchGlob(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchGlob(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,43 @@
+package pack
+
+import "testing"
+
+func TestIgnoreMatch(t *testing.T) {
+	ig := ParseIgnore([]string{
+		"# comment",
+		"",
+		"*.log",
+		"/dist",
+		"tmp/",
+		"docs/**/draft.md",
+		"secret?.txt",
+		"!keep.log",
+	})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"debug.log", false, true},
+		{"src/debug.log", false, true},
+		{"keep.log", false, false},
+		{"src/keep.log", false, false},
+		{"dist", true, true},
+		{"src/dist", true, false},
+		{"tmp", true, true},
+		{"src/tmp", true, true},
+		{"tmp", false, false},
+		{"docs/draft.md", false, true},
+		{"docs/a/b/draft.md", false, true},
+		{"notes/draft.md", false, false},
+		{"secret1.txt", false, true},
+		{"secret12.txt", false, false},
+		{"main.droy", false, false},
+	}
+	for _, tt := range tests {
+		if got := ig.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
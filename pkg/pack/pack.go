@@ -0,0 +1,297 @@
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// mtime is stamped on every entry so that packing the same files always
+// produces the same bytes
+var mtime = time.Date(1985, time.October, 26, 8, 15, 0, 0, time.UTC)
+
+// prefix wraps every entry, as the installer expects
+const prefix = "package/"
+
+// neverInclude are never packed, whatever files or .droyignore say
+var neverInclude = map[string]bool{
+	".git":      true,
+	".hg":       true,
+	".svn":      true,
+	".droyrc":   true,
+	"droy.lock": true,
+	IgnoreFile:  true,
+	".DS_Store": true,
+}
+
+// defaultIgnore applies before .droyignore, which can re-include files
+// with "!" rules
+var defaultIgnore = []string{"*.tgz", "*.swp", "*~"}
+
+// File is a file included in a package tarball
+type File struct {
+	Path string
+	Size int64
+	Mode fs.FileMode
+}
+
+// Result describes a packed tarball
+type Result struct {
+	Path         string
+	Files        []File
+	Size         int64
+	UnpackedSize int64
+	Integrity    string
+	Shasum       string
+}
+
+// Filename returns the conventional tarball name of a package version,
+// e.g. acme-utils-1.0.0.tgz for @acme/utils
+func Filename(name, version string) string {
+	name = strings.ReplaceAll(strings.TrimPrefix(name, "@"), "/", "-")
+	return fmt.Sprintf("%s-%s.tgz", name, version)
+}
+
+// List returns the files of the project in dir that belong in its
+// tarball, sorted by path. droy.toml, the README, the LICENSE and the main
+// file are always included. With a files allowlist in droy.toml only the
+// listed paths, globs and directories are added; otherwise everything is.
+// .droyignore and exclude, which names directories such as droy_modules
+// relative to dir, are applied in both cases
+func List(dir string, pkg *config.Package, exclude ...string) ([]File, error) {
+	ignore, err := ReadIgnore(filepath.Join(dir, IgnoreFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+	ignore.rules = append(ParseIgnore(defaultIgnore).rules, ignore.rules...)
+
+	excluded := make(map[string]bool)
+	for _, e := range exclude {
+		excluded[filepath.ToSlash(filepath.Clean(e))] = true
+	}
+
+	allow := make([][]string, 0, len(pkg.Files))
+	for _, entry := range pkg.Files {
+		entry = strings.Trim(path.Clean(filepath.ToSlash(entry)), "/")
+		allow = append(allow, strings.Split(entry, "/"))
+	}
+	used := make([]bool, len(allow))
+
+	var files []File
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if neverInclude[d.Name()] || excluded[rel] || ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink; symlinks cannot be packed", rel)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if len(allow) > 0 && !alwaysIncluded(rel, pkg) && !allowed(rel, allow, used) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: rel, Size: info.Size(), Mode: info.Mode()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, ok := range used {
+		if !ok {
+			return nil, fmt.Errorf("files entry %q in droy.toml matches nothing", pkg.Files[i])
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// alwaysIncluded reports whether a file is packed regardless of the files
+// allowlist
+func alwaysIncluded(rel string, pkg *config.Package) bool {
+	if rel == "droy.toml" {
+		return true
+	}
+	if pkg.Main != "" && rel == path.Clean(filepath.ToSlash(pkg.Main)) {
+		return true
+	}
+	if strings.Contains(rel, "/") {
+		return false
+	}
+
+	upper := strings.ToUpper(rel)
+	for _, name := range []string{"README", "LICENSE", "LICENCE"} {
+		if upper == name || strings.HasPrefix(upper, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether a file matches an allowlist entry, either
+// itself or through one of its parent directories, and marks the entries
+// that matched
+func allowed(rel string, allow [][]string, used []bool) bool {
+	segments := strings.Split(rel, "/")
+
+	match := false
+	for i, pattern := range allow {
+		for n := 1; n <= len(segments); n++ {
+			if matchGlob(pattern, segments[:n]) {
+				used[i] = true
+				match = true
+				break
+			}
+		}
+	}
+	return match
+}
+
+// Create writes a reproducible gzipped tarball of files from dir to dest.
+// Entries are written in the given order with a fixed mtime, no owner and
+// modes normalized to 0644 or 0755
+func Create(dir string, files []File, dest string) (*Result, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp := dest + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tarball: %w", err)
+	}
+
+	result, err := Write(out, dir, files)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	result.Path = dest
+	return result, nil
+}
+
+// Write writes the tarball to out and returns its details without a Path.
+// Writing to io.Discard gives the size and integrity without a file
+func Write(out io.Writer, dir string, files []File) (*Result, error) {
+	sha512sum := sha512.New()
+	sha1sum := sha1.New()
+	counter := &countingWriter{}
+
+	gz := gzip.NewWriter(io.MultiWriter(out, sha512sum, sha1sum, counter))
+	tw := tar.NewWriter(gz)
+
+	result := &Result{Files: files}
+	for _, file := range files {
+		if err := addFile(tw, dir, file); err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", file.Path, err)
+		}
+		result.UnpackedSize += file.Size
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	result.Size = counter.n
+	result.Integrity = "sha512-" + base64.StdEncoding.EncodeToString(sha512sum.Sum(nil))
+	result.Shasum = hex.EncodeToString(sha1sum.Sum(nil))
+	return result, nil
+}
+
+func addFile(tw *tar.Writer, dir string, file File) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mode := int64(0644)
+	if file.Mode&0111 != 0 {
+		mode = 0755
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     prefix + file.Path,
+		Mode:     mode,
+		Size:     file.Size,
+		ModTime:  mtime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	// The size was recorded by List; a file that changed since is an error
+	// rather than a corrupt archive
+	n, err := io.Copy(tw, f)
+	if err != nil {
+		return err
+	}
+	if n != file.Size {
+		return fmt.Errorf("file changed while packing")
+	}
+	return nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,247 @@
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// writeProject creates the files of a project in a new directory, with
+// the given permissions
+func writeProject(t *testing.T, files map[string]os.FileMode) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, mode := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("contents of "+name+"\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(file, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func listPaths(files []File) []string {
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+func TestListAppliesIgnoreRules(t *testing.T) {
+	dir := writeProject(t, map[string]os.FileMode{
+		"droy.toml":               0644,
+		"README.md":               0644,
+		"src/main.droy":           0644,
+		"src/util.droy":           0644,
+		"src/debug.log":           0644,
+		"build/out.droy":          0644,
+		"docs/build/index.md":     0644,
+		"old-1.0.0.tgz":           0644,
+		"fixtures/keep.tgz":       0644,
+		"notes.txt~":              0644,
+		".git/config":             0644,
+		".droyrc":                 0644,
+		"droy.lock":               0644,
+		".DS_Store":               0644,
+		"droy_modules/lib/a.droy": 0644,
+		"vendor/droy_modules/x":   0644,
+		IgnoreFile:                0644,
+	})
+	ignore := "# build output\n/build/\n*.log\n!fixtures/keep.tgz\n"
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFile), []byte(ignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := List(dir, &config.Package{Name: "app"}, "droy_modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"README.md",
+		"docs/build/index.md",
+		"droy.toml",
+		"fixtures/keep.tgz",
+		"src/main.droy",
+		"src/util.droy",
+		"vendor/droy_modules/x",
+	}
+	if got := listPaths(files); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("List =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestListFilesAllowlist(t *testing.T) {
+	dir := writeProject(t, map[string]os.FileMode{
+		"droy.toml":      0644,
+		"LICENSE":        0644,
+		"bin/cli.droy":   0644,
+		"src/main.droy":  0644,
+		"src/lib/a.droy": 0644,
+		"test/a.droy":    0644,
+	})
+
+	pkg := &config.Package{Name: "app", Main: "bin/cli.droy", Files: []string{"src/lib", "src/*.droy"}}
+	files, err := List(dir, pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "LICENSE bin/cli.droy droy.toml src/lib/a.droy src/main.droy"
+	if got := strings.Join(listPaths(files), " "); got != want {
+		t.Errorf("List = %s, want %s", got, want)
+	}
+
+	pkg.Files = append(pkg.Files, "missing/")
+	if _, err := List(dir, pkg); err == nil {
+		t.Error("List accepted a files entry that matches nothing")
+	}
+}
+
+func TestCreateIsReproducible(t *testing.T) {
+	dir := writeProject(t, map[string]os.FileMode{
+		"droy.toml":     0644,
+		"src/main.droy": 0600,
+		"bin/cli":       0700,
+	})
+	pkg := &config.Package{Name: "app"}
+
+	pack := func(name string) (*Result, []byte) {
+		t.Helper()
+		files, err := List(dir, pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := Create(dir, files, filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(result.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, data
+	}
+
+	first, firstData := pack("first.tgz")
+
+	// Neither modification times nor group and other permissions matter
+	later := time.Now().Add(48 * time.Hour)
+	for _, name := range []string{"droy.toml", "src/main.droy", "bin/cli"} {
+		if err := os.Chtimes(filepath.Join(dir, name), later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "src/main.droy"), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	second, secondData := pack("second.tgz")
+	if !bytes.Equal(firstData, secondData) {
+		t.Error("packing the same files twice gave different tarballs")
+	}
+	if first.Integrity != second.Integrity || first.Shasum != second.Shasum {
+		t.Errorf("integrity %s and %s differ", first.Integrity, second.Integrity)
+	}
+	if first.Size != int64(len(firstData)) {
+		t.Errorf("Size = %d, want %d", first.Size, len(firstData))
+	}
+
+	// Write reports the same integrity without a file
+	files, err := List(dir, pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discarded, err := Write(io.Discard, dir, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if discarded.Integrity != first.Integrity {
+		t.Errorf("Write integrity = %s, want %s", discarded.Integrity, first.Integrity)
+	}
+}
+
+func TestCreateNormalizesHeaders(t *testing.T) {
+	dir := writeProject(t, map[string]os.FileMode{
+		"droy.toml":     0664,
+		"src/main.droy": 0600,
+		"bin/cli":       0750,
+	})
+	files, err := List(dir, &config.Package{Name: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Create(dir, files, filepath.Join(t.TempDir(), "app.tgz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(result.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	wantModes := map[string]int64{
+		"package/bin/cli":       0755,
+		"package/droy.toml":     0644,
+		"package/src/main.droy": 0644,
+	}
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+
+		if header.Mode != wantModes[header.Name] {
+			t.Errorf("%s mode = %o, want %o", header.Name, header.Mode, wantModes[header.Name])
+		}
+		if !header.ModTime.Equal(mtime) {
+			t.Errorf("%s mtime = %v, want %v", header.Name, header.ModTime, mtime)
+		}
+		if header.Uid != 0 || header.Gid != 0 || header.Uname != "" || header.Gname != "" {
+			t.Errorf("%s has owner %d:%d (%s:%s)", header.Name, header.Uid, header.Gid, header.Uname, header.Gname)
+		}
+	}
+
+	want := "package/bin/cli package/droy.toml package/src/main.droy"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("entries = %s, want %s in order", got, want)
+	}
+}
+
+func TestListRejectsSymlinks(t *testing.T) {
+	dir := writeProject(t, map[string]os.FileMode{"droy.toml": 0644})
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "passwd")); err != nil {
+		t.Skip(err)
+	}
+	if _, err := List(dir, &config.Package{Name: "app"}); err == nil {
+		t.Error("List packed a symlink")
+	}
+}
@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// Advisory describes a known vulnerability in a range of versions of a
+// package
+type Advisory struct {
+	ID       string `json:"id"`
+	Package  string `json:"package"`
+	Title    string `json:"title"`
+	Severity string `json:"severity"`
+	URL      string `json:"url,omitempty"`
+
+	// VulnerableVersions is the range of affected versions, e.g. "<1.2.3"
+	VulnerableVersions string `json:"vulnerable_versions"`
+
+	// PatchedVersions is the range of versions with the fix, e.g.
+	// ">=1.2.3"; empty when there is none
+	PatchedVersions string `json:"patched_versions,omitempty"`
+}
+
+// Advisories asks the registry's advisory endpoint about package versions,
+// given as a map of package names to versions, and returns the advisories
+// that apply to them by package name. Scoped packages are checked against
+// their own registries
+func (r *Registry) Advisories(versions map[string][]string) (map[string][]Advisory, error) {
+	// Group the packages by the registry that serves them
+	groups := make(map[*Registry]map[string][]string)
+	for name, list := range versions {
+		reg := r.ForPackage(name)
+		if groups[reg] == nil {
+			groups[reg] = make(map[string][]string)
+		}
+		groups[reg][name] = list
+	}
+
+	// Query in a fixed order so errors are reproducible
+	regs := make([]*Registry, 0, len(groups))
+	for reg := range groups {
+		regs = append(regs, reg)
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i].URL < regs[j].URL })
+
+	result := make(map[string][]Advisory)
+	for _, reg := range regs {
+		found, err := reg.bulkAdvisories(groups[reg])
+		if err != nil {
+			return nil, err
+		}
+		for name, advisories := range found {
+			for _, adv := range advisories {
+				adv.Package = name
+				result[name] = append(result[name], adv)
+			}
+		}
+	}
+	return result, nil
+}
+
+// bulkAdvisories sends one request to the advisory endpoint,
+// POST /-/advisories/bulk, with a body mapping names to versions
+func (r *Registry) bulkAdvisories(versions map[string][]string) (map[string][]Advisory, error) {
+	if r.Offline {
+		return nil, fmt.Errorf("cannot query advisories in offline mode: %w", ErrNotCached)
+	}
+
+	body, err := json.Marshal(versions)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", r.URL+"/-/advisories/bulk", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	r.authorize(req)
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s has no advisory endpoint", r.URL)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("registry error: %s - %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result map[string][]Advisory
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode advisories: %w", err)
+	}
+	return result, nil
+}
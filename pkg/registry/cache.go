@@ -0,0 +1,176 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached metadata is used without asking the
+// registry whether it changed
+const DefaultCacheTTL = 5 * time.Minute
+
+// ErrNotCached is returned in offline mode when a response is not in the
+// metadata cache
+var ErrNotCached = errors.New("not available offline")
+
+// errNotFound is returned by get for 404 responses
+var errNotFound = errors.New("not found")
+
+// ErrPackageNotFound is returned when the registry has no such package or
+// version
+var ErrPackageNotFound = errors.New("package not found")
+
+// cacheEntry is a registry response stored on disk along with the
+// validators needed to revalidate it
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	NotFound     bool      `json:"notFound,omitempty"`
+	Body         []byte    `json:"body,omitempty"`
+}
+
+// cachePath returns the file a response for rawURL is cached in: one
+// directory per registry host and one file per request path, e.g.
+// metadata/registry.droy-lang.org/droy-http.json
+func (r *Registry) cachePath(rawURL string) string {
+	host, key := "default", rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+		key = strings.TrimPrefix(u.RequestURI(), "/")
+	}
+	if key == "" {
+		key = "index"
+	}
+
+	host = strings.ReplaceAll(host, ":", "_")
+	return filepath.Join(r.CacheDir, host, url.PathEscape(key)+".json")
+}
+
+func (r *Registry) readCache(rawURL string) (*cacheEntry, bool) {
+	if r.CacheDir == "" {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(r.cachePath(rawURL))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != rawURL {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (r *Registry) writeCache(entry *cacheEntry) error {
+	if r.CacheDir == "" {
+		return nil
+	}
+
+	path := r.cachePath(entry.URL)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// get fetches a registry URL through the metadata cache.
+//
+// Cached responses younger than CacheTTL are used as they are. Older ones
+// are revalidated with If-None-Match/If-Modified-Since, so an unchanged
+// package costs a 304 instead of a full download. With PreferOffline any
+// cached response is used without contacting the registry, and with
+// Offline the cache is the only source.
+func (r *Registry) get(rawURL string) ([]byte, error) {
+	if body, ok := r.memo.Load(rawURL); ok {
+		return body.([]byte), nil
+	}
+
+	entry, cached := r.readCache(rawURL)
+	if cached && (r.Offline || r.PreferOffline || time.Since(entry.FetchedAt) < r.CacheTTL) {
+		return r.remember(entry)
+	}
+	if r.Offline {
+		return nil, ErrNotCached
+	}
+
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.authorize(req)
+	if cached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		entry.FetchedAt = time.Now()
+
+	case resp.StatusCode == http.StatusNotFound:
+		entry = &cacheEntry{URL: rawURL, FetchedAt: time.Now(), NotFound: true}
+
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		entry = &cacheEntry{
+			URL:          rawURL,
+			FetchedAt:    time.Now(),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Body:         body,
+		}
+
+	default:
+		return nil, fmt.Errorf("registry error: %s", resp.Status)
+	}
+
+	// Caching is best effort; a response that cannot be stored is still used
+	r.writeCache(entry)
+
+	return r.remember(entry)
+}
+
+// remember keeps a response in memory for the rest of the process, so that
+// repeated lookups by different commands and goroutines share one fetch
+func (r *Registry) remember(entry *cacheEntry) ([]byte, error) {
+	if entry.NotFound {
+		return nil, errNotFound
+	}
+	r.memo.Store(entry.URL, entry.Body)
+	return entry.Body, nil
+}
@@ -0,0 +1,136 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer serves lib with an ETag, answering a matching
+// If-None-Match with 304, and counts full and conditional responses
+type countingServer struct {
+	*httptest.Server
+	full, notModified atomic.Int32
+	version           atomic.Value
+}
+
+func newCountingServer(t *testing.T) *countingServer {
+	t.Helper()
+
+	s := &countingServer{}
+	s.version.Store("1.0.0")
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lib" {
+			http.NotFound(w, r)
+			return
+		}
+
+		version := s.version.Load().(string)
+		etag := `"` + version + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			s.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		s.full.Add(1)
+		w.Header().Set("ETag", etag)
+		json.NewEncoder(w).Encode(PackageInfo{Name: "lib", Version: version})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newCachedRegistry returns a client for url caching in dir. Each client
+// has its own in-memory responses, so a new one goes through the disk cache
+func newCachedRegistry(url, dir string, ttl time.Duration) *Registry {
+	reg := New(url)
+	reg.Token = ""
+	reg.CacheDir = dir
+	reg.CacheTTL = ttl
+	return reg
+}
+
+func TestCacheUsesFreshEntriesAndRevalidatesStaleOnes(t *testing.T) {
+	srv := newCountingServer(t)
+	dir := t.TempDir()
+
+	fetch := func(reg *Registry) string {
+		t.Helper()
+		info, err := reg.GetPackage("lib")
+		if err != nil {
+			t.Fatalf("GetPackage: %v", err)
+		}
+		return info.Version
+	}
+
+	fetch(newCachedRegistry(srv.URL, dir, time.Hour))
+	if got := srv.full.Load(); got != 1 {
+		t.Fatalf("first fetch made %d full requests, want 1", got)
+	}
+
+	// Within the TTL the registry is not asked at all
+	fetch(newCachedRegistry(srv.URL, dir, time.Hour))
+	if full, cond := srv.full.Load(), srv.notModified.Load(); full != 1 || cond != 0 {
+		t.Errorf("fresh cache made %d full and %d conditional requests, want none", full-1, cond)
+	}
+
+	// Past the TTL the entry is revalidated with its ETag
+	if got := fetch(newCachedRegistry(srv.URL, dir, 0)); got != "1.0.0" {
+		t.Errorf("revalidated version = %s, want 1.0.0", got)
+	}
+	if full, cond := srv.full.Load(), srv.notModified.Load(); full != 1 || cond != 1 {
+		t.Errorf("stale cache made %d full and %d conditional requests, want one 304", full-1, cond)
+	}
+
+	// A changed package is downloaded again
+	srv.version.Store("1.1.0")
+	if got := fetch(newCachedRegistry(srv.URL, dir, 0)); got != "1.1.0" {
+		t.Errorf("version after a change = %s, want 1.1.0", got)
+	}
+	if got := srv.full.Load(); got != 2 {
+		t.Errorf("changed package made %d full requests in total, want 2", got)
+	}
+}
+
+func TestCacheOfflineModes(t *testing.T) {
+	srv := newCountingServer(t)
+	dir := t.TempDir()
+
+	offline := newCachedRegistry(srv.URL, dir, time.Hour)
+	offline.Offline = true
+	if _, err := offline.GetPackage("lib"); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("offline GetPackage without a cache = %v, want ErrNotCached", err)
+	}
+	if got := srv.full.Load(); got != 0 {
+		t.Fatalf("offline mode made %d requests", got)
+	}
+
+	if _, err := newCachedRegistry(srv.URL, dir, time.Hour).GetPackage("lib"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stale entries are used as they are, without revalidating
+	for _, mode := range []string{"offline", "prefer-offline"} {
+		reg := newCachedRegistry(srv.URL, dir, 0)
+		reg.Offline = mode == "offline"
+		reg.PreferOffline = mode == "prefer-offline"
+		if _, err := reg.GetPackage("lib"); err != nil {
+			t.Errorf("%s GetPackage with a stale cache: %v", mode, err)
+		}
+	}
+	if full, cond := srv.full.Load(), srv.notModified.Load(); full != 1 || cond != 0 {
+		t.Errorf("offline modes made %d full and %d conditional requests, want none", full-1, cond)
+	}
+
+	// prefer-offline still fetches what is not cached
+	reg := newCachedRegistry(srv.URL, dir, time.Hour)
+	reg.PreferOffline = true
+	if _, err := reg.GetPackage("missing"); !errors.Is(err, ErrPackageNotFound) {
+		t.Errorf("prefer-offline GetPackage of an uncached package = %v, want ErrPackageNotFound", err)
+	}
+}
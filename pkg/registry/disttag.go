@@ -0,0 +1,134 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/droy-go/droy-pm/pkg/semver"
+)
+
+// DefaultTag is the dist-tag publish points at the new version unless
+// told otherwise, and the one installs without a version use
+const DefaultTag = "latest"
+
+// Access levels a package can be published with
+const (
+	AccessPublic     = "public"
+	AccessRestricted = "restricted"
+)
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+// ValidTag checks a dist-tag name. Tags that could be read as a version
+// range, such as "v1" or "x", are rejected so that specs stay unambiguous
+func ValidTag(tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q: use letters, numbers, dots, hyphens and underscores, starting with a letter", tag)
+	}
+	if _, err := semver.ParseRange(tag); err == nil {
+		return fmt.Errorf("invalid tag %q: it looks like a version range", tag)
+	}
+	return nil
+}
+
+// IsTag reports whether a version spec names a dist-tag such as "next"
+// rather than a version or range
+func IsTag(spec string) bool {
+	return ValidTag(spec) == nil
+}
+
+// ValidAccess checks a publish access level
+func ValidAccess(access string) error {
+	if access != AccessPublic && access != AccessRestricted {
+		return fmt.Errorf("invalid access %q, expected %s or %s", access, AccessPublic, AccessRestricted)
+	}
+	return nil
+}
+
+// DistTags returns the dist-tags of a package, mapping tag names to
+// versions. Unlike other metadata they are always fetched fresh, since
+// they move without a new version being published
+func (r *Registry) DistTags(name string) (map[string]string, error) {
+	var tags map[string]string
+	if err := r.distTagRequest("GET", name, "", nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// AddDistTag points a dist-tag of a package at a published version
+func (r *Registry) AddDistTag(name, tag, version string) error {
+	if err := ValidTag(tag); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	return r.distTagRequest("PUT", name, tag, body, nil)
+}
+
+// RemoveDistTag removes a dist-tag from a package. The latest tag cannot
+// be removed, only moved
+func (r *Registry) RemoveDistTag(name, tag string) error {
+	if tag == DefaultTag {
+		return fmt.Errorf("the %s tag cannot be removed", DefaultTag)
+	}
+	return r.distTagRequest("DELETE", name, tag, nil, nil)
+}
+
+// distTagRequest sends a request to the dist-tags endpoint of a package,
+// /-/package/:package/dist-tags[/:tag], decoding the response into out
+func (r *Registry) distTagRequest(method, name, tag string, body []byte, out interface{}) error {
+	if reg := r.ForPackage(name); reg != r {
+		return reg.distTagRequest(method, name, tag, body, out)
+	}
+	if r.Offline {
+		return fmt.Errorf("cannot manage dist-tags in offline mode")
+	}
+
+	endpoint := fmt.Sprintf("%s/-/package/%s/dist-tags", r.URL, escapeName(name))
+	if tag != "" {
+		endpoint += "/" + url.PathEscape(tag)
+	}
+
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	r.authorize(req)
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && tag != "" && method == "DELETE":
+		return fmt.Errorf("%s has no %s tag", name, tag)
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrPackageNotFound, name)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("registry error: %s - run 'droy-pm login' first", resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("registry error: %s - %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode dist-tags: %w", err)
+		}
+	}
+	return nil
+}
@@ -1,20 +1,51 @@
 package registry
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/droy-go/droy-pm/internal/utils"
 	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/signing"
 )
 
 // Registry represents a package registry
 type Registry struct {
 	URL        string
 	HTTPClient *http.Client
+
+	// Token is sent as a bearer token with requests to the registry's own
+	// host; it is never sent to other hosts such as GitHub
+	Token string
+
+	// CacheDir holds cached registry responses, which are revalidated
+	// once they are older than CacheTTL
+	CacheDir string
+	CacheTTL time.Duration
+
+	// Offline answers every request from CacheDir and never touches the
+	// network. PreferOffline uses cached responses when present and only
+	// fetches what is missing
+	Offline       bool
+	PreferOffline bool
+
+	// Scopes maps package scopes such as "@acme" to the registries that
+	// serve them. Requests for scoped packages are sent there instead
+	Scopes map[string]*Registry
+
+	// memo holds the responses seen by this client
+	memo sync.Map
 }
 
 // PackageInfo represents package information from registry
@@ -27,6 +58,8 @@ type PackageInfo struct {
 	Repository  string   `json:"repository"`
 	Keywords    []string `json:"keywords"`
 	Versions    []string `json:"versions"`
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	DistTags    map[string]string `json:"dist-tags,omitempty"`
 	Dist        *DistInfo `json:"dist,omitempty"`
 }
 
@@ -37,16 +70,44 @@ type DistInfo struct {
 	Integrity string `json:"integrity"`
 }
 
-// SearchResult represents a search result
+// VersionList is the response of the versions endpoint
+type VersionList struct {
+	Versions []string          `json:"versions"`
+	Latest   string            `json:"latest"`
+	DistTags map[string]string `json:"dist-tags,omitempty"`
+}
+
+// PublishOptions are sent along with a published tarball
+type PublishOptions struct {
+	// Tag is the dist-tag to point at the new version; the registry uses
+	// latest when empty
+	Tag string
+
+	// Access is public or restricted; empty leaves the registry's default
+	Access string
+
+	// Signature is the detached signature of the tarball, sent in the same
+	// request so that the version is never published unsigned
+	Signature *signing.Signature
+}
+
+// SearchResult represents a search result. Total counts every match,
+// not just the returned page
 type SearchResult struct {
 	Packages []PackageInfo `json:"packages"`
 	Total    int           `json:"total"`
 }
 
-// New creates a new registry client
+// DefaultURL is the public Droy registry
+const DefaultURL = "https://registry.droy-lang.org"
+
+// New creates a new registry client. Its Token is the one stored for the
+// host of url in ~/.droy/credentials or, for the public registry only,
+// DROY_TOKEN; clients of a differently configured default registry set
+// Token themselves
 func New(url string) *Registry {
 	if url == "" {
-		url = "https://registry.droy-lang.org"
+		url = DefaultURL
 	}
 
 	return &Registry{
@@ -54,35 +115,174 @@ func New(url string) *Registry {
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		CacheDir: filepath.Join(utils.GetCacheDir(), "metadata"),
+		CacheTTL: DefaultCacheTTL,
+		Token:    config.RegistryToken(url, DefaultURL),
+	}
+}
+
+// authorize adds the registry token to requests for the registry's host
+func (r *Registry) authorize(req *http.Request) {
+	if r.Token == "" {
+		return
+	}
+	if !strings.EqualFold(req.URL.Host, config.RegistryHost(r.URL)) {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+r.Token)
+}
+
+// Fetch performs a GET request for a file such as a package tarball,
+// authorized like every other registry request. Unlike metadata requests
+// it is not bound by HTTPClient's timeout, since large downloads may take
+// a while. The caller must close the response body
+func (r *Registry) Fetch(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.authorize(req)
+
+	client := *r.HTTPClient
+	client.Timeout = 0
+	return client.Do(req)
+}
+
+// Whoami returns the user the registry token belongs to
+func (r *Registry) Whoami() (string, error) {
+	if r.Offline {
+		return "", fmt.Errorf("cannot contact the registry in offline mode")
+	}
+	if r.Token == "" {
+		return "", fmt.Errorf("not logged in to %s", r.URL)
+	}
+
+	req, err := http.NewRequest("GET", r.URL+"/-/whoami", nil)
+	if err != nil {
+		return "", err
+	}
+	r.authorize(req)
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to contact registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", fmt.Errorf("the registry rejected the token: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry error: %s", resp.Status)
 	}
+
+	var result struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Username, nil
 }
 
 // GetPackage gets package information from the registry
 func (r *Registry) GetPackage(name string) (*PackageInfo, error) {
-	url := fmt.Sprintf("%s/%s", r.URL, name)
-	
-	resp, err := r.HTTPClient.Get(url)
+	if reg := r.ForPackage(name); reg != r {
+		return reg.GetPackage(name)
+	}
+
+	url := fmt.Sprintf("%s/%s", r.URL, escapeName(name))
+	return r.fetchPackageInfo(url, name)
+}
+
+// GetPackageVersion gets the metadata of a specific package version,
+// including its dependencies
+func (r *Registry) GetPackageVersion(name, version string) (*PackageInfo, error) {
+	if reg := r.ForPackage(name); reg != r {
+		return reg.GetPackageVersion(name, version)
+	}
+
+	url := fmt.Sprintf("%s/%s/%s", r.URL, escapeName(name), url.PathEscape(version))
+	return r.fetchPackageInfo(url, name+"@"+version)
+}
+
+// GetVersions lists the published versions of a package through the
+// registry's versions endpoint, which is much smaller than the full
+// package document
+func (r *Registry) GetVersions(name string) (*VersionList, error) {
+	if reg := r.ForPackage(name); reg != r {
+		return reg.GetVersions(name)
+	}
+
+	body, err := r.get(fmt.Sprintf("%s/%s/versions", r.URL, escapeName(name)))
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch package: %w", err)
+		return nil, r.fetchError(name, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("package not found: %s", name)
+	var list VersionList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode versions of %s: %w", name, err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("registry error: %s", resp.Status)
+	return &list, nil
+}
+
+// Tarball returns the download URL of a package version from its
+// metadata. dist.tarball may point anywhere, such as a CDN, and relative
+// URLs are resolved against the registry. Registries that publish no
+// dist.tarball get the conventional TarballURL
+func (r *Registry) Tarball(info *PackageInfo) (string, error) {
+	if reg := r.ForPackage(info.Name); reg != r {
+		return reg.Tarball(info)
+	}
+
+	if info.Dist == nil || info.Dist.Tarball == "" {
+		return r.TarballURL(info.Name, info.Version)
+	}
+
+	base, err := url.Parse(r.URL + "/")
+	if err != nil {
+		return "", fmt.Errorf("invalid registry URL: %w", err)
+	}
+	ref, err := url.Parse(info.Dist.Tarball)
+	if err != nil {
+		return "", fmt.Errorf("invalid tarball URL for %s@%s: %w", info.Name, info.Version, err)
+	}
+
+	tarball := base.ResolveReference(ref)
+	if tarball.Scheme != "https" && tarball.Scheme != "http" {
+		return "", fmt.Errorf("unsupported tarball URL for %s@%s: %s", info.Name, info.Version, info.Dist.Tarball)
+	}
+	return tarball.String(), nil
+}
+
+func (r *Registry) fetchPackageInfo(url, name string) (*PackageInfo, error) {
+	body, err := r.get(url)
+	if err != nil {
+		return nil, r.fetchError(name, err)
 	}
 
 	var info PackageInfo
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+	if err := json.Unmarshal(body, &info); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	return &info, nil
 }
 
+// fetchError turns an error from get into one that names the package
+func (r *Registry) fetchError(name string, err error) error {
+	switch {
+	case errors.Is(err, errNotFound):
+		return fmt.Errorf("%w: %s", ErrPackageNotFound, name)
+	case errors.Is(err, ErrNotCached):
+		return fmt.Errorf("%s is not in the cache; run once without --offline to fetch it: %w", name, err)
+	default:
+		return fmt.Errorf("failed to fetch package: %w", err)
+	}
+}
+
 // GetLatestVersion gets the latest version of a package
 func (r *Registry) GetLatestVersion(name string) (string, error) {
 	// Try to get from registry
@@ -99,32 +299,63 @@ func (r *Registry) GetLatestVersion(name string) (string, error) {
 	return "", err
 }
 
-// Search searches for packages
-func (r *Registry) Search(query string) ([]PackageInfo, error) {
-	url := fmt.Sprintf("%s/-/v1/search?text=%s", r.URL, query)
-	
-	resp, err := r.HTTPClient.Get(url)
-	if err != nil {
-		// Fallback to mock results for demonstration
-		return r.mockSearch(query), nil
+// Search searches for packages. Keyword and author filters are sent to the
+// registry and applied again to the returned page, so registries that
+// ignore them never return packages that do not match
+func (r *Registry) Search(query string, opts SearchOptions) (*SearchResult, error) {
+	if opts.Sort != "" && !validSort(opts.Sort) {
+		return nil, fmt.Errorf("invalid sort %q (use %s)", opts.Sort, strings.Join(SearchSorts, ", "))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return r.mockSearch(query), nil
+	params := url.Values{}
+	params.Set("text", query)
+	if opts.Size > 0 {
+		params.Set("size", strconv.Itoa(opts.Size))
+	}
+	if opts.From > 0 {
+		params.Set("from", strconv.Itoa(opts.From))
+	}
+	if len(opts.Keywords) > 0 {
+		params.Set("keywords", strings.Join(opts.Keywords, ","))
+	}
+	if opts.Author != "" {
+		params.Set("author", opts.Author)
+	}
+	if opts.Sort != "" {
+		params.Set("sort", opts.Sort)
+	}
+
+	body, err := r.get(fmt.Sprintf("%s/-/v1/search?%s", r.URL, params.Encode()))
+	switch {
+	case errors.Is(err, ErrNotCached):
+		return nil, fmt.Errorf("search results for %q are not in the cache: %w", query, err)
+	case errors.Is(err, errNotFound):
+		return nil, fmt.Errorf("%s does not support search", r.URL)
+	case err != nil:
+		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
 	var result SearchResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return r.mockSearch(query), nil
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode search results: %w", err)
 	}
 
-	return result.Packages, nil
+	result.Packages = filterPackages(result.Packages, opts)
+	sortPackages(result.Packages, opts.Sort)
+
+	return &result, nil
 }
 
 // Publish publishes a package to the registry
-func (r *Registry) Publish(pkg *config.Package, tarballPath string) error {
-	url := fmt.Sprintf("%s/%s", r.URL, pkg.Name)
+func (r *Registry) Publish(pkg *config.Package, tarballPath string, opts PublishOptions) error {
+	if reg := r.ForPackage(pkg.Name); reg != r {
+		return reg.Publish(pkg, tarballPath, opts)
+	}
+	if r.Offline {
+		return fmt.Errorf("cannot publish in offline mode")
+	}
+
+	url := fmt.Sprintf("%s/%s", r.URL, escapeName(pkg.Name))
 
 	// Open tarball
 	file, err := os.Open(tarballPath)
@@ -141,6 +372,20 @@ func (r *Registry) Publish(pkg *config.Package, tarballPath string) error {
 
 	req.Header.Set("Content-Type", "application/gzip")
 	req.Header.Set("X-Droy-Version", pkg.Version)
+	if opts.Tag != "" {
+		req.Header.Set("X-Droy-Tag", opts.Tag)
+	}
+	if opts.Access != "" {
+		req.Header.Set("X-Droy-Access", opts.Access)
+	}
+	if opts.Signature != nil {
+		sig, err := opts.Signature.Marshal()
+		if err != nil {
+			return fmt.Errorf("failed to encode signature: %w", err)
+		}
+		req.Header.Set("X-Droy-Signature", base64.StdEncoding.EncodeToString(sig))
+	}
+	r.authorize(req)
 
 	// Send request
 	resp, err := r.HTTPClient.Do(req)
@@ -149,6 +394,10 @@ func (r *Registry) Publish(pkg *config.Package, tarballPath string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("publish failed: %s - run 'droy-pm login' first", resp.Status)
+	}
+
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("publish failed: %s - %s", resp.Status, string(body))
@@ -157,6 +406,37 @@ func (r *Registry) Publish(pkg *config.Package, tarballPath string) error {
 	return nil
 }
 
+// GetGitHubManifest fetches the droy.toml of a GitHub repository at the
+// given tag or branch, which must have one
+func (r *Registry) GetGitHubManifest(repo, ref string) (*config.Package, error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("invalid GitHub repository")
+	}
+
+	if ref == "" || ref == "latest" || ref == "*" {
+		ref = "HEAD"
+	}
+
+	url := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/droy.toml", parts[1], parts[2], ref)
+
+	data, err := r.get(url)
+
+	// A missing droy.toml is as likely a wrong ref as a package without
+	// dependencies, so it is never taken to mean the latter
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("%s has no droy.toml at %s", repo, ref)
+	}
+	if errors.Is(err, ErrNotCached) {
+		return nil, fmt.Errorf("droy.toml of %s@%s is not in the cache: %w", repo, ref, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch droy.toml: %w", err)
+	}
+
+	return config.ParsePackageConfig(data)
+}
+
 func (r *Registry) getLatestGitHubVersion(repo string) (string, error) {
 	parts := strings.Split(repo, "/")
 	if len(parts) < 3 {
@@ -167,51 +447,28 @@ func (r *Registry) getLatestGitHubVersion(repo string) (string, error) {
 	repoName := parts[2]
 
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", owner, repoName)
-	
-	resp, err := r.HTTPClient.Get(url)
-	if err != nil {
-		return "latest", nil // Fallback
-	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	body, err := r.get(url)
+
+	// Without releases "latest" means the default branch
+	if errors.Is(err, errNotFound) {
 		return "latest", nil
 	}
+	if err != nil {
+		return "", r.fetchError(repo, err)
+	}
 
 	var release struct {
 		TagName string `json:"tag_name"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+	if err := json.Unmarshal(body, &release); err != nil {
+		return "", fmt.Errorf("failed to decode release of %s: %w", repo, err)
+	}
+	if release.TagName == "" {
 		return "latest", nil
 	}
 
-	return strings.TrimPrefix(release.TagName, "v"), nil
-}
-
-func (r *Registry) mockSearch(query string) []PackageInfo {
-	// Mock search results for demonstration
-	return []PackageInfo{
-		{
-			Name:        "droy-http",
-			Version:     "1.2.0",
-			Description: "HTTP client library for Droy",
-			Author:      "droy-team",
-			License:     "MIT",
-		},
-		{
-			Name:        "droy-json",
-			Version:     "2.0.1",
-			Description: "JSON parsing and serialization",
-			Author:      "community",
-			License:     "Apache-2.0",
-		},
-		{
-			Name:        "droy-cli",
-			Version:     "0.5.0",
-			Description: "Command-line interface utilities",
-			Author:      "droy-team",
-			License:     "MIT",
-		},
-	}
+	// The tag is cloned and fetched by name, so it keeps any "v" prefix
+	return release.TagName, nil
 }
@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTarball(t *testing.T) {
+	reg := New("https://registry.example.com/api")
+
+	tests := []struct {
+		name, tarball, want string
+	}{
+		{"lib", "", "https://registry.example.com/api/lib/-/lib-1.0.0.tgz"},
+		{"lib", "https://cdn.example.com/lib-1.0.0.tgz", "https://cdn.example.com/lib-1.0.0.tgz"},
+		{"lib", "files/lib-1.0.0.tgz", "https://registry.example.com/api/files/lib-1.0.0.tgz"},
+		{"lib", "/files/lib-1.0.0.tgz", "https://registry.example.com/files/lib-1.0.0.tgz"},
+	}
+	for _, tt := range tests {
+		info := &PackageInfo{Name: tt.name, Version: "1.0.0"}
+		if tt.tarball != "" {
+			info.Dist = &DistInfo{Tarball: tt.tarball}
+		}
+		got, err := reg.Tarball(info)
+		if err != nil || got != tt.want {
+			t.Errorf("Tarball(%q) = %q, %v, want %q", tt.tarball, got, err, tt.want)
+		}
+	}
+
+	for _, tarball := range []string{"file:///etc/passwd", "ftp://example.com/lib.tgz"} {
+		info := &PackageInfo{Name: "lib", Version: "1.0.0", Dist: &DistInfo{Tarball: tarball}}
+		if got, err := reg.Tarball(info); err == nil {
+			t.Errorf("Tarball(%q) = %q, want an error", tarball, got)
+		}
+	}
+}
+
+func TestTokenOnlySentToRegistryHost(t *testing.T) {
+	var auth []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+	})
+	registrySrv := httptest.NewServer(handler)
+	defer registrySrv.Close()
+	cdn := httptest.NewServer(handler)
+	defer cdn.Close()
+
+	reg := New(registrySrv.URL)
+	reg.Token = "secret"
+
+	for _, url := range []string{registrySrv.URL + "/lib/-/lib-1.0.0.tgz", cdn.URL + "/lib-1.0.0.tgz"} {
+		resp, err := reg.Fetch(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(auth) != 2 || auth[0] != "Bearer secret" || auth[1] != "" {
+		t.Errorf("Authorization headers = %q, want the token for the registry only", auth)
+	}
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// Scope returns the scope of a package name, e.g. "@acme" for
+// "@acme/utils", or "" for an unscoped package
+func Scope(name string) string {
+	if !strings.HasPrefix(name, "@") {
+		return ""
+	}
+	scope, _, _ := strings.Cut(name, "/")
+	return scope
+}
+
+// SplitName splits a package name into its scope and base name. Scoped
+// names must have exactly one slash, as in "@acme/utils"
+func SplitName(name string) (scope, base string, err error) {
+	if !strings.HasPrefix(name, "@") {
+		return "", name, nil
+	}
+
+	scope, base, ok := strings.Cut(name, "/")
+	if !ok || base == "" || strings.Contains(base, "/") || !config.ValidScope(scope) {
+		return "", "", fmt.Errorf("invalid scoped package name %q, expected @scope/name", name)
+	}
+	return scope, base, nil
+}
+
+// escapeName encodes a package name as a single URL path segment, so
+// "@acme/utils" becomes "@acme%2Futils"
+func escapeName(name string) string {
+	return url.PathEscape(name)
+}
+
+// ForPackage returns the client for the registry that serves a package:
+// the one mapped to its scope in Scopes, or r itself
+func (r *Registry) ForPackage(name string) *Registry {
+	if scope := Scope(name); scope != "" {
+		if reg, ok := r.Scopes[scope]; ok && reg != nil {
+			return reg
+		}
+	}
+	return r
+}
+
+// TarballURL returns the conventional download URL of a package version,
+// <registry>/<name>/-/<base>-<version>.tgz, e.g.
+// https://registry.droy-lang.org/@acme/utils/-/utils-1.0.0.tgz
+func (r *Registry) TarballURL(name, version string) (string, error) {
+	if reg := r.ForPackage(name); reg != r {
+		return reg.TarballURL(name, version)
+	}
+
+	scope, base, err := SplitName(name)
+	if err != nil {
+		return "", err
+	}
+
+	path := url.PathEscape(base)
+	if scope != "" {
+		path = url.PathEscape(scope) + "/" + path
+	}
+	file := url.PathEscape(fmt.Sprintf("%s-%s.tgz", base, version))
+
+	return fmt.Sprintf("%s/%s/-/%s", r.URL, path, file), nil
+}
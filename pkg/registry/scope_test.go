@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		name, scope, base string
+		ok                bool
+	}{
+		{"utils", "", "utils", true},
+		{"@acme/utils", "@acme", "utils", true},
+		{"@acme", "", "", false},
+		{"@acme/", "", "", false},
+		{"@acme/utils/extra", "", "", false},
+		{"@Acme/utils", "", "", false},
+	}
+
+	for _, tt := range tests {
+		scope, base, err := SplitName(tt.name)
+		if (err == nil) != tt.ok {
+			t.Errorf("SplitName(%q) error = %v, want ok = %v", tt.name, err, tt.ok)
+			continue
+		}
+		if scope != tt.scope || base != tt.base {
+			t.Errorf("SplitName(%q) = %q, %q, want %q, %q", tt.name, scope, base, tt.scope, tt.base)
+		}
+		if tt.ok && Scope(tt.name) != tt.scope {
+			t.Errorf("Scope(%q) = %q, want %q", tt.name, Scope(tt.name), tt.scope)
+		}
+	}
+}
+
+func TestTarballURL(t *testing.T) {
+	reg := New("https://registry.example.com")
+	tests := map[string]string{
+		"utils":       "https://registry.example.com/utils/-/utils-1.0.0.tgz",
+		"@acme/utils": "https://registry.example.com/@acme/utils/-/utils-1.0.0.tgz",
+	}
+	for name, want := range tests {
+		got, err := reg.TarballURL(name, "1.0.0")
+		if err != nil || got != want {
+			t.Errorf("TarballURL(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
+
+// namingServer answers every package request with the registry's own name
+func namingServer(t *testing.T, registryName string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(PackageInfo{Name: r.URL.EscapedPath(), Description: registryName})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestScopedPackagesUseTheirRegistry(t *testing.T) {
+	public := namingServer(t, "public")
+	private := namingServer(t, "acme")
+
+	reg := New(public.URL)
+	reg.CacheDir = t.TempDir()
+	acme := New(private.URL)
+	acme.CacheDir = t.TempDir()
+	reg.Scopes = map[string]*Registry{"@acme": acme}
+
+	tests := []struct {
+		name, registry, path string
+	}{
+		{"utils", "public", "/utils"},
+		{"@acme/utils", "acme", "/@acme%2Futils"},
+		{"@other/utils", "public", "/@other%2Futils"},
+	}
+	for _, tt := range tests {
+		info, err := reg.GetPackage(tt.name)
+		if err != nil {
+			t.Fatalf("GetPackage(%q): %v", tt.name, err)
+		}
+		if info.Description != tt.registry || info.Name != tt.path {
+			t.Errorf("GetPackage(%q) was served by %s at %s, want %s at %s", tt.name, info.Description, info.Name, tt.registry, tt.path)
+		}
+	}
+
+	if got := reg.ForPackage("@acme/utils"); got != acme {
+		t.Error("ForPackage(@acme/utils) is not the @acme registry")
+	}
+	url, err := reg.TarballURL("@acme/utils", "1.0.0")
+	if err != nil || url != private.URL+"/@acme/utils/-/utils-1.0.0.tgz" {
+		t.Errorf("TarballURL(@acme/utils) = %q, %v, want it on the @acme registry", url, err)
+	}
+}
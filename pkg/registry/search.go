@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultSearchSize is the page size the registry uses when none is given
+const DefaultSearchSize = 20
+
+// SearchSorts lists the accepted SearchOptions.Sort values. The registry
+// does the sorting; "name" is also reapplied locally
+var SearchSorts = []string{"relevance", "name", "downloads", "updated"}
+
+// SearchOptions page and narrow a search
+type SearchOptions struct {
+	Size int
+	From int
+
+	// Keywords must all be present on a package; Author must match its
+	// author, ignoring case
+	Keywords []string
+	Author   string
+
+	Sort string
+}
+
+func validSort(sort string) bool {
+	for _, s := range SearchSorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPackages drops packages that do not match the keyword and author
+// filters
+func filterPackages(packages []PackageInfo, opts SearchOptions) []PackageInfo {
+	if len(opts.Keywords) == 0 && opts.Author == "" {
+		return packages
+	}
+
+	var matched []PackageInfo
+	for _, pkg := range packages {
+		if opts.Author != "" && !strings.EqualFold(pkg.Author, opts.Author) {
+			continue
+		}
+		if !hasKeywords(pkg, opts.Keywords) {
+			continue
+		}
+		matched = append(matched, pkg)
+	}
+	return matched
+}
+
+func hasKeywords(pkg PackageInfo, keywords []string) bool {
+	for _, want := range keywords {
+		found := false
+		for _, keyword := range pkg.Keywords {
+			if strings.EqualFold(keyword, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sortPackages orders packages by name; the other sorts need data only
+// the registry has, so its order is kept
+func sortPackages(packages []PackageInfo, by string) {
+	if by == "name" {
+		sort.SliceStable(packages, func(i, j int) bool {
+			return packages[i].Name < packages[j].Name
+		})
+	}
+}
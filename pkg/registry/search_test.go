@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSearchSendsQueryAndFilters(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/-/v1/search" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+
+		// The registry ignores the filters and sorting
+		json.NewEncoder(w).Encode(SearchResult{Total: 42, Packages: []PackageInfo{
+			{Name: "web-b", Author: "Alice", Keywords: []string{"http", "server"}},
+			{Name: "web-c", Author: "bob", Keywords: []string{"http", "server"}},
+			{Name: "web-a", Author: "alice", Keywords: []string{"HTTP", "Server", "json"}},
+			{Name: "web-d", Author: "alice", Keywords: []string{"http"}},
+		}})
+	}))
+	defer srv.Close()
+
+	reg := New(srv.URL)
+	reg.CacheDir = t.TempDir()
+	result, err := reg.Search("web & more", SearchOptions{
+		Size:     10,
+		From:     20,
+		Keywords: []string{"http", "server"},
+		Author:   "alice",
+		Sort:     "name",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"text":     "web & more",
+		"size":     "10",
+		"from":     "20",
+		"keywords": "http,server",
+		"author":   "alice",
+		"sort":     "name",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("query %s = %q, want %q", key, got, value)
+		}
+	}
+
+	var names []string
+	for _, pkg := range result.Packages {
+		names = append(names, pkg.Name)
+	}
+	if len(names) != 2 || names[0] != "web-a" || names[1] != "web-b" {
+		t.Errorf("packages = %v, want [web-a web-b]", names)
+	}
+	if result.Total != 42 {
+		t.Errorf("total = %d, want the registry's 42", result.Total)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	if _, err := New("https://registry.example.com").Search("web", SearchOptions{Sort: "stars"}); err == nil {
+		t.Error("Search accepted an unknown sort")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	reg := New(srv.URL)
+	reg.CacheDir = t.TempDir()
+	result, err := reg.Search("web", SearchOptions{})
+	if err == nil {
+		t.Errorf("Search with a failing registry = %+v, want an error instead of results", result)
+	}
+
+	reg = New("http://127.0.0.1:1")
+	reg.CacheDir = t.TempDir()
+	if result, err := reg.Search("web", SearchOptions{}); err == nil {
+		t.Errorf("Search without a registry = %+v, want an error instead of results", result)
+	}
+}
@@ -0,0 +1,33 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/droy-go/droy-pm/pkg/signing"
+)
+
+// signatureURL returns the endpoint holding the detached signature of a
+// package version, /:package/:version/signature
+func (r *Registry) signatureURL(name, version string) string {
+	return fmt.Sprintf("%s/%s/%s/signature", r.URL, escapeName(name), url.PathEscape(version))
+}
+
+// GetSignature fetches the detached signature of a package version,
+// returning signing.ErrUnsigned if it has none
+func (r *Registry) GetSignature(name, version string) (*signing.Signature, error) {
+	if reg := r.ForPackage(name); reg != r {
+		return reg.GetSignature(name, version)
+	}
+
+	body, err := r.get(r.signatureURL(name, version))
+	if errors.Is(err, errNotFound) {
+		return nil, signing.ErrUnsigned
+	}
+	if err != nil {
+		return nil, r.fetchError(name+"@"+version, err)
+	}
+
+	return signing.ParseSignature(body)
+}
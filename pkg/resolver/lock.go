@@ -0,0 +1,182 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// Dependencies returns the dependencies of a package version seen during
+// the last resolution
+func (r *Resolver) Dependencies(name, version string) map[string]string {
+	return r.manifests[name+"@"+version]
+}
+
+// BuildLock creates a lock file from the last resolution. Resolved URLs and
+// integrity hashes are carried over from previous for unchanged versions
+func (r *Resolver) BuildLock(pkg *config.Package, deps map[string]string, previous *config.LockFile) *config.LockFile {
+	lock := &config.LockFile{
+		Version:         pkg.Version,
+		LockfileVersion: config.LockfileVersion,
+		Dependencies:    make(map[string]string),
+		Packages:        make(map[string]*config.LockPackage),
+	}
+
+	for name := range deps {
+		lock.Dependencies[name] = r.resolved[name]
+	}
+
+	for name, version := range r.resolved {
+		entry := &config.LockPackage{
+			Version:      version,
+			Dependencies: r.Dependencies(name, version),
+		}
+		if previous != nil {
+			if old := previous.Packages[name]; old != nil && old.Version == version {
+				entry.Resolved = old.Resolved
+				entry.Integrity = old.Integrity
+			}
+		}
+		lock.Packages[name] = entry
+	}
+
+	return lock
+}
+
+// CheckLock verifies that a lock file still satisfies the given
+// dependencies: each one is locked to a matching version, every locked
+// package's own dependencies are locked too, and nothing else is locked
+func CheckLock(lock *config.LockFile, deps map[string]string) error {
+	for _, name := range sortedKeys(deps) {
+		version, ok := lock.Dependencies[name]
+		if !ok {
+			return fmt.Errorf("%s is not in droy.lock", name)
+		}
+		if !Satisfies(version, deps[name]) {
+			return fmt.Errorf("%s is locked at %s, which does not satisfy %s", name, version, deps[name])
+		}
+	}
+
+	reachable := make(map[string]bool)
+	queue := sortedKeys(deps)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if reachable[name] {
+			continue
+		}
+		reachable[name] = true
+
+		entry, ok := lock.Packages[name]
+		if !ok {
+			return fmt.Errorf("%s has no package entry in droy.lock", name)
+		}
+
+		for _, dep := range sortedKeys(entry.Dependencies) {
+			spec := entry.Dependencies[dep]
+			locked, ok := lock.Packages[dep]
+			if !ok {
+				return fmt.Errorf("%s@%s depends on %s, which is not in droy.lock", name, entry.Version, dep)
+			}
+			if !Satisfies(locked.Version, spec) {
+				return fmt.Errorf("%s@%s depends on %s %s, but droy.lock has %s",
+					name, entry.Version, dep, spec, locked.Version)
+			}
+			queue = append(queue, dep)
+		}
+	}
+
+	var extra []string
+	for name := range lock.Packages {
+		if !reachable[name] {
+			extra = append(extra, name)
+		}
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		return fmt.Errorf("droy.lock contains packages that are no longer required: %v", extra)
+	}
+
+	return nil
+}
+
+// CheckLocal verifies that the lock entries of local packages, such as
+// workspace members, still record their manifest's version and
+// dependencies. CheckLock cannot tell when a member's droy.toml changes,
+// since the lock is all it sees of the member
+func CheckLocal(lock *config.LockFile, local map[string]*config.Package) error {
+	for _, name := range sortedPackageNames(local) {
+		entry, ok := lock.Packages[name]
+		if !ok {
+			continue
+		}
+		pkg := local[name]
+		if entry.Version != pkg.Version {
+			return fmt.Errorf("%s is locked at %s, but its droy.toml has version %s", name, entry.Version, pkg.Version)
+		}
+
+		deps := make(map[string]string)
+		for dep, spec := range pkg.Dependencies {
+			deps[dep] = spec
+		}
+		for dep, spec := range pkg.DevDependencies {
+			deps[dep] = spec
+		}
+		if len(deps) != len(entry.Dependencies) {
+			return fmt.Errorf("the dependencies of %s changed since droy.lock was written", name)
+		}
+		for dep, spec := range deps {
+			if locked, ok := entry.Dependencies[dep]; !ok || locked != spec {
+				return fmt.Errorf("the dependencies of %s changed since droy.lock was written", name)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedPackageNames(m map[string]*config.Package) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LockTree builds the dependency tree of deps recorded in a lock file.
+// Packages missing from the lock are left unresolved
+func LockTree(lock *config.LockFile, deps map[string]string) *DependencyTree {
+	return buildTree(deps, func(name string) (string, map[string]string) {
+		entry, ok := lock.Packages[name]
+		if !ok {
+			return "", nil
+		}
+		return entry.Version, entry.Dependencies
+	})
+}
+
+// LockedVersions returns the locked version of every package reachable
+// from deps, i.e. the set of packages that has to be installed for them
+func LockedVersions(lock *config.LockFile, deps map[string]string) map[string]string {
+	result := make(map[string]string)
+
+	queue := sortedKeys(deps)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, seen := result[name]; seen {
+			continue
+		}
+
+		entry, ok := lock.Packages[name]
+		if !ok {
+			continue
+		}
+		result[name] = entry.Version
+
+		queue = append(queue, sortedKeys(entry.Dependencies)...)
+	}
+
+	return result
+}
@@ -0,0 +1,79 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+func TestLockTree(t *testing.T) {
+	lock := &config.LockFile{Packages: map[string]*config.LockPackage{
+		"web":  {Version: "1.2.0", Dependencies: map[string]string{"json": "^2.0.0", "log": "^1.0.0"}},
+		"json": {Version: "2.1.0"},
+		"log":  {Version: "1.0.0", Dependencies: map[string]string{"web": "^1.0.0"}},
+	}}
+
+	tree := LockTree(lock, map[string]string{"web": "^1.0.0", "json": "^2.0.0", "missing": "^1.0.0"})
+
+	web := tree.Root.Children["web"]
+	if web == nil || web.Version != "1.2.0" || len(web.Children) != 2 {
+		t.Fatalf("web = %+v, want 1.2.0 with json and log", web)
+	}
+	if json := web.Children["json"]; json.Version != "2.1.0" || json.Parent != web {
+		t.Errorf("web's json = %+v", json)
+	}
+
+	// The cycle back to web is recorded but not expanded again
+	cycle := web.Children["log"].Children["web"]
+	if cycle == nil || len(cycle.Children) != 0 {
+		t.Errorf("log's web = %+v, want an unexpanded node", cycle)
+	}
+
+	if missing := tree.Root.Children["missing"]; missing.Resolved {
+		t.Errorf("missing = %+v, want it unresolved", missing)
+	}
+
+	flat := tree.Flatten()
+	if len(flat) != 4 || flat["log"] != "1.0.0" {
+		t.Errorf("Flatten = %v", flat)
+	}
+}
+
+func TestCheckLocal(t *testing.T) {
+	lock := &config.LockFile{
+		Dependencies: map[string]string{"app": "1.0.0", "web": "1.1.0"},
+		Packages: map[string]*config.LockPackage{
+			"app":  {Version: "1.0.0", Resolved: "file:packages/app", Dependencies: map[string]string{"web": "^1.0.0", "json": "^2.0.0"}},
+			"web":  {Version: "1.1.0", Resolved: "file:packages/web"},
+			"json": {Version: "2.1.0"},
+		},
+	}
+	members := func(app *config.Package) map[string]*config.Package {
+		return map[string]*config.Package{
+			"app": app,
+			"web": {Name: "web", Version: "1.1.0"},
+		}
+	}
+
+	current := &config.Package{
+		Name:            "app",
+		Version:         "1.0.0",
+		Dependencies:    map[string]string{"web": "^1.0.0"},
+		DevDependencies: map[string]string{"json": "^2.0.0"},
+	}
+	if err := CheckLocal(lock, members(current)); err != nil {
+		t.Errorf("CheckLocal with unchanged members: %v", err)
+	}
+
+	changed := map[string]*config.Package{
+		"added dependency":   {Name: "app", Version: "1.0.0", Dependencies: map[string]string{"web": "^1.0.0", "json": "^2.0.0", "log": "^1.0.0"}},
+		"removed dependency": {Name: "app", Version: "1.0.0", Dependencies: map[string]string{"web": "^1.0.0"}},
+		"changed range":      {Name: "app", Version: "1.0.0", Dependencies: map[string]string{"web": "^1.0.0", "json": "^3.0.0"}},
+		"bumped version":     {Name: "app", Version: "1.1.0", Dependencies: map[string]string{"web": "^1.0.0", "json": "^2.0.0"}},
+	}
+	for desc, app := range changed {
+		if err := CheckLocal(lock, members(app)); err == nil {
+			t.Errorf("CheckLocal accepted a member with a %s", desc)
+		}
+	}
+}
@@ -1,115 +1,254 @@
 package resolver
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
 	"sort"
 	"strings"
 
+	"github.com/droy-go/droy-pm/pkg/config"
 	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/semver"
 )
 
 // Resolver handles dependency resolution
 type Resolver struct {
+	// Root names the project being resolved in conflict explanations
+	Root string
+
+	// Prefer maps packages to a version to try before any other allowed
+	// one, such as the version in droy.lock
+	Prefer map[string]string
+
+	// Skip reports versions that must never be chosen, such as versions
+	// with known vulnerabilities
+	Skip func(name, version string) bool
+
+	// Local maps packages that are provided locally, such as workspace
+	// members, to their manifest. Only the local version is a candidate,
+	// and its dependencies and dev dependencies are read from the manifest
+	Local map[string]*config.Package
+
 	registry  *registry.Registry
 	resolved  map[string]string
-	conflicts map[string][]string
+	packages  map[string]*registry.PackageInfo
+	manifests map[string]map[string]string
 }
 
 // New creates a new dependency resolver
 func New() *Resolver {
+	return NewWithRegistry(registry.New(""))
+}
+
+// NewWithRegistry creates a dependency resolver that reads package
+// metadata through reg
+func NewWithRegistry(reg *registry.Registry) *Resolver {
 	return &Resolver{
-		registry:  registry.New(""),
+		Root:      "root",
+		registry:  reg,
 		resolved:  make(map[string]string),
-		conflicts: make(map[string][]string),
+		packages:  make(map[string]*registry.PackageInfo),
+		manifests: make(map[string]map[string]string),
 	}
 }
 
-// Resolve resolves all dependencies
+// Resolve resolves all dependencies, including transitive ones, into a
+// flat map of package name to version.
+//
+// When the newest candidates conflict, older versions are tried until a
+// consistent set is found. If none exists, the returned *ConflictError
+// explains which chain of requirements could not be satisfied.
 func (r *Resolver) Resolve(deps map[string]string) (map[string]string, error) {
-	r.resolved = make(map[string]string)
-	r.conflicts = make(map[string][]string)
+	s := newSolver(r)
 
-	for name, version := range deps {
-		if err := r.resolveDependency(name, version); err != nil {
-			return nil, err
-		}
+	resolved, err := s.solve(deps)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(r.conflicts) > 0 {
-		return nil, r.formatConflicts()
+	r.resolved = resolved
+	return r.resolved, nil
+}
+
+// availableVersions returns the published versions of a package
+func (r *Resolver) availableVersions(name string) ([]string, error) {
+	if local, ok := r.Local[name]; ok {
+		return []string{local.Version}, nil
 	}
 
-	return r.resolved, nil
+	info, err := r.packageInfo(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(info.Versions) == 0 && info.Version != "" {
+		return []string{info.Version}, nil
+	}
+	return info.Versions, nil
+}
+
+// latestVersion returns the version a "latest" spec refers to
+func (r *Resolver) latestVersion(name string) (string, error) {
+	if isGitHubPackage(name) {
+		return r.registry.GetLatestVersion(name)
+	}
+
+	info, err := r.packageInfo(name)
+	if err != nil {
+		return "", err
+	}
+	return SelectVersion(info, "latest")
 }
 
-func (r *Resolver) resolveDependency(name, versionSpec string) error {
-	// Normalize version spec
-	versionSpec = normalizeVersionSpec(versionSpec)
+// packageInfo returns the published versions of a package from the
+// registry's versions endpoint, falling back to the full package document
+// for registries without one or when only that is cached
+func (r *Resolver) packageInfo(name string) (*registry.PackageInfo, error) {
+	if info, ok := r.packages[name]; ok {
+		return info, nil
+	}
 
-	// Check if already resolved
-	if resolvedVersion, exists := r.resolved[name]; exists {
-		if !r.satisfies(resolvedVersion, versionSpec) {
-			r.conflicts[name] = append(r.conflicts[name], versionSpec)
+	var info *registry.PackageInfo
+	list, err := r.registry.GetVersions(name)
+	switch {
+	case err == nil:
+		info = &registry.PackageInfo{Name: name, Version: list.Latest, Versions: list.Versions, DistTags: list.DistTags}
+	case errors.Is(err, registry.ErrPackageNotFound), errors.Is(err, registry.ErrNotCached):
+		info, err = r.registry.GetPackage(name)
+		if err != nil {
+			return nil, err
 		}
-		return nil
+	default:
+		return nil, err
 	}
 
-	// Get latest version that satisfies the spec
-	latest, err := r.registry.GetLatestVersion(name)
+	r.packages[name] = info
+	return info, nil
+}
+
+// Version returns the version of a registry package a spec selects, such
+// as the version a dist-tag points at
+func (r *Resolver) Version(name, spec string) (string, error) {
+	info, err := r.packageInfo(name)
 	if err != nil {
-		// For now, just use the version spec as-is
-		latest = versionSpec
+		return "", err
 	}
+	return SelectVersion(info, spec)
+}
 
-	if versionSpec != "latest" && versionSpec != "*" {
-		// In a real implementation, we'd check if latest satisfies the spec
-		// For now, just use latest
+// dependenciesOf returns the dependencies declared by a specific package
+// version, read from registry metadata, the repository's droy.toml or the
+// local manifest
+func (r *Resolver) dependenciesOf(name, version string) (map[string]string, error) {
+	key := name + "@" + version
+	if deps, ok := r.manifests[key]; ok {
+		return deps, nil
 	}
 
-	r.resolved[name] = latest
+	var deps map[string]string
+	if local, ok := r.Local[name]; ok {
+		deps = make(map[string]string)
+		for dep, spec := range local.Dependencies {
+			deps[dep] = spec
+		}
+		for dep, spec := range local.DevDependencies {
+			deps[dep] = spec
+		}
+	} else if isGitHubPackage(name) {
+		manifest, err := r.registry.GetGitHubManifest(name, version)
+		if err != nil {
+			return nil, err
+		}
+		deps = manifest.Dependencies
+	} else {
+		info, err := r.registry.GetPackageVersion(name, version)
+		if err != nil {
+			return nil, err
+		}
+		deps = info.Dependencies
+	}
 
-	// Resolve transitive dependencies
-	// In a real implementation, we'd read the package's dependencies
-	// and recursively resolve them
+	if deps == nil {
+		deps = make(map[string]string)
+	}
+	r.manifests[key] = deps
 
-	return nil
+	return deps, nil
 }
 
-func (r *Resolver) satisfies(version, spec string) bool {
-	// Simple version satisfaction check
-	// In a real implementation, this would handle semver properly
-	
+// SelectVersion picks the highest published version of a package that
+// satisfies the spec. Dist-tags such as "latest" or "next" select the
+// version the tag points at
+func SelectVersion(info *registry.PackageInfo, spec string) (string, error) {
+	spec = normalizeVersionSpec(spec)
+
+	versions := info.Versions
+	if len(versions) == 0 && info.Version != "" {
+		versions = []string{info.Version}
+	}
+
+	if registry.IsTag(spec) {
+		if version, ok := info.DistTags[spec]; ok {
+			return version, nil
+		}
+		if spec != registry.DefaultTag {
+			return "", fmt.Errorf("%s has no %q tag", info.Name, spec)
+		}
+		if info.Version != "" {
+			return info.Version, nil
+		}
+		if latest := semver.Max(versions); latest != "" {
+			return latest, nil
+		}
+		return "", fmt.Errorf("no versions published for %s", info.Name)
+	}
+
+	rng, err := semver.ParseRange(spec)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", info.Name, err)
+	}
+
+	version := semver.MaxSatisfying(versions, rng)
+	if version == "" {
+		return "", fmt.Errorf("no version of %s satisfies %s (available: %s)",
+			info.Name, spec, strings.Join(versions, ", "))
+	}
+
+	return version, nil
+}
+
+// Satisfies reports whether a resolved version matches a version spec
+func Satisfies(version, spec string) bool {
+	spec = normalizeVersionSpec(spec)
 	if spec == "*" || spec == "latest" {
 		return true
 	}
 
-	// Handle ^ prefix (compatible with)
-	if strings.HasPrefix(spec, "^") {
-		return true // Simplified
+	// Versions that are not semver (e.g. git refs) only match themselves
+	if !semver.IsValid(version) {
+		return version == spec
 	}
 
-	// Handle ~ prefix (approximately equivalent)
-	if strings.HasPrefix(spec, "~") {
-		return true // Simplified
+	// A dist-tag moves between versions; whichever it was resolved to
+	// still satisfies it
+	if registry.IsTag(spec) {
+		return true
 	}
 
-	// Handle >=, <=, >, < prefixes
-	if match, _ := regexp.MatchString(`^[><=]+`, spec); match {
-		return true // Simplified
-	}
+	return semver.Satisfies(version, spec)
+}
 
-	// Exact version match
-	return version == spec
+func isGitHubPackage(name string) bool {
+	return strings.HasPrefix(name, "github.com/")
 }
 
-func (r *Resolver) formatConflicts() error {
-	var messages []string
-	for name, specs := range r.conflicts {
-		messages = append(messages, fmt.Sprintf("%s: %v", name, specs))
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	sort.Strings(messages)
-	return fmt.Errorf("version conflicts:\n  %s", strings.Join(messages, "\n  "))
+	sort.Strings(keys)
+	return keys
 }
 
 func normalizeVersionSpec(spec string) string {
@@ -122,26 +261,54 @@ func normalizeVersionSpec(spec string) string {
 
 // ResolveTree resolves the full dependency tree
 func (r *Resolver) ResolveTree(deps map[string]string) (*DependencyTree, error) {
+	if _, err := r.Resolve(deps); err != nil {
+		return nil, err
+	}
+
+	return buildTree(deps, func(name string) (string, map[string]string) {
+		version := r.resolved[name]
+		return version, r.manifests[name+"@"+version]
+	}), nil
+}
+
+// buildTree expands deps into a tree, using lookup to find the version of
+// each package and its own dependencies
+func buildTree(deps map[string]string, lookup func(name string) (string, map[string]string)) *DependencyTree {
 	tree := &DependencyTree{
 		Root: &DependencyNode{
 			Name:     "root",
 			Version:  "1.0.0",
+			Resolved: true,
 			Children: make(map[string]*DependencyNode),
 		},
 	}
 
-	for name, version := range deps {
-		node := &DependencyNode{
-			Name:     name,
-			Version:  version,
-			Children: make(map[string]*DependencyNode),
-		}
-		tree.Root.Children[name] = node
+	for name := range deps {
+		addNode(tree.Root, name, lookup)
+	}
 
-		// In a real implementation, recursively resolve children
+	return tree
+}
+
+func addNode(parent *DependencyNode, name string, lookup func(name string) (string, map[string]string)) {
+	version, deps := lookup(name)
+	node := &DependencyNode{
+		Name:     name,
+		Version:  version,
+		Resolved: version != "",
+		Children: make(map[string]*DependencyNode),
+		Parent:   parent,
+	}
+	parent.Children[name] = node
+
+	// Stop at cycles; the package is already expanded further up
+	if parent.hasAncestor(name) {
+		return
 	}
 
-	return tree, nil
+	for dep := range deps {
+		addNode(node, dep, lookup)
+	}
 }
 
 // DependencyTree represents a dependency tree
@@ -165,6 +332,15 @@ func (t *DependencyTree) Flatten() map[string]string {
 	return result
 }
 
+func (n *DependencyNode) hasAncestor(name string) bool {
+	for node := n; node != nil; node = node.Parent {
+		if node.Name == name && node.Parent != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (n *DependencyNode) flatten(result map[string]string) {
 	if n.Name != "root" {
 		result[n.Name] = n.Version
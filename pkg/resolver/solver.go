@@ -0,0 +1,498 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/semver"
+)
+
+// maxSolverSteps bounds the number of decisions the solver may try before
+// giving up, so pathological graphs fail instead of hanging
+const maxSolverSteps = 10000
+
+// requirement is a version constraint placed on a package, either by the
+// root project or by a chosen version of another package
+type requirement struct {
+	name string
+	spec string
+	rng  *semver.Range
+	via  *decision // nil for requirements from the root project
+
+	// tag is set for dist-tag specs such as "next"; version is the
+	// version the tag points at, looked up when the requirement is checked
+	tag     bool
+	version string
+}
+
+// decision records the version chosen for a package and the requirement
+// that first brought the package into the graph
+type decision struct {
+	name    string
+	version string
+	reason  *requirement
+}
+
+// ConflictError explains why no consistent set of versions exists
+type ConflictError struct {
+	// Package is the package no version could be found for
+	Package string
+	// Chains holds one derivation per conflicting requirement, e.g.
+	// "app depends on droy-http ^2.0.0 (2.1.0) which depends on droy-json ^3.0.0"
+	Chains []string
+	// Available lists the published versions of Package
+	Available []string
+	// Tried lists the versions attempted for packages along the chains
+	Tried map[string][]string
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "version solving failed for %s:\n", e.Package)
+	for i, chain := range e.Chains {
+		switch {
+		case i == 0:
+			fmt.Fprintf(&b, "  %s,\n", chain)
+		case i == len(e.Chains)-1:
+			fmt.Fprintf(&b, "  but %s.\n", chain)
+		default:
+			fmt.Fprintf(&b, "  and %s,\n", chain)
+		}
+	}
+
+	if len(e.Chains) == 1 {
+		fmt.Fprintf(&b, "  but no version of %s matches", e.Package)
+	} else {
+		fmt.Fprintf(&b, "  No version of %s satisfies all of these requirements", e.Package)
+	}
+	if len(e.Available) > 0 {
+		fmt.Fprintf(&b, " (available: %s)", strings.Join(e.Available, ", "))
+	}
+	b.WriteString(".")
+
+	names := make([]string, 0, len(e.Tried))
+	for name := range e.Tried {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n  Tried %s %s; none avoid the conflict.", name, strings.Join(e.Tried[name], ", "))
+	}
+
+	return b.String()
+}
+
+// solver performs a backtracking search over package versions. Packages
+// are decided in the order they are discovered, newest version first; when
+// a choice leads to a dead end it is undone and the next older candidate
+// is tried.
+type solver struct {
+	resolver  *Resolver
+	decisions map[string]*decision
+	reqs      map[string][]*requirement
+	tried     map[string][]string
+	conflicts []conflict
+	steps     int
+}
+
+// conflict is a dead end found during the search: no candidate of the
+// package is allowed by all of its requirements
+type conflict struct {
+	name string
+	reqs []*requirement
+}
+
+func newSolver(r *Resolver) *solver {
+	return &solver{
+		resolver:  r,
+		decisions: make(map[string]*decision),
+		reqs:      make(map[string][]*requirement),
+		tried:     make(map[string][]string),
+	}
+}
+
+func (s *solver) solve(deps map[string]string) (map[string]string, error) {
+	names := sortedKeys(deps)
+	for _, name := range names {
+		req, err := newRequirement(name, deps[name], nil)
+		if err != nil {
+			return nil, err
+		}
+		s.reqs[name] = append(s.reqs[name], req)
+	}
+
+	ok, err := s.search(names)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.explainFailure()
+	}
+
+	resolved := make(map[string]string, len(s.decisions))
+	for name, d := range s.decisions {
+		resolved[name] = d.version
+	}
+	return resolved, nil
+}
+
+func (s *solver) search(pending []string) (bool, error) {
+	for len(pending) > 0 && s.decisions[pending[0]] != nil {
+		pending = pending[1:]
+	}
+	if len(pending) == 0 {
+		return true, nil
+	}
+
+	name, rest := pending[0], pending[1:]
+
+	candidates, err := s.candidates(name)
+	if err != nil {
+		return false, err
+	}
+	if len(candidates) == 0 {
+		s.recordConflict(name)
+		return false, nil
+	}
+
+	for _, version := range candidates {
+		s.steps++
+		if s.steps > maxSolverSteps {
+			return false, fmt.Errorf("dependency resolution gave up after %d steps", maxSolverSteps)
+		}
+
+		s.tried[name] = appendUnique(s.tried[name], version)
+
+		d := &decision{name: name, version: version, reason: s.reqs[name][0]}
+		s.decisions[name] = d
+
+		deps, err := s.resolver.dependenciesOf(name, version)
+		if err != nil {
+			return false, fmt.Errorf("failed to read dependencies of %s@%s: %w", name, version, err)
+		}
+
+		// Add the requirements of this version, failing fast if one rules
+		// out a package that has already been decided
+		var added, next []string
+		consistent := true
+		for _, dep := range sortedKeys(deps) {
+			req, err := newRequirement(dep, deps[dep], d)
+			if err != nil {
+				return false, err
+			}
+			s.reqs[dep] = append(s.reqs[dep], req)
+			added = append(added, dep)
+
+			if existing := s.decisions[dep]; existing != nil {
+				if err := s.resolveTag(req); err != nil {
+					return false, err
+				}
+				if !req.allows(existing.version) {
+					s.recordConflict(dep)
+					consistent = false
+					break
+				}
+				continue
+			}
+			next = append(next, dep)
+		}
+
+		if consistent {
+			queue := append(append([]string{}, rest...), next...)
+			solved, err := s.search(queue)
+			if err != nil {
+				return false, err
+			}
+			if solved {
+				return true, nil
+			}
+		}
+
+		// Undo this decision before trying the next candidate
+		for i := len(added) - 1; i >= 0; i-- {
+			dep := added[i]
+			s.reqs[dep] = s.reqs[dep][:len(s.reqs[dep])-1]
+		}
+		delete(s.decisions, name)
+	}
+
+	return false, nil
+}
+
+// resolveTag looks up the version a dist-tag requirement points at, once
+func (s *solver) resolveTag(req *requirement) error {
+	if !req.tag || req.version != "" {
+		return nil
+	}
+	info, err := s.resolver.packageInfo(req.name)
+	if err != nil {
+		return err
+	}
+	req.version, err = SelectVersion(info, req.spec)
+	return err
+}
+
+// candidates returns the versions of a package allowed by every current
+// requirement, newest first
+func (s *solver) candidates(name string) ([]string, error) {
+	reqs := s.reqs[name]
+
+	var versions []string
+	if local, ok := s.resolver.Local[name]; ok {
+		versions = []string{local.Version}
+	} else if isGitHubPackage(name) {
+		// GitHub packages are pinned by ref, so the refs themselves are
+		// the only candidates
+		for _, req := range reqs {
+			if req.spec != "latest" && req.spec != "*" {
+				versions = appendUnique(versions, req.spec)
+			}
+		}
+		if len(versions) == 0 {
+			latest, err := s.resolver.latestVersion(name)
+			if err != nil {
+				return nil, err
+			}
+			versions = []string{latest}
+		}
+	} else {
+		available, err := s.resolver.availableVersions(name)
+		if err != nil {
+			return nil, err
+		}
+		versions = sortDescending(available)
+
+		// Prefer the registry's latest tag when asked for "latest"
+		for _, req := range reqs {
+			if req.spec == "latest" {
+				if latest, err := s.resolver.latestVersion(name); err == nil {
+					versions = append([]string{latest}, removeVersion(versions, latest)...)
+				}
+				break
+			}
+		}
+
+		// Dist-tags other than latest pin the version they point at
+		for _, req := range reqs {
+			if err := s.resolveTag(req); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var result []string
+	for _, version := range versions {
+		if s.resolver.Skip != nil && s.resolver.Skip(name, version) {
+			continue
+		}
+		allowed := true
+		for _, req := range reqs {
+			if !req.allows(version) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			result = append(result, version)
+		}
+	}
+
+	// Keep the preferred version when it is still allowed
+	if preferred, ok := s.resolver.Prefer[name]; ok && containsVersion(result, preferred) {
+		result = append([]string{preferred}, removeVersion(result, preferred)...)
+	}
+
+	return result, nil
+}
+
+// maxRecordedConflicts caps how many dead ends are kept for the final
+// explanation
+const maxRecordedConflicts = 100
+
+func (s *solver) recordConflict(name string) {
+	if len(s.conflicts) >= maxRecordedConflicts {
+		return
+	}
+	reqs := append([]*requirement{}, s.reqs[name]...)
+	s.conflicts = append(s.conflicts, conflict{name: name, reqs: reqs})
+}
+
+// explainFailure picks the dead end that depends on the fewest decisions,
+// since it is the one no amount of backtracking could avoid. Ties go to the
+// first conflict found, which involves the newest versions
+func (s *solver) explainFailure() *ConflictError {
+	var best *conflict
+	bestDepth := -1
+	for i := range s.conflicts {
+		c := &s.conflicts[i]
+		depth := 0
+		for _, req := range c.reqs {
+			depth += requirementDepth(req)
+		}
+		if best == nil || depth < bestDepth {
+			best, bestDepth = c, depth
+		}
+	}
+
+	if best == nil {
+		return &ConflictError{Package: s.resolver.Root}
+	}
+
+	e := &ConflictError{
+		Package: best.name,
+		Tried:   make(map[string][]string),
+	}
+
+	// Lead with the longest derivations so direct requirements of the
+	// root project read as the "but ..." at the end
+	reqs := append([]*requirement{}, best.reqs...)
+	sort.SliceStable(reqs, func(i, j int) bool {
+		return requirementDepth(reqs[i]) > requirementDepth(reqs[j])
+	})
+
+	for _, req := range reqs {
+		e.Chains = append(e.Chains, s.explain(req))
+
+		for d := req.via; d != nil; d = d.reason.via {
+			if tried := s.tried[d.name]; len(tried) > 1 {
+				e.Tried[d.name] = tried
+			}
+		}
+	}
+
+	if !isGitHubPackage(best.name) {
+		if available, err := s.resolver.availableVersions(best.name); err == nil {
+			e.Available = sortDescending(available)
+		}
+	}
+
+	return e
+}
+
+// explain renders the derivation of a requirement from the root project,
+// e.g. "app depends on droy-http ^2.0.0 (2.1.0) which depends on droy-json ^3.0.0"
+func (s *solver) explain(req *requirement) string {
+	var chain []*requirement
+	for r := req; r != nil; {
+		chain = append([]*requirement{r}, chain...)
+		if r.via == nil {
+			break
+		}
+		r = r.via.reason
+	}
+
+	var b strings.Builder
+	b.WriteString(s.resolver.Root)
+	for i, r := range chain {
+		if i > 0 {
+			b.WriteString(" which")
+		}
+		fmt.Fprintf(&b, " depends on %s %s", r.name, r.spec)
+		if i < len(chain)-1 {
+			fmt.Fprintf(&b, " (%s)", chain[i+1].via.version)
+		}
+	}
+
+	return b.String()
+}
+
+// requirementDepth counts the decisions between the root project and req
+func requirementDepth(req *requirement) int {
+	depth := 0
+	for d := req.via; d != nil; d = d.reason.via {
+		depth++
+	}
+	return depth
+}
+
+func newRequirement(name, spec string, via *decision) (*requirement, error) {
+	req := &requirement{name: name, spec: normalizeVersionSpec(spec), via: via}
+
+	// GitHub refs and "latest" are matched without a range
+	if isGitHubPackage(name) || req.spec == "latest" {
+		return req, nil
+	}
+	if registry.IsTag(req.spec) {
+		req.tag = true
+		return req, nil
+	}
+
+	rng, err := semver.ParseRange(req.spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version spec for %s: %w", name, err)
+	}
+	req.rng = rng
+
+	return req, nil
+}
+
+func (q *requirement) allows(version string) bool {
+	if q.tag {
+		return version == q.version
+	}
+	if q.rng == nil {
+		if q.spec == "latest" || q.spec == "*" {
+			v, err := semver.Parse(version)
+			return err != nil || !v.IsPrerelease()
+		}
+		return version == q.spec
+	}
+
+	v, err := semver.Parse(version)
+	if err != nil {
+		return false
+	}
+	return q.rng.Contains(v)
+}
+
+func sortDescending(versions []string) []string {
+	parsed := make([]*semver.Version, 0, len(versions))
+	raw := make(map[*semver.Version]string, len(versions))
+	for _, version := range versions {
+		v, err := semver.Parse(version)
+		if err != nil {
+			continue
+		}
+		parsed = append(parsed, v)
+		raw[v] = version
+	}
+
+	semver.Sort(parsed)
+
+	result := make([]string, 0, len(parsed))
+	for i := len(parsed) - 1; i >= 0; i-- {
+		result = append(result, raw[parsed[i]])
+	}
+	return result
+}
+
+func removeVersion(versions []string, version string) []string {
+	result := make([]string, 0, len(versions))
+	for _, v := range versions {
+		if v != version {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func containsVersion(versions []string, version string) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,240 @@
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/droy-go/droy-pm/pkg/registry"
+)
+
+// fakePackage is a package served by newTestResolver, mapping each
+// published version to its dependencies
+type fakePackage struct {
+	versions map[string]map[string]string
+	tags     map[string]string
+}
+
+// newTestResolver returns a resolver reading metadata from a registry
+// serving packages
+func newTestResolver(t *testing.T, packages map[string]fakePackage) *Resolver {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		pkg, ok := packages[parts[0]]
+		if !ok || len(parts) != 2 {
+			http.NotFound(w, r)
+			return
+		}
+
+		if parts[1] == "versions" {
+			list := registry.VersionList{DistTags: pkg.tags}
+			for version := range pkg.versions {
+				list.Versions = append(list.Versions, version)
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+
+		deps, ok := pkg.versions[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(registry.PackageInfo{Name: parts[0], Version: parts[1], Dependencies: deps})
+	}))
+	t.Cleanup(srv.Close)
+
+	reg := registry.New(srv.URL)
+	reg.CacheDir = t.TempDir()
+	return NewWithRegistry(reg)
+}
+
+func TestResolveDistTagOfDecidedPackage(t *testing.T) {
+	r := newTestResolver(t, map[string]fakePackage{
+		"web": {versions: map[string]map[string]string{"1.0.0": {"lib": "next"}}},
+		"lib": {
+			versions: map[string]map[string]string{"1.0.0": nil, "2.0.0-beta.1": nil},
+			tags:     map[string]string{"latest": "1.0.0", "next": "2.0.0-beta.1"},
+		},
+	})
+
+	// lib is decided from the root before web's "next" requirement on it
+	// is added
+	resolved, err := r.Resolve(map[string]string{"lib": "2.0.0-beta.1", "web": "^1.0.0"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got := resolved["lib"]; got != "2.0.0-beta.1" {
+		t.Errorf("lib = %s, want 2.0.0-beta.1", got)
+	}
+}
+
+// versions is shorthand for packages without dependencies
+func versions(vs ...string) fakePackage {
+	pkg := fakePackage{versions: make(map[string]map[string]string)}
+	for _, v := range vs {
+		pkg.versions[v] = nil
+	}
+	return pkg
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages map[string]fakePackage
+		deps     map[string]string
+		prefer   map[string]string
+		skip     map[string]string
+		want     map[string]string
+	}{
+		{
+			name: "newest allowed versions",
+			packages: map[string]fakePackage{
+				"http": {versions: map[string]map[string]string{
+					"1.0.0":  {"json": "^1.0.0"},
+					"1.2.0":  {"json": "~1.1.0"},
+					"1.10.0": {"json": "~1.1.0"},
+					"2.0.0":  {"json": "^2.0.0"},
+				}},
+				"json": versions("1.0.0", "1.1.0", "1.1.5", "1.2.0", "2.0.0"),
+			},
+			deps: map[string]string{"http": "^1.0.0"},
+			want: map[string]string{"http": "1.10.0", "json": "1.1.5"},
+		},
+		{
+			name: "backtracks to an older version",
+			packages: map[string]fakePackage{
+				"a": {versions: map[string]map[string]string{
+					"1.0.0": {"c": "^1.0.0"},
+					"1.1.0": {"c": "^2.0.0"},
+				}},
+				"b": {versions: map[string]map[string]string{"1.0.0": {"c": "^1.0.0"}}},
+				"c": versions("1.0.0", "2.0.0"),
+			},
+			deps: map[string]string{"a": "^1.0.0", "b": "^1.0.0"},
+			want: map[string]string{"a": "1.0.0", "b": "1.0.0", "c": "1.0.0"},
+		},
+		{
+			name: "backtracks past a decided package",
+			packages: map[string]fakePackage{
+				"a": versions("1.0.0", "2.0.0"),
+				"b": {versions: map[string]map[string]string{
+					"1.0.0": {"a": "^1.0.0"},
+					"1.1.0": {"a": "^3.0.0"},
+				}},
+			},
+			deps: map[string]string{"a": "*", "b": "^1.0.0"},
+			want: map[string]string{"a": "1.0.0", "b": "1.0.0"},
+		},
+		{
+			name:     "keeps preferred versions",
+			packages: map[string]fakePackage{"a": versions("1.0.0", "1.1.0"), "b": versions("1.0.0", "1.1.0")},
+			deps:     map[string]string{"a": "^1.0.0", "b": "^1.0.0"},
+			prefer:   map[string]string{"a": "1.0.0", "b": "2.0.0"},
+			want:     map[string]string{"a": "1.0.0", "b": "1.1.0"},
+		},
+		{
+			name:     "skips versions",
+			packages: map[string]fakePackage{"a": versions("1.0.0", "1.1.0", "1.2.0")},
+			deps:     map[string]string{"a": "^1.0.0"},
+			skip:     map[string]string{"a": "1.2.0"},
+			want:     map[string]string{"a": "1.1.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestResolver(t, tt.packages)
+			r.Prefer = tt.prefer
+			r.Skip = func(name, version string) bool { return tt.skip[name] == version }
+
+			resolved, err := r.Resolve(tt.deps)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if len(resolved) != len(tt.want) {
+				t.Errorf("resolved %v, want %v", resolved, tt.want)
+			}
+			for name, version := range tt.want {
+				if resolved[name] != version {
+					t.Errorf("%s = %s, want %s", name, resolved[name], version)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages map[string]fakePackage
+		deps     map[string]string
+		want     string
+	}{
+		{
+			name:     "no matching version",
+			packages: map[string]fakePackage{"lib": versions("1.0.0", "1.1.0")},
+			deps:     map[string]string{"lib": "^5.0.0"},
+			want: "version solving failed for lib:\n" +
+				"  app depends on lib ^5.0.0,\n" +
+				"  but no version of lib matches (available: 1.1.0, 1.0.0).",
+		},
+		{
+			name: "transitive conflict",
+			packages: map[string]fakePackage{
+				"web": {versions: map[string]map[string]string{
+					"1.0.0": {"json": "^2.0.0"},
+					"1.1.0": {"json": "^2.0.0"},
+				}},
+				"json": versions("1.0.0", "2.0.0"),
+			},
+			deps: map[string]string{"web": "^1.0.0", "json": "^1.0.0"},
+			want: "version solving failed for json:\n" +
+				"  app depends on web ^1.0.0 (1.1.0) which depends on json ^2.0.0,\n" +
+				"  but app depends on json ^1.0.0.\n" +
+				"  No version of json satisfies all of these requirements (available: 2.0.0, 1.0.0).\n" +
+				"  Tried web 1.1.0, 1.0.0; none avoid the conflict.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestResolver(t, tt.packages)
+			r.Root = "app"
+
+			_, err := r.Resolve(tt.deps)
+			var conflict *ConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("Resolve error = %v, want a *ConflictError", err)
+			}
+			if got := conflict.Error(); got != tt.want {
+				t.Errorf("error =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTree(t *testing.T) {
+	r := newTestResolver(t, map[string]fakePackage{
+		"http": {versions: map[string]map[string]string{"1.0.0": {"json": "^1.0.0"}}},
+		"json": versions("1.0.0", "1.1.0"),
+	})
+
+	tree, err := r.ResolveTree(map[string]string{"http": "^1.0.0"})
+	if err != nil {
+		t.Fatalf("ResolveTree: %v", err)
+	}
+
+	web := tree.Root.Children["http"]
+	if web == nil || web.Version != "1.0.0" {
+		t.Fatalf("http = %+v, want 1.0.0", web)
+	}
+	if json := web.Children["json"]; json == nil || json.Version != "1.1.0" {
+		t.Errorf("http's json = %+v, want 1.1.0", json)
+	}
+}
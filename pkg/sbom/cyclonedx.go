@@ -0,0 +1,160 @@
+package sbom
+
+import (
+	"strings"
+	"time"
+
+	"github.com/droy-go/droy-pm/pkg/spdx"
+)
+
+// CycloneDX 1.5 JSON documents; see https://cyclonedx.org/specification/overview/
+
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type               string           `json:"type"`
+	BOMRef             string           `json:"bom-ref,omitempty"`
+	Group              string           `json:"group,omitempty"`
+	Name               string           `json:"name"`
+	Version            string           `json:"version,omitempty"`
+	Description        string           `json:"description,omitempty"`
+	Hashes             []cdxHash        `json:"hashes,omitempty"`
+	Licenses           []cdxLicense     `json:"licenses,omitempty"`
+	PURL               string           `json:"purl,omitempty"`
+	ExternalReferences []cdxExternalRef `json:"externalReferences,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// cdxLicense is either a single license or an SPDX expression
+type cdxLicense struct {
+	License    *cdxLicenseID `json:"license,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+}
+
+type cdxLicenseID struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cdxExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+var cdxHashAlgorithms = map[string]string{
+	"sha1":   "SHA-1",
+	"sha256": "SHA-256",
+	"sha384": "SHA-384",
+	"sha512": "SHA-512",
+}
+
+func (b *BOM) cycloneDX() *cdxBOM {
+	refs := make(map[string]string)
+	for _, c := range b.Components {
+		refs[c.Name] = c.PURL()
+	}
+
+	doc := &cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: b.Created.UTC().Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{
+				{Type: "application", Name: b.Tool, Version: b.ToolVersion},
+			}},
+			Component: cdxFromComponent(&b.Root, "application"),
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+
+	for i := range b.Components {
+		doc.Components = append(doc.Components, cdxFromComponent(&b.Components[i], "library"))
+	}
+
+	for _, c := range append([]Component{b.Root}, b.Components...) {
+		dep := cdxDependency{Ref: c.PURL(), DependsOn: []string{}}
+		for _, name := range c.Dependencies {
+			dep.DependsOn = append(dep.DependsOn, refs[name])
+		}
+		doc.Dependencies = append(doc.Dependencies, dep)
+	}
+
+	return doc
+}
+
+func cdxFromComponent(c *Component, kind string) cdxComponent {
+	comp := cdxComponent{
+		Type:        kind,
+		BOMRef:      c.PURL(),
+		Name:        c.Name,
+		Version:     c.Version,
+		Description: c.Description,
+		Licenses:    cdxLicenses(c.License),
+		PURL:        c.PURL(),
+	}
+
+	// Scoped names split into a group and a name, as for npm
+	if scope, name, ok := strings.Cut(c.Name, "/"); ok && strings.HasPrefix(scope, "@") {
+		comp.Group, comp.Name = scope, name
+	}
+
+	for _, h := range c.hashes() {
+		comp.Hashes = append(comp.Hashes, cdxHash{Alg: cdxHashAlgorithms[h.Algorithm], Content: h.Value})
+	}
+	if c.Resolved != "" {
+		comp.ExternalReferences = []cdxExternalRef{{Type: "distribution", URL: c.Resolved}}
+	}
+	return comp
+}
+
+// cdxLicenses describes a license as a listed SPDX id where possible, as
+// an expression when it combines licenses, and by name when it is not a
+// valid expression
+func cdxLicenses(license string) []cdxLicense {
+	if license == "" {
+		return nil
+	}
+
+	expr, err := spdx.Parse(license)
+	if err != nil {
+		return []cdxLicense{{License: &cdxLicenseID{Name: license}}}
+	}
+
+	if l, ok := expr.(*spdx.License); ok && !l.OrLater && l.Exception == "" {
+		if strings.HasPrefix(l.ID, "LicenseRef-") || strings.HasPrefix(l.ID, "DocumentRef-") {
+			return []cdxLicense{{License: &cdxLicenseID{Name: l.ID}}}
+		}
+		return []cdxLicense{{License: &cdxLicenseID{ID: l.ID}}}
+	}
+	return []cdxLicense{{Expression: expr.String()}}
+}
@@ -0,0 +1,185 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// Formats Encode can write
+const (
+	FormatCycloneDX = "cyclonedx-json"
+	FormatSPDX      = "spdx-json"
+)
+
+// Component is a package in a bill of materials
+type Component struct {
+	Name        string
+	Version     string
+	Description string
+
+	// License is the SPDX expression the package declares, which may be
+	// empty, UNLICENSED or invalid
+	License string
+
+	// Resolved is the URL the package is downloaded from
+	Resolved string
+
+	// Integrity holds the package's Subresource Integrity hashes
+	Integrity string
+
+	// Dependencies names the components this one depends on, sorted
+	Dependencies []string
+}
+
+// BOM is a software bill of materials: a project and every package in
+// its droy.lock
+type BOM struct {
+	Root       Component
+	Components []Component
+
+	// Tool and ToolVersion name the program that generated the BOM
+	Tool        string
+	ToolVersion string
+
+	Created time.Time
+}
+
+// FromLock builds a BOM for a project from its lock file. Components are
+// sorted by name; licenses and descriptions are left for the caller to
+// fill in from package metadata
+func FromLock(pkg *config.Package, lock *config.LockFile) *BOM {
+	bom := &BOM{
+		Root: Component{
+			Name:         pkg.Name,
+			Version:      pkg.Version,
+			Description:  pkg.Description,
+			License:      pkg.License,
+			Dependencies: lockedNames(lock, lock.Dependencies),
+		},
+		Created: time.Now().UTC(),
+	}
+
+	for name, entry := range lock.Packages {
+		c := Component{
+			Name:         name,
+			Version:      entry.Version,
+			Resolved:     entry.Resolved,
+			Integrity:    entry.Integrity,
+			Dependencies: lockedNames(lock, entry.Dependencies),
+		}
+		// Workspace members are linked from the project, not downloaded
+		if strings.HasPrefix(c.Resolved, "file:") {
+			c.Resolved = ""
+		}
+		bom.Components = append(bom.Components, c)
+	}
+	sort.Slice(bom.Components, func(i, j int) bool {
+		return bom.Components[i].Name < bom.Components[j].Name
+	})
+
+	return bom
+}
+
+// lockedNames returns the sorted names in deps that have a lock entry
+func lockedNames(lock *config.LockFile, deps map[string]string) []string {
+	var names []string
+	for name := range deps {
+		if _, ok := lock.Packages[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Encode writes the BOM as indented JSON in one of the supported formats
+func Encode(w io.Writer, bom *BOM, format string) error {
+	var doc interface{}
+	switch format {
+	case FormatCycloneDX:
+		doc = bom.cycloneDX()
+	case FormatSPDX:
+		doc = bom.spdx()
+	default:
+		return fmt.Errorf("unknown SBOM format %q, expected %s or %s", format, FormatCycloneDX, FormatSPDX)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// PURL returns the component's package URL: pkg:github/owner/repo@version
+// for GitHub packages and pkg:droy/name@version for registry packages,
+// with the @ of a scope encoded as the purl spec requires
+func (c *Component) PURL() string {
+	var version string
+	if c.Version != "" {
+		version = "@" + purlEscape(c.Version)
+	}
+
+	if repo, ok := strings.CutPrefix(c.Name, "github.com/"); ok {
+		return "pkg:github/" + repo + version
+	}
+
+	if scope, name, ok := strings.Cut(c.Name, "/"); ok {
+		return "pkg:droy/" + purlEscape(scope) + "/" + purlEscape(name) + version
+	}
+	return "pkg:droy/" + purlEscape(c.Name) + version
+}
+
+// purlEscape percent-encodes a purl segment, including the @ that
+// separates the version
+func purlEscape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+}
+
+// hash is one of a component's integrity hashes, hex-encoded
+type hash struct {
+	Algorithm string
+	Value     string
+}
+
+// hashes decodes the component's integrity hashes, skipping algorithms
+// the SBOM formats do not know
+func (c *Component) hashes() []hash {
+	var result []hash
+	for _, sri := range strings.Fields(c.Integrity) {
+		algo, digest, ok := strings.Cut(sri, "-")
+		if !ok {
+			continue
+		}
+		switch algo {
+		case "sha1", "sha256", "sha384", "sha512":
+		default:
+			continue
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(digest)
+		if err != nil {
+			continue
+		}
+		result = append(result, hash{Algorithm: algo, Value: hex.EncodeToString(raw)})
+	}
+	return result
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,251 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// deadbeef is the integrity of a tarball whose SHA-512 is 0xdeadbeef
+const deadbeef = "sha512-3q2+7w=="
+
+func testBOM() *BOM {
+	pkg := &config.Package{Name: "app", Version: "1.0.0", License: "MIT"}
+	lock := &config.LockFile{
+		Dependencies: map[string]string{"lib": "^1.0.0", "@acme/utils": "^2.0.0", "member": "*"},
+		Packages: map[string]*config.LockPackage{
+			"lib": {
+				Version:      "1.2.0",
+				Resolved:     "https://registry.example.com/lib/-/lib-1.2.0.tgz",
+				Integrity:    "md5-AAAA " + deadbeef,
+				Dependencies: map[string]string{"@acme/utils": "^2.0.0", "missing": "^1.0.0"},
+			},
+			"@acme/utils": {
+				Version:   "2.0.1",
+				Resolved:  "https://registry.example.com/@acme/utils/-/utils-2.0.1.tgz",
+				Integrity: deadbeef,
+			},
+			"member": {Version: "0.1.0", Resolved: "file:packages/member"},
+		},
+	}
+
+	bom := FromLock(pkg, lock)
+	bom.Tool, bom.ToolVersion = "droy-pm", "1.0.0"
+	bom.Created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return bom
+}
+
+func TestFromLock(t *testing.T) {
+	bom := testBOM()
+
+	var names []string
+	for _, c := range bom.Components {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, " "); got != "@acme/utils lib member" {
+		t.Errorf("components = %s, want them sorted by name", got)
+	}
+
+	if got := strings.Join(bom.Root.Dependencies, " "); got != "@acme/utils lib member" {
+		t.Errorf("root dependencies = %s", got)
+	}
+	lib := bom.Components[1]
+	if got := strings.Join(lib.Dependencies, " "); got != "@acme/utils" {
+		t.Errorf("lib dependencies = %s, want only the locked ones", got)
+	}
+	if member := bom.Components[2]; member.Resolved != "" {
+		t.Errorf("workspace member resolved = %q, want none", member.Resolved)
+	}
+}
+
+func TestPURL(t *testing.T) {
+	tests := []struct {
+		name, version, want string
+	}{
+		{"lib", "1.0.0", "pkg:droy/lib@1.0.0"},
+		{"lib", "", "pkg:droy/lib"},
+		{"lib", "1.0.0-beta.1", "pkg:droy/lib@1.0.0-beta.1"},
+		{"@acme/utils", "2.0.1", "pkg:droy/%40acme/utils@2.0.1"},
+		{"github.com/acme/lib", "v1.2.0", "pkg:github/acme/lib@v1.2.0"},
+	}
+	for _, tt := range tests {
+		c := &Component{Name: tt.name, Version: tt.version}
+		if got := c.PURL(); got != tt.want {
+			t.Errorf("PURL(%s@%s) = %s, want %s", tt.name, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeCycloneDX(t *testing.T) {
+	bom := testBOM()
+	bom.Components[0].License = "MIT OR ISC"
+	bom.Components[1].License = "GPLv3"
+	bom.Components[2].License = "LicenseRef-Acme"
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, bom, FormatCycloneDX); err != nil {
+		t.Fatal(err)
+	}
+	var doc cdxBOM
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.BOMFormat != "CycloneDX" || doc.SpecVersion != "1.5" || !strings.HasPrefix(doc.SerialNumber, "urn:uuid:") {
+		t.Errorf("header = %s %s %s", doc.BOMFormat, doc.SpecVersion, doc.SerialNumber)
+	}
+	if doc.Metadata.Timestamp != "2024-01-02T03:04:05Z" {
+		t.Errorf("timestamp = %s", doc.Metadata.Timestamp)
+	}
+	root := doc.Metadata.Component
+	if root.Type != "application" || root.PURL != "pkg:droy/app@1.0.0" || len(root.Licenses) != 1 || root.Licenses[0].License.ID != "MIT" {
+		t.Errorf("metadata component = %+v", root)
+	}
+	if tools := doc.Metadata.Tools.Components; len(tools) != 1 || tools[0].Name != "droy-pm" {
+		t.Errorf("tools = %+v", tools)
+	}
+
+	if len(doc.Components) != 3 {
+		t.Fatalf("components = %+v, want 3", doc.Components)
+	}
+	utils, lib, member := doc.Components[0], doc.Components[1], doc.Components[2]
+
+	if utils.Group != "@acme" || utils.Name != "utils" || utils.BOMRef != "pkg:droy/%40acme/utils@2.0.1" {
+		t.Errorf("scoped component = %+v", utils)
+	}
+	if len(utils.Licenses) != 1 || utils.Licenses[0].Expression != "MIT OR ISC" {
+		t.Errorf("expression license = %+v", utils.Licenses)
+	}
+
+	if len(lib.Hashes) != 1 || lib.Hashes[0] != (cdxHash{Alg: "SHA-512", Content: "deadbeef"}) {
+		t.Errorf("hashes = %+v, want the SHA-512 only", lib.Hashes)
+	}
+	if len(lib.Licenses) != 1 || lib.Licenses[0].License.Name != "GPLv3" {
+		t.Errorf("invalid license = %+v, want it by name", lib.Licenses)
+	}
+	if len(lib.ExternalReferences) != 1 || lib.ExternalReferences[0].URL != "https://registry.example.com/lib/-/lib-1.2.0.tgz" {
+		t.Errorf("external references = %+v", lib.ExternalReferences)
+	}
+
+	if len(member.Licenses) != 1 || member.Licenses[0].License.Name != "LicenseRef-Acme" {
+		t.Errorf("LicenseRef license = %+v, want it by name", member.Licenses)
+	}
+	if len(member.ExternalReferences) != 0 {
+		t.Errorf("workspace member has external references %+v", member.ExternalReferences)
+	}
+
+	deps := make(map[string]string)
+	for _, d := range doc.Dependencies {
+		deps[d.Ref] = strings.Join(d.DependsOn, " ")
+	}
+	want := map[string]string{
+		"pkg:droy/app@1.0.0":           "pkg:droy/%40acme/utils@2.0.1 pkg:droy/lib@1.2.0 pkg:droy/member@0.1.0",
+		"pkg:droy/lib@1.2.0":           "pkg:droy/%40acme/utils@2.0.1",
+		"pkg:droy/%40acme/utils@2.0.1": "",
+		"pkg:droy/member@0.1.0":        "",
+	}
+	if len(deps) != len(want) {
+		t.Errorf("dependencies = %v, want %v", deps, want)
+	}
+	for ref, dependsOn := range want {
+		if got, ok := deps[ref]; !ok || got != dependsOn {
+			t.Errorf("%s depends on %q, want %q", ref, got, dependsOn)
+		}
+	}
+}
+
+func TestEncodeSPDX(t *testing.T) {
+	bom := testBOM()
+	bom.Components[0].License = "mit or LicenseRef-Acme"
+	bom.Components[1].License = "UNLICENSED"
+	// Sanitized, this name collides with lib@1.2.0
+	bom.Components = append(bom.Components, Component{Name: "lib_1.2.0"})
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, bom, FormatSPDX); err != nil {
+		t.Fatal(err)
+	}
+	var doc spdxDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.SPDXVersion != "SPDX-2.3" || doc.Name != "app@1.0.0" || !strings.HasPrefix(doc.DocumentNamespace, "https://droy-lang.org/spdxdocs/app-1.0.0-") {
+		t.Errorf("header = %s %s %s", doc.SPDXVersion, doc.Name, doc.DocumentNamespace)
+	}
+	if doc.CreationInfo.Created != "2024-01-02T03:04:05Z" || doc.CreationInfo.Creators[0] != "Tool: droy-pm-1.0.0" {
+		t.Errorf("creation info = %+v", doc.CreationInfo)
+	}
+
+	packages := make(map[string]spdxPackage)
+	ids := make(map[string]bool)
+	for _, p := range doc.Packages {
+		packages[p.Name] = p
+		if ids[p.SPDXID] {
+			t.Errorf("SPDX id %s is used twice", p.SPDXID)
+		}
+		ids[p.SPDXID] = true
+	}
+	if len(doc.Packages) != 5 {
+		t.Fatalf("packages = %+v, want 5", doc.Packages)
+	}
+	if id := packages["lib_1.2.0"].SPDXID; id != "SPDXRef-Package-lib-1.2.0-2" {
+		t.Errorf("colliding package id = %s, want SPDXRef-Package-lib-1.2.0-2", id)
+	}
+
+	app := packages["app"]
+	if app.SPDXID != "SPDXRef-Package-app-1.0.0" || app.PrimaryPurpose != "APPLICATION" || app.LicenseDeclared != "MIT" {
+		t.Errorf("root package = %+v", app)
+	}
+
+	utils := packages["@acme/utils"]
+	if utils.LicenseDeclared != "MIT OR LicenseRef-Acme" || utils.PrimaryPurpose != "LIBRARY" {
+		t.Errorf("@acme/utils = %+v", utils)
+	}
+	if len(utils.ExternalRefs) != 1 || utils.ExternalRefs[0].ReferenceLocator != "pkg:droy/%40acme/utils@2.0.1" {
+		t.Errorf("@acme/utils refs = %+v", utils.ExternalRefs)
+	}
+
+	lib := packages["lib"]
+	if lib.LicenseDeclared != noAssertion {
+		t.Errorf("UNLICENSED is declared as %s, want NOASSERTION", lib.LicenseDeclared)
+	}
+	if len(lib.Checksums) != 1 || lib.Checksums[0] != (spdxChecksum{Algorithm: "SHA512", ChecksumValue: "deadbeef"}) {
+		t.Errorf("checksums = %+v", lib.Checksums)
+	}
+	if lib.DownloadLocation != "https://registry.example.com/lib/-/lib-1.2.0.tgz" {
+		t.Errorf("download location = %s", lib.DownloadLocation)
+	}
+	if member := packages["member"]; member.DownloadLocation != noAssertion || member.LicenseDeclared != noAssertion {
+		t.Errorf("member = %+v", member)
+	}
+
+	if len(doc.ExtractedLicenses) != 1 || doc.ExtractedLicenses[0].LicenseID != "LicenseRef-Acme" {
+		t.Errorf("extracted licenses = %+v", doc.ExtractedLicenses)
+	}
+
+	var relationships []string
+	for _, r := range doc.Relationships {
+		relationships = append(relationships, r.SPDXElementID+" "+r.RelationshipType+" "+r.RelatedSPDXElement)
+	}
+	want := []string{
+		"SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package-app-1.0.0",
+		"SPDXRef-Package-app-1.0.0 DEPENDS_ON SPDXRef-Package-acme-utils-2.0.1",
+		"SPDXRef-Package-app-1.0.0 DEPENDS_ON SPDXRef-Package-lib-1.2.0",
+		"SPDXRef-Package-app-1.0.0 DEPENDS_ON SPDXRef-Package-member-0.1.0",
+		"SPDXRef-Package-lib-1.2.0 DEPENDS_ON SPDXRef-Package-acme-utils-2.0.1",
+	}
+	if got := strings.Join(relationships, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("relationships =\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestEncodeUnknownFormat(t *testing.T) {
+	if err := Encode(&bytes.Buffer{}, testBOM(), "xml"); err == nil {
+		t.Error("Encode accepted an unknown format")
+	}
+}
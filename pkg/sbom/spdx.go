@@ -0,0 +1,218 @@
+package sbom
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/droy-go/droy-pm/pkg/spdx"
+)
+
+// SPDX 2.3 JSON documents; see https://spdx.github.io/spdx-spec/v2.3/
+
+type spdxDocument struct {
+	SPDXVersion       string                 `json:"spdxVersion"`
+	DataLicense       string                 `json:"dataLicense"`
+	SPDXID            string                 `json:"SPDXID"`
+	Name              string                 `json:"name"`
+	DocumentNamespace string                 `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo       `json:"creationInfo"`
+	Packages          []spdxPackage          `json:"packages"`
+	Relationships     []spdxRelationship     `json:"relationships"`
+	ExtractedLicenses []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string         `json:"name"`
+	SPDXID           string         `json:"SPDXID"`
+	VersionInfo      string         `json:"versionInfo,omitempty"`
+	Description      string         `json:"description,omitempty"`
+	DownloadLocation string         `json:"downloadLocation"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum `json:"checksums,omitempty"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	CopyrightText    string         `json:"copyrightText"`
+	ExternalRefs     []spdxRef      `json:"externalRefs,omitempty"`
+	PrimaryPurpose   string         `json:"primaryPackagePurpose,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type spdxExtractedLicense struct {
+	LicenseID     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+const (
+	spdxDocumentID = "SPDXRef-DOCUMENT"
+	noAssertion    = "NOASSERTION"
+)
+
+var spdxHashAlgorithms = map[string]string{
+	"sha1":   "SHA1",
+	"sha256": "SHA256",
+	"sha384": "SHA384",
+	"sha512": "SHA512",
+}
+
+// spdxIDInvalid matches the characters SPDX identifiers may not contain
+var spdxIDInvalid = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func (b *BOM) spdx() *spdxDocument {
+	name := b.Root.Name
+	if b.Root.Version != "" {
+		name += "@" + b.Root.Version
+	}
+
+	doc := &spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            spdxDocumentID,
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("https://droy-lang.org/spdxdocs/%s-%s", spdxIDInvalid.ReplaceAllString(name, "-"), newUUID()),
+		CreationInfo: spdxCreationInfo{
+			Created:  b.Created.UTC().Format(time.RFC3339),
+			Creators: []string{fmt.Sprintf("Tool: %s-%s", b.Tool, b.ToolVersion)},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	// Package names can collide once sanitized, so ids are made unique
+	ids := make(map[string]string)
+	used := make(map[string]bool)
+	all := append([]Component{b.Root}, b.Components...)
+	for _, c := range all {
+		base := "SPDXRef-Package-" + strings.Trim(spdxIDInvalid.ReplaceAllString(c.Name+"-"+c.Version, "-"), "-")
+		id := base
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[id] = true
+		ids[c.Name] = id
+	}
+
+	refs := make(map[string]bool)
+	for i, c := range all {
+		pkg := spdxFromComponent(&all[i], ids[c.Name])
+		if i == 0 {
+			pkg.PrimaryPurpose = "APPLICATION"
+		} else {
+			pkg.PrimaryPurpose = "LIBRARY"
+		}
+		doc.Packages = append(doc.Packages, pkg)
+
+		for _, id := range licenseRefs(c.License) {
+			refs[id] = true
+		}
+	}
+
+	doc.Relationships = append(doc.Relationships, spdxRelationship{
+		SPDXElementID:      spdxDocumentID,
+		RelationshipType:   "DESCRIBES",
+		RelatedSPDXElement: ids[b.Root.Name],
+	})
+	for _, c := range all {
+		for _, dep := range c.Dependencies {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID:      ids[c.Name],
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: ids[dep],
+			})
+		}
+	}
+
+	// LicenseRef- identifiers must be declared in the document
+	var custom []string
+	for id := range refs {
+		custom = append(custom, id)
+	}
+	sort.Strings(custom)
+	for _, id := range custom {
+		doc.ExtractedLicenses = append(doc.ExtractedLicenses, spdxExtractedLicense{
+			LicenseID:     id,
+			Name:          strings.TrimPrefix(id, "LicenseRef-"),
+			ExtractedText: noAssertion,
+		})
+	}
+
+	return doc
+}
+
+func spdxFromComponent(c *Component, id string) spdxPackage {
+	pkg := spdxPackage{
+		Name:             c.Name,
+		SPDXID:           id,
+		VersionInfo:      c.Version,
+		Description:      c.Description,
+		DownloadLocation: noAssertion,
+		LicenseConcluded: noAssertion,
+		LicenseDeclared:  spdxLicense(c.License),
+		CopyrightText:    noAssertion,
+		ExternalRefs: []spdxRef{{
+			ReferenceCategory: "PACKAGE-MANAGER",
+			ReferenceType:     "purl",
+			ReferenceLocator:  c.PURL(),
+		}},
+	}
+	if c.Resolved != "" {
+		pkg.DownloadLocation = c.Resolved
+	}
+	for _, h := range c.hashes() {
+		pkg.Checksums = append(pkg.Checksums, spdxChecksum{Algorithm: spdxHashAlgorithms[h.Algorithm], ChecksumValue: h.Value})
+	}
+	return pkg
+}
+
+// spdxLicense returns a license in canonical form, or NOASSERTION when it
+// is missing, UNLICENSED or not a valid SPDX expression
+func spdxLicense(license string) string {
+	if license == "" {
+		return noAssertion
+	}
+	expr, err := spdx.Parse(license)
+	if err != nil {
+		return noAssertion
+	}
+	return expr.String()
+}
+
+// licenseRefs returns the LicenseRef- identifiers a license uses
+func licenseRefs(license string) []string {
+	expr, err := spdx.Parse(license)
+	if err != nil {
+		return nil
+	}
+
+	var refs []string
+	for _, id := range expr.Licenses() {
+		if strings.HasPrefix(id, "LicenseRef-") {
+			refs = append(refs, id)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,397 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Range represents a set of version constraints such as "^1.2.0",
+// ">=1.0.0 <2.0.0" or "1.x || 2.1.0 - 2.4.0"
+type Range struct {
+	raw  string
+	sets [][]comparator
+}
+
+type comparator struct {
+	op      string
+	version *Version
+}
+
+// partial is a possibly incomplete version such as "1", "1.2" or "1.x"
+type partial struct {
+	nums       [3]uint64
+	parts      int // number of concrete components before any wildcard
+	prerelease []string
+}
+
+var (
+	hyphenRangeRe   = regexp.MustCompile(`^(\S+)\s+-\s+(\S+)$`)
+	operatorSpaceRe = regexp.MustCompile(`(<=|>=|<|>|=|\^|~>|~)\s+`)
+)
+
+// ParseRange parses a range expression.
+//
+// Supported syntax:
+//
+//	1.2.3, =1.2.3        exact version
+//	^1.2.3               >=1.2.3 <2.0.0 (left-most non-zero component fixed)
+//	~1.2.3               >=1.2.3 <1.3.0
+//	>1.2.3, >=1.2.3      comparators, also < and <=
+//	>=1.0.0 <2.0.0       intersection (space separated)
+//	1.2.3 - 2.3.4        hyphen range, inclusive
+//	1.x, 1.2.*, *        x-ranges
+//	^1.0.0 || ^2.0.0     union
+func ParseRange(s string) (*Range, error) {
+	r := &Range{raw: strings.TrimSpace(s)}
+
+	for _, part := range strings.Split(r.raw, "||") {
+		set, err := parseComparatorSet(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+		r.sets = append(r.sets, set)
+	}
+
+	return r, nil
+}
+
+// MustParseRange is like ParseRange but panics if the range cannot be parsed
+func MustParseRange(s string) *Range {
+	r, err := ParseRange(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// String returns the range as originally written
+func (r *Range) String() string {
+	return r.raw
+}
+
+// Contains reports whether v satisfies the range.
+//
+// Prerelease versions only match when a comparator in the same set
+// refers to a prerelease of the same MAJOR.MINOR.PATCH, so "^1.2.0"
+// does not pick up "1.3.0-beta" but ">=1.3.0-alpha" does.
+func (r *Range) Contains(v *Version) bool {
+	for _, set := range r.sets {
+		if setContains(set, v, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsPrerelease is like Contains, but a prerelease matches whenever
+// it falls within the range's bounds, so "<2.0.0" contains "1.5.0-beta".
+// This suits ranges of affected versions, where a prerelease between the
+// bounds is as affected as the releases around it
+func (r *Range) ContainsPrerelease(v *Version) bool {
+	for _, set := range r.sets {
+		if setContains(set, v, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// Satisfies reports whether version satisfies the range expression.
+// Invalid versions or ranges never satisfy
+func Satisfies(version, rng string) bool {
+	v, err := Parse(version)
+	if err != nil {
+		return false
+	}
+	r, err := ParseRange(rng)
+	if err != nil {
+		return false
+	}
+	return r.Contains(v)
+}
+
+// MaxSatisfying returns the highest version in the list that satisfies
+// the range, or "" if none does
+func MaxSatisfying(versions []string, r *Range) string {
+	var best *Version
+	var bestRaw string
+
+	for _, raw := range versions {
+		v, err := Parse(raw)
+		if err != nil || !r.Contains(v) {
+			continue
+		}
+		if best == nil || best.LessThan(v) {
+			best, bestRaw = v, raw
+		}
+	}
+
+	return bestRaw
+}
+
+func setContains(set []comparator, v *Version, prerelease bool) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+
+	if prerelease || !v.IsPrerelease() {
+		return true
+	}
+
+	for _, c := range set {
+		cv := c.version
+		if cv.IsPrerelease() && cv.Major == v.Major && cv.Minor == v.Minor && cv.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c comparator) matches(v *Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	if s == "" || s == "*" || s == "x" || s == "X" {
+		return []comparator{}, nil
+	}
+
+	if m := hyphenRangeRe.FindStringSubmatch(s); m != nil {
+		return hyphenRange(m[1], m[2])
+	}
+
+	s = operatorSpaceRe.ReplaceAllString(s, "$1")
+
+	var set []comparator
+	for _, token := range strings.Fields(s) {
+		cs, err := parseComparator(token)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+func parseComparator(token string) ([]comparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "=", "^", "~>", "~"} {
+		if strings.HasPrefix(token, candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	p, err := parsePartial(strings.TrimPrefix(token, op))
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "^":
+		return caretRange(p), nil
+	case "~", "~>":
+		return tildeRange(p), nil
+	case "", "=":
+		return xRange(p), nil
+	default:
+		return primitive(op, p), nil
+	}
+}
+
+func parsePartial(s string) (*partial, error) {
+	orig := s
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return nil, fmt.Errorf("empty version in %q", orig)
+	}
+
+	// Build metadata never affects matching
+	if idx := strings.Index(s, "+"); idx >= 0 {
+		s = s[:idx]
+	}
+
+	p := &partial{}
+	if idx := strings.Index(s, "-"); idx >= 0 {
+		p.prerelease = strings.Split(s[idx+1:], ".")
+		s = s[:idx]
+		for _, id := range p.prerelease {
+			if !isIdentifier(id) {
+				return nil, fmt.Errorf("bad prerelease identifier %q in %q", id, orig)
+			}
+		}
+	}
+
+	components := strings.Split(s, ".")
+	if len(components) > 3 {
+		return nil, fmt.Errorf("too many version components in %q", orig)
+	}
+
+	wildcard := false
+	for i, c := range components {
+		if c == "x" || c == "X" || c == "*" {
+			wildcard = true
+			continue
+		}
+		if wildcard {
+			return nil, fmt.Errorf("version component after wildcard in %q", orig)
+		}
+		n, err := parseNumber(c)
+		if err != nil {
+			return nil, fmt.Errorf("%v in %q", err, orig)
+		}
+		p.nums[i] = n
+		p.parts = i + 1
+	}
+
+	if p.prerelease != nil && p.parts != 3 {
+		return nil, fmt.Errorf("prerelease requires a full version in %q", orig)
+	}
+
+	return p, nil
+}
+
+// floor returns the lowest version matched by the partial
+func (p *partial) floor() *Version {
+	return &Version{
+		Major:      p.nums[0],
+		Minor:      p.nums[1],
+		Patch:      p.nums[2],
+		Prerelease: p.prerelease,
+	}
+}
+
+// ceiling returns the first version above the partial's wildcard
+// component, e.g. 1.2.x -> 1.3.0 and 1.x -> 2.0.0
+func (p *partial) ceiling() *Version {
+	if p.parts == 1 {
+		return &Version{Major: p.nums[0] + 1}
+	}
+	return &Version{Major: p.nums[0], Minor: p.nums[1] + 1}
+}
+
+// none matches no version at all: nothing sorts below 0.0.0-0
+func none() []comparator {
+	return []comparator{{op: "<", version: &Version{Prerelease: []string{"0"}}}}
+}
+
+func xRange(p *partial) []comparator {
+	switch p.parts {
+	case 0:
+		return []comparator{}
+	case 3:
+		return []comparator{{op: "=", version: p.floor()}}
+	default:
+		return []comparator{
+			{op: ">=", version: p.floor()},
+			{op: "<", version: p.ceiling()},
+		}
+	}
+}
+
+func tildeRange(p *partial) []comparator {
+	switch p.parts {
+	case 0:
+		return []comparator{}
+	case 1:
+		return xRange(p)
+	default:
+		return []comparator{
+			{op: ">=", version: p.floor()},
+			{op: "<", version: &Version{Major: p.nums[0], Minor: p.nums[1] + 1}},
+		}
+	}
+}
+
+func caretRange(p *partial) []comparator {
+	if p.parts == 0 {
+		return []comparator{}
+	}
+
+	major, minor, patch := p.nums[0], p.nums[1], p.nums[2]
+
+	var upper *Version
+	switch {
+	case major > 0 || p.parts == 1:
+		upper = &Version{Major: major + 1}
+	case minor > 0 || p.parts == 2:
+		upper = &Version{Minor: minor + 1}
+	default:
+		upper = &Version{Patch: patch + 1}
+	}
+
+	return []comparator{
+		{op: ">=", version: p.floor()},
+		{op: "<", version: upper},
+	}
+}
+
+func primitive(op string, p *partial) []comparator {
+	if p.parts == 3 {
+		return []comparator{{op: op, version: p.floor()}}
+	}
+
+	switch op {
+	case ">":
+		if p.parts == 0 {
+			return none()
+		}
+		return []comparator{{op: ">=", version: p.ceiling()}}
+	case ">=":
+		return []comparator{{op: ">=", version: p.floor()}}
+	case "<":
+		if p.parts == 0 {
+			return none()
+		}
+		return []comparator{{op: "<", version: p.floor()}}
+	default: // "<="
+		if p.parts == 0 {
+			return []comparator{}
+		}
+		return []comparator{{op: "<", version: p.ceiling()}}
+	}
+}
+
+func hyphenRange(from, to string) ([]comparator, error) {
+	lower, err := parsePartial(from)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := parsePartial(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var set []comparator
+	if lower.parts > 0 {
+		set = append(set, comparator{op: ">=", version: lower.floor()})
+	}
+
+	switch upper.parts {
+	case 0:
+	case 3:
+		set = append(set, comparator{op: "<=", version: upper.floor()})
+	default:
+		set = append(set, comparator{op: "<", version: upper.ceiling()})
+	}
+
+	if set == nil {
+		set = []comparator{}
+	}
+	return set, nil
+}
@@ -0,0 +1,160 @@
+package semver
+
+import "testing"
+
+func TestRangeContains(t *testing.T) {
+	tests := []struct {
+		rng     string
+		version string
+		want    bool
+	}{
+		// Exact versions
+		{"1.2.3", "1.2.3", true},
+		{"=1.2.3", "1.2.3", true},
+		{"v1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"1.2.3+build", "1.2.3+other", true},
+
+		// Caret
+		{"^1.2.3", "1.2.3", true},
+		{"^1.2.3", "1.9.9", true},
+		{"^1.2.3", "1.2.2", false},
+		{"^1.2.3", "2.0.0", false},
+		{"^1.2.3", "1.3.0-beta", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0", "0.9.0", true},
+		{"^0", "1.0.0", false},
+		{"^1.x", "1.5.0", true},
+		{"^1.x", "2.0.0", false},
+		{"^1.2.3-beta.2", "1.2.3-beta.3", true},
+		{"^1.2.3-beta.2", "1.2.3-beta.1", false},
+		{"^1.2.3-beta.2", "1.2.3", true},
+		{"^1.2.3-beta.2", "1.2.4-beta", false},
+
+		// Tilde
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2", "1.2.0", true},
+		{"~1.2", "1.3.0", false},
+		{"~1", "1.9.0", true},
+		{"~1", "2.0.0", false},
+		{"~>1.2.3", "1.2.5", true},
+		{"~>1.2.3", "1.3.0", false},
+
+		// Comparators and intersections
+		{">=1.0.0 <2.0.0", "1.5.0", true},
+		{">=1.0.0 <2.0.0", "2.0.0", false},
+		{">= 1.0.0", "1.0.0", true},
+		{">1.2.3", "1.2.3", false},
+		{">1.2.3", "1.2.4", true},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{"<1.2", "1.1.9", true},
+		{"<1.2", "1.2.0", false},
+		{"<=1.2", "1.2.9", true},
+		{"<=1.2", "1.3.0", false},
+		{">*", "0.0.0", false},
+		{"<*", "0.0.0", false},
+		{">=1.3.0-alpha", "1.3.0-beta", true},
+		{">=1.3.0-alpha", "1.4.0-beta", false},
+		{">=1.3.0-alpha", "1.4.0", true},
+
+		// Hyphen ranges
+		{"1.2.3 - 2.3.4", "1.2.3", true},
+		{"1.2.3 - 2.3.4", "2.3.4", true},
+		{"1.2.3 - 2.3.4", "2.3.5", false},
+		{"1.2.3 - 2.3.4", "1.2.2", false},
+		{"1.2 - 2.3", "2.3.9", true},
+		{"1.2 - 2.3", "2.4.0", false},
+		{"* - 2.0.0", "0.1.0", true},
+
+		// X-ranges
+		{"1.x", "1.9.9", true},
+		{"1.x", "2.0.0", false},
+		{"1.2.*", "1.2.7", true},
+		{"1.2.*", "1.3.0", false},
+		{"1", "1.4.0", true},
+		{"*", "5.0.0", true},
+		{"*", "1.0.0-beta", false},
+		{"", "0.0.1", true},
+
+		// Unions
+		{"^1.0.0 || ^2.0.0", "2.5.0", true},
+		{"^1.0.0 || ^2.0.0", "3.0.0", false},
+		{"1.x || >=2.5.0", "2.4.0", false},
+		{"1.x || >=2.5.0", "2.6.0", true},
+		{"1.2.3 || 2.1.0 - 2.4.0", "2.2.0", true},
+	}
+
+	for _, tt := range tests {
+		rng, err := ParseRange(tt.rng)
+		if err != nil {
+			t.Errorf("ParseRange(%q): %v", tt.rng, err)
+			continue
+		}
+		if got := rng.Contains(MustParse(tt.version)); got != tt.want {
+			t.Errorf("%q contains %s = %v, want %v", tt.rng, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestRangeContainsPrerelease(t *testing.T) {
+	tests := []struct {
+		rng     string
+		version string
+		want    bool
+	}{
+		{"<2.0.0", "1.5.0-beta", true},
+		{"<2.0.0", "2.0.0-rc.1", true},
+		{"<2.0.0", "2.0.0", false},
+		{"^1.2.0", "1.3.0-beta", true},
+		{">=1.2.0 <1.4.0", "1.2.0-beta", false},
+		{">*", "0.0.0-alpha", false},
+	}
+
+	for _, tt := range tests {
+		if got := MustParseRange(tt.rng).ContainsPrerelease(MustParse(tt.version)); got != tt.want {
+			t.Errorf("%q contains prerelease %s = %v, want %v", tt.rng, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, in := range []string{
+		"1.2.3.4",
+		"^a.b",
+		"1.x.3",
+		">=1.0.0 <",
+		"1.2.3-",
+		"1.0.0 - ",
+		"^1.0.0 || 2.x.1",
+		"latest",
+	} {
+		if _, err := ParseRange(in); err == nil {
+			t.Errorf("ParseRange(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.10.0", "1.11.0-beta", "2.0.0", "not-a-version"}
+
+	tests := []struct {
+		rng  string
+		want string
+	}{
+		{"^1.0.0", "1.10.0"},
+		{"~1.2", "1.2.0"},
+		{">=1.11.0-alpha <2.0.0", "1.11.0-beta"},
+		{"*", "2.0.0"},
+		{"^3.0.0", ""},
+	}
+	for _, tt := range tests {
+		if got := MaxSatisfying(versions, MustParseRange(tt.rng)); got != tt.want {
+			t.Errorf("MaxSatisfying(%q) = %q, want %q", tt.rng, got, tt.want)
+		}
+	}
+}
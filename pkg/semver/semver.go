@@ -0,0 +1,339 @@
+package semver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version represents a semantic version (https://semver.org)
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      []string
+}
+
+// Parse parses a version string such as "1.2.3", "v1.2.3-beta.1" or
+// "1.2.3+build.5"
+func Parse(s string) (*Version, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "=")
+	s = strings.TrimPrefix(s, "v")
+
+	v := &Version{}
+
+	if idx := strings.Index(s, "+"); idx >= 0 {
+		build := s[idx+1:]
+		s = s[:idx]
+		if build == "" {
+			return nil, fmt.Errorf("invalid version %q: empty build metadata", orig)
+		}
+		v.Build = strings.Split(build, ".")
+		for _, id := range v.Build {
+			if !isIdentifier(id) {
+				return nil, fmt.Errorf("invalid version %q: bad build identifier %q", orig, id)
+			}
+		}
+	}
+
+	if idx := strings.Index(s, "-"); idx >= 0 {
+		pre := s[idx+1:]
+		s = s[:idx]
+		if pre == "" {
+			return nil, fmt.Errorf("invalid version %q: empty prerelease", orig)
+		}
+		v.Prerelease = strings.Split(pre, ".")
+		for _, id := range v.Prerelease {
+			if !isIdentifier(id) {
+				return nil, fmt.Errorf("invalid version %q: bad prerelease identifier %q", orig, id)
+			}
+			if isNumeric(id) && len(id) > 1 && id[0] == '0' {
+				return nil, fmt.Errorf("invalid version %q: prerelease %q has leading zero", orig, id)
+			}
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid version %q: expected MAJOR.MINOR.PATCH", orig)
+	}
+
+	nums := make([]uint64, 3)
+	for i, part := range parts {
+		n, err := parseNumber(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %v", orig, err)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+
+	return v, nil
+}
+
+// MustParse is like Parse but panics if the version cannot be parsed
+func MustParse(s string) *Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// IsValid reports whether s is a valid semantic version
+func IsValid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// String returns the canonical string form of the version
+func (v *Version) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		b.WriteString("-")
+		b.WriteString(strings.Join(v.Prerelease, "."))
+	}
+	if len(v.Build) > 0 {
+		b.WriteString("+")
+		b.WriteString(strings.Join(v.Build, "."))
+	}
+	return b.String()
+}
+
+// IsPrerelease reports whether the version carries prerelease identifiers
+func (v *Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Compare compares v to o, ignoring build metadata.
+// Returns -1 if v < o, 0 if v == o, 1 if v > o
+func (v *Version) Compare(o *Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// LessThan reports whether v sorts before o
+func (v *Version) LessThan(o *Version) bool {
+	return v.Compare(o) < 0
+}
+
+// Equal reports whether v and o have the same precedence
+func (v *Version) Equal(o *Version) bool {
+	return v.Compare(o) == 0
+}
+
+// IncMajor returns the next major version (1.2.3 -> 2.0.0).
+// A prerelease of a major version is promoted to it (2.0.0-beta -> 2.0.0)
+func (v *Version) IncMajor() *Version {
+	if v.IsPrerelease() && v.Minor == 0 && v.Patch == 0 {
+		return &Version{Major: v.Major}
+	}
+	return &Version{Major: v.Major + 1}
+}
+
+// IncMinor returns the next minor version (1.2.3 -> 1.3.0).
+// A prerelease of a minor version is promoted to it (1.3.0-beta -> 1.3.0)
+func (v *Version) IncMinor() *Version {
+	if v.IsPrerelease() && v.Patch == 0 {
+		return &Version{Major: v.Major, Minor: v.Minor}
+	}
+	return &Version{Major: v.Major, Minor: v.Minor + 1}
+}
+
+// IncPatch returns the next patch version (1.2.3 -> 1.2.4).
+// A prerelease is promoted to its release (1.2.3-beta -> 1.2.3)
+func (v *Version) IncPatch() *Version {
+	if v.IsPrerelease() {
+		return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	}
+	return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// IncPrerelease returns the next prerelease version. A release gets its
+// next patch as a prerelease (1.2.3 -> 1.2.4-0, or 1.2.4-beta.0 with preid
+// "beta"); a prerelease has its last numeric identifier incremented
+// (1.2.4-beta.0 -> 1.2.4-beta.1). A different preid starts over at 0
+func (v *Version) IncPrerelease(preid string) *Version {
+	start := []string{"0"}
+	if preid != "" {
+		start = []string{preid, "0"}
+	}
+
+	if !v.IsPrerelease() {
+		return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, Prerelease: start}
+	}
+
+	next := &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	if preid != "" && v.Prerelease[0] != preid {
+		next.Prerelease = start
+		return next
+	}
+
+	next.Prerelease = append([]string(nil), v.Prerelease...)
+	for i := len(next.Prerelease) - 1; i >= 0; i-- {
+		if isNumeric(next.Prerelease[i]) {
+			n, _ := strconv.ParseUint(next.Prerelease[i], 10, 64)
+			next.Prerelease[i] = strconv.FormatUint(n+1, 10)
+			return next
+		}
+	}
+	next.Prerelease = append(next.Prerelease, "0")
+	return next
+}
+
+// Compare compares two version strings.
+// Returns -1 if a < b, 0 if a == b, 1 if a > b
+func Compare(a, b string) (int, error) {
+	va, err := Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// Sort sorts versions in ascending order
+func Sort(versions []*Version) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LessThan(versions[j])
+	})
+}
+
+// Max returns the highest valid version in the list, skipping prereleases
+// unless nothing else is available. Returns "" if no entry parses
+func Max(versions []string) string {
+	var best, bestPre *Version
+	var bestRaw, bestPreRaw string
+
+	for _, raw := range versions {
+		v, err := Parse(raw)
+		if err != nil {
+			continue
+		}
+		if v.IsPrerelease() {
+			if bestPre == nil || bestPre.LessThan(v) {
+				bestPre, bestPreRaw = v, raw
+			}
+			continue
+		}
+		if best == nil || best.LessThan(v) {
+			best, bestRaw = v, raw
+		}
+	}
+
+	if best != nil {
+		return bestRaw
+	}
+	return bestPreRaw
+}
+
+func comparePrerelease(a, b []string) int {
+	// A version without prerelease has higher precedence
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareInt(len(a), len(b))
+}
+
+func compareIdentifier(a, b string) int {
+	aNum, bNum := isNumeric(a), isNumeric(b)
+
+	switch {
+	case aNum && bNum:
+		ai, _ := strconv.ParseUint(a, 10, 64)
+		bi, _ := strconv.ParseUint(b, 10, 64)
+		return compareUint(ai, bi)
+	case aNum:
+		// Numeric identifiers have lower precedence than alphanumeric ones
+		return -1
+	case bNum:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func parseNumber(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty version component")
+	}
+	if !isNumeric(s) {
+		return 0, fmt.Errorf("non-numeric version component %q", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("version component %q has leading zero", s)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !((r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
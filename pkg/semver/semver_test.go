@@ -0,0 +1,134 @@
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{"=1.2.3", "1.2.3"},
+		{" 1.2.3 ", "1.2.3"},
+		{"0.0.0", "0.0.0"},
+		{"1.2.3-beta.1", "1.2.3-beta.1"},
+		{"1.2.3-0.3.7", "1.2.3-0.3.7"},
+		{"1.2.3-x-y.7", "1.2.3-x-y.7"},
+		{"1.2.3+build.5", "1.2.3+build.5"},
+		{"1.2.3-rc.1+sha.5114f85", "1.2.3-rc.1+sha.5114f85"},
+	}
+	for _, tt := range tests {
+		v, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := v.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"1",
+		"1.2",
+		"1.2.3.4",
+		"1.2.x",
+		"a.b.c",
+		"01.2.3",
+		"1.02.3",
+		"1.2.3-",
+		"1.2.3-01",
+		"1.2.3-beta..1",
+		"1.2.3-beta_1",
+		"1.2.3+",
+		"-1.2.3",
+	} {
+		if v, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %s, want an error", in, v)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Ascending precedence, from the semver spec plus numeric ordering
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.9.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, b := ordered[i], ordered[i+1]
+		if c, err := Compare(a, b); err != nil || c != -1 {
+			t.Errorf("Compare(%s, %s) = %d, %v, want -1", a, b, c, err)
+		}
+		if c, err := Compare(b, a); err != nil || c != 1 {
+			t.Errorf("Compare(%s, %s) = %d, %v, want 1", b, a, c, err)
+		}
+	}
+
+	if c, err := Compare("1.0.0+a", "1.0.0+b"); err != nil || c != 0 {
+		t.Errorf("build metadata affects precedence: Compare = %d, %v", c, err)
+	}
+	if _, err := Compare("1.0.0", "latest"); err == nil {
+		t.Error("Compare with an invalid version succeeded")
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	tests := []struct {
+		name string
+		inc  func(*Version) *Version
+		in   string
+		want string
+	}{
+		{"major", (*Version).IncMajor, "1.2.3", "2.0.0"},
+		{"major", (*Version).IncMajor, "2.0.0-beta", "2.0.0"},
+		{"major", (*Version).IncMajor, "2.1.0-beta", "3.0.0"},
+		{"minor", (*Version).IncMinor, "1.2.3", "1.3.0"},
+		{"minor", (*Version).IncMinor, "1.3.0-beta", "1.3.0"},
+		{"patch", (*Version).IncPatch, "1.2.3", "1.2.4"},
+		{"patch", (*Version).IncPatch, "1.2.3-beta", "1.2.3"},
+		{"prerelease", func(v *Version) *Version { return v.IncPrerelease("") }, "1.2.3", "1.2.4-0"},
+		{"prerelease", func(v *Version) *Version { return v.IncPrerelease("") }, "1.2.4-0", "1.2.4-1"},
+		{"prerelease", func(v *Version) *Version { return v.IncPrerelease("") }, "1.2.4-beta", "1.2.4-beta.0"},
+		{"prerelease beta", func(v *Version) *Version { return v.IncPrerelease("beta") }, "1.2.3", "1.2.4-beta.0"},
+		{"prerelease beta", func(v *Version) *Version { return v.IncPrerelease("beta") }, "1.2.4-beta.0", "1.2.4-beta.1"},
+		{"prerelease rc", func(v *Version) *Version { return v.IncPrerelease("rc") }, "1.2.4-beta.1", "1.2.4-rc.0"},
+	}
+	for _, tt := range tests {
+		if got := tt.inc(MustParse(tt.in)).String(); got != tt.want {
+			t.Errorf("%s of %s = %s, want %s", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMax(t *testing.T) {
+	tests := []struct {
+		versions []string
+		want     string
+	}{
+		{[]string{"1.9.0", "1.10.0", "1.2.0"}, "1.10.0"},
+		{[]string{"1.0.0", "2.0.0-beta"}, "1.0.0"},
+		{[]string{"1.0.0-alpha", "1.0.0-beta"}, "1.0.0-beta"},
+		{[]string{"latest", "v1.0.0"}, "v1.0.0"},
+		{[]string{"latest"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Max(tt.versions); got != tt.want {
+			t.Errorf("Max(%q) = %q, want %q", tt.versions, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Algorithm is the only signature algorithm droy-pm uses
+const Algorithm = "ed25519"
+
+// Policies for verifying package signatures on install
+const (
+	PolicyOff     = "off"
+	PolicyWarn    = "warn"
+	PolicyRequire = "require"
+)
+
+var (
+	// ErrUnsigned is returned when a package has no signature
+	ErrUnsigned = errors.New("package is not signed")
+
+	// ErrUntrusted is returned when a package is signed by a key that is
+	// not trusted
+	ErrUntrusted = errors.New("package is signed by an untrusted key")
+)
+
+var keyNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// PublicKey is a key that verifies signatures
+type PublicKey struct {
+	Name string
+	Key  ed25519.PublicKey
+}
+
+// ID identifies the key in signatures: the first 16 hex digits of the
+// SHA-256 of the key
+func (k *PublicKey) ID() string {
+	sum := sha256.Sum256(k.Key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// String returns the key as "ed25519:<base64>", the form trusted-keys and
+// keys export use
+func (k *PublicKey) String() string {
+	return Algorithm + ":" + base64.StdEncoding.EncodeToString(k.Key)
+}
+
+// ParsePublicKey parses a key in the "ed25519:<base64> [name]" form
+func ParsePublicKey(s string) (*PublicKey, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty public key")
+	}
+
+	encoded, ok := strings.CutPrefix(fields[0], Algorithm+":")
+	if !ok {
+		return nil, fmt.Errorf("public key must start with %s:", Algorithm)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid %s public key", Algorithm)
+	}
+
+	key := &PublicKey{Key: ed25519.PublicKey(raw)}
+	if len(fields) > 1 {
+		key.Name = fields[1]
+	}
+	return key, nil
+}
+
+// Key is a private signing key
+type Key struct {
+	Name    string
+	Private ed25519.PrivateKey
+}
+
+// Public returns the key's public half
+func (k *Key) Public() *PublicKey {
+	return &PublicKey{Name: k.Name, Key: k.Private.Public().(ed25519.PublicKey)}
+}
+
+// Generate creates a key pair in dir as <name>.key, readable only by the
+// user, and <name>.pub. Existing keys are never overwritten
+func Generate(dir, name string) (*Key, error) {
+	if !keyNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid key name %q", name)
+	}
+	if _, err := os.Stat(privatePath(dir, name)); err == nil {
+		return nil, fmt.Errorf("key %s already exists", name)
+	}
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{Name: name, Private: private}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create keys directory: %w", err)
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(privatePath(dir, name), block, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write private key: %w", err)
+	}
+	public := key.Public().String() + " " + name + "\n"
+	if err := os.WriteFile(publicPath(dir, name), []byte(public), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	return key, nil
+}
+
+// Load reads the private key <name>.key from dir
+func Load(dir, name string) (*Key, error) {
+	data, err := os.ReadFile(privatePath(dir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no key named %s in %s", name, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s.key is not a PEM file", name)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s.key: %w", name, err)
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s.key is not an %s key", name, Algorithm)
+	}
+
+	return &Key{Name: name, Private: private}, nil
+}
+
+// List returns the public keys in dir, sorted by name
+func List(dir string) ([]*PublicKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	keys := make([]*PublicKey, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePublicKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		key.Name = strings.TrimSuffix(filepath.Base(file), ".pub")
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func privatePath(dir, name string) string { return filepath.Join(dir, name+".key") }
+func publicPath(dir, name string) string  { return filepath.Join(dir, name+".pub") }
+
+// Signature is a detached signature of a package version
+type Signature struct {
+	KeyID     string `json:"keyid"`
+	Algorithm string `json:"algorithm"`
+	Signature string `json:"signature"`
+}
+
+// message is what gets signed: the package, its version and the integrity
+// of its tarball, so a signature cannot be reused for another package
+func message(name, version, integrity string) []byte {
+	return []byte(fmt.Sprintf("droy-signature-v1\n%s@%s\n%s\n", name, version, integrity))
+}
+
+// Sign signs a package version with the integrity hash of its tarball
+func Sign(key *Key, name, version, integrity string) *Signature {
+	sig := ed25519.Sign(key.Private, message(name, version, integrity))
+	return &Signature{
+		KeyID:     key.Public().ID(),
+		Algorithm: Algorithm,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}
+}
+
+// ParseSignature decodes a signature as uploaded to the registry
+func ParseSignature(data []byte) (*Signature, error) {
+	var sig Signature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	return &sig, nil
+}
+
+// Marshal encodes a signature for upload
+func (s *Signature) Marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Verify checks a signature of a package version against the trusted keys
+func Verify(sig *Signature, trusted []*PublicKey, name, version, integrity string) (*PublicKey, error) {
+	if sig.Algorithm != Algorithm {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+
+	var key *PublicKey
+	for _, k := range trusted {
+		if k.ID() == sig.KeyID {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w %s", ErrUntrusted, sig.KeyID)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err == nil {
+		// The integrity may list several hashes; the signature covers one
+		for _, hash := range strings.Fields(integrity) {
+			if ed25519.Verify(key.Key, message(name, version, hash), raw) {
+				return key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid signature from key %s", sig.KeyID)
+}
+
+// Verifier applies a signature policy to installed packages
+type Verifier struct {
+	// Policy is PolicyOff, PolicyWarn or PolicyRequire
+	Policy string
+
+	// Trusted are the keys packages may be signed with
+	Trusted []*PublicKey
+}
+
+// NewVerifier creates a verifier trusting keys given as "ed25519:<base64>"
+func NewVerifier(policy string, trusted []string) (*Verifier, error) {
+	v := &Verifier{Policy: policy}
+	for _, s := range trusted {
+		key, err := ParsePublicKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted key %q: %w", s, err)
+		}
+		v.Trusted = append(v.Trusted, key)
+	}
+	return v, nil
+}
@@ -0,0 +1,153 @@
+package signing
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const integrity = "sha512-ZGVhZGJlZWY="
+
+func TestSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	key, err := Generate(dir, "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := []*PublicKey{key.Public()}
+
+	sig := Sign(key, "lib", "1.0.0", integrity)
+	data, err := sig.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseSignature(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := Verify(parsed, trusted, "lib", "1.0.0", integrity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.ID() != key.Public().ID() {
+		t.Errorf("signed by %s, want %s", signer.ID(), key.Public().ID())
+	}
+
+	// The signature covers one of several hashes
+	if _, err := Verify(parsed, trusted, "lib", "1.0.0", "sha256-YWJj "+integrity); err != nil {
+		t.Errorf("Verify with several hashes: %v", err)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	key, err := Generate(t.TempDir(), "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := []*PublicKey{key.Public()}
+	sig := Sign(key, "lib", "1.0.0", integrity)
+
+	tests := []struct {
+		desc, name, version, integrity string
+	}{
+		{"integrity", "lib", "1.0.0", "sha512-b3RoZXI="},
+		{"name", "other", "1.0.0", integrity},
+		{"version", "lib", "1.0.1", integrity},
+	}
+	for _, tt := range tests {
+		_, err := Verify(sig, trusted, tt.name, tt.version, tt.integrity)
+		if err == nil {
+			t.Errorf("Verify accepted a signature with another %s", tt.desc)
+		} else if errors.Is(err, ErrUntrusted) {
+			t.Errorf("Verify with another %s = %v, want an invalid signature", tt.desc, err)
+		}
+	}
+
+	bad := *sig
+	bad.Signature = "not base64"
+	if _, err := Verify(&bad, trusted, "lib", "1.0.0", integrity); err == nil {
+		t.Error("Verify accepted a malformed signature")
+	}
+}
+
+func TestVerifyRejectsUntrustedKeys(t *testing.T) {
+	dir := t.TempDir()
+	key, err := Generate(dir, "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Generate(dir, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := Sign(key, "lib", "1.0.0", integrity)
+
+	for _, trusted := range [][]*PublicKey{nil, {other.Public()}} {
+		if _, err := Verify(sig, trusted, "lib", "1.0.0", integrity); !errors.Is(err, ErrUntrusted) {
+			t.Errorf("Verify trusting %d other keys = %v, want ErrUntrusted", len(trusted), err)
+		}
+	}
+
+	// A key claiming a trusted ID must still produce a valid signature
+	forged := Sign(other, "lib", "1.0.0", integrity)
+	forged.KeyID = key.Public().ID()
+	if _, err := Verify(forged, []*PublicKey{key.Public()}, "lib", "1.0.0", integrity); err == nil {
+		t.Error("Verify accepted a signature made by another key")
+	}
+}
+
+func TestKeysRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys")
+	key, err := Generate(dir, "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(dir, "release"); err == nil {
+		t.Error("Generate overwrote an existing key")
+	}
+	if _, err := Generate(dir, "../escape"); err == nil {
+		t.Error("Generate accepted an invalid key name")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "release.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("release.key mode = %o, want 600", info.Mode().Perm())
+	}
+
+	loaded, err := Load(dir, "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Private.Equal(key.Private) {
+		t.Error("Load returned another key")
+	}
+
+	keys, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Name != "release" || keys[0].ID() != key.Public().ID() {
+		t.Errorf("List = %v, want the release key", keys)
+	}
+
+	parsed, err := ParsePublicKey(key.Public().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ID() != key.Public().ID() {
+		t.Errorf("ParsePublicKey(String()) has ID %s, want %s", parsed.ID(), key.Public().ID())
+	}
+	for _, s := range []string{"", "rsa:AAAA", "ed25519:AAAA", "ed25519:not-base64"} {
+		if _, err := ParsePublicKey(s); err == nil {
+			t.Errorf("ParsePublicKey(%q) succeeded", s)
+		}
+	}
+	if _, err := NewVerifier(PolicyRequire, []string{"ed25519:AAAA"}); err == nil {
+		t.Error("NewVerifier accepted an invalid trusted key")
+	}
+}
@@ -0,0 +1,284 @@
+package spdx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unlicensed marks a package that grants no license, typically
+// proprietary code published to a private registry
+const Unlicensed = "UNLICENSED"
+
+// licenseIDs are the SPDX license identifiers droy-pm recognizes: the
+// licenses commonly used for open source packages
+var licenseIDs = []string{
+	"0BSD", "AFL-3.0", "AGPL-1.0-only", "AGPL-1.0-or-later", "AGPL-3.0-only",
+	"AGPL-3.0-or-later", "Apache-1.0", "Apache-1.1", "Apache-2.0", "APSL-2.0",
+	"Artistic-1.0", "Artistic-2.0", "Beerware", "BlueOak-1.0.0", "BSD-1-Clause",
+	"BSD-2-Clause", "BSD-2-Clause-Patent", "BSD-3-Clause", "BSD-3-Clause-Clear",
+	"BSD-4-Clause", "BSL-1.0", "CC-BY-1.0", "CC-BY-2.0", "CC-BY-3.0", "CC-BY-4.0",
+	"CC-BY-NC-4.0", "CC-BY-NC-SA-4.0", "CC-BY-ND-4.0", "CC-BY-SA-3.0",
+	"CC-BY-SA-4.0", "CC0-1.0", "CDDL-1.0", "CDDL-1.1", "CECILL-2.1", "CPL-1.0",
+	"ECL-2.0", "EFL-2.0", "EPL-1.0", "EPL-2.0", "EUPL-1.1", "EUPL-1.2",
+	"GFDL-1.3-only", "GFDL-1.3-or-later", "GPL-1.0-only", "GPL-1.0-or-later",
+	"GPL-2.0-only", "GPL-2.0-or-later", "GPL-3.0-only", "GPL-3.0-or-later",
+	"HPND", "ICU", "IJG", "IPL-1.0", "ISC", "LGPL-2.0-only", "LGPL-2.0-or-later",
+	"LGPL-2.1-only", "LGPL-2.1-or-later", "LGPL-3.0-only", "LGPL-3.0-or-later",
+	"LPL-1.02", "LPPL-1.3c", "MIT", "MIT-0", "MPL-1.1", "MPL-2.0",
+	"MPL-2.0-no-copyleft-exception", "MS-PL", "MS-RL", "MulanPSL-2.0", "NCSA",
+	"ODbL-1.0", "OFL-1.1", "OpenSSL", "OSL-3.0", "PHP-3.01", "PostgreSQL",
+	"PSF-2.0", "Python-2.0", "QPL-1.0", "Ruby", "SSPL-1.0", "Unicode-DFS-2016",
+	"Unlicense", "UPL-1.0", "Vim", "W3C", "WTFPL", "X11", "Zlib", "ZPL-2.1",
+
+	// Deprecated identifiers that are still common in manifests
+	"AGPL-3.0", "GPL-2.0", "GPL-2.0+", "GPL-3.0", "GPL-3.0+", "LGPL-2.1",
+	"LGPL-2.1+", "LGPL-3.0", "LGPL-3.0+",
+}
+
+// exceptionIDs are the SPDX exceptions allowed after WITH
+var exceptionIDs = []string{
+	"Autoconf-exception-3.0", "Bison-exception-2.2", "Classpath-exception-2.0",
+	"GCC-exception-3.1", "LLVM-exception", "Linux-syscall-note",
+	"OpenJDK-assembly-exception-1.0", "Qt-LGPL-exception-1.1",
+	"Swift-exception", "Universal-FOSS-exception-1.0",
+}
+
+var (
+	licenses   = index(licenseIDs)
+	exceptions = index(exceptionIDs)
+)
+
+// index maps lower-cased identifiers to their canonical spelling, since
+// SPDX identifiers are matched case-insensitively
+func index(ids []string) map[string]string {
+	m := make(map[string]string, len(ids))
+	for _, id := range ids {
+		m[strings.ToLower(id)] = id
+	}
+	return m
+}
+
+// LookupLicense returns the canonical spelling of a license identifier
+func LookupLicense(id string) (string, bool) {
+	canonical, ok := licenses[strings.ToLower(id)]
+	return canonical, ok
+}
+
+// LookupException returns the canonical spelling of an exception
+// identifier
+func LookupException(id string) (string, bool) {
+	canonical, ok := exceptions[strings.ToLower(id)]
+	return canonical, ok
+}
+
+// Expr is a parsed SPDX license expression
+type Expr interface {
+	String() string
+
+	// Licenses returns the license identifiers in the expression
+	Licenses() []string
+}
+
+// License is a single license, optionally "or later" (+) and with an
+// exception. LicenseRef-* identifiers name licenses outside the SPDX list
+type License struct {
+	ID        string
+	OrLater   bool
+	Exception string
+}
+
+// And requires both licenses to be complied with
+type And struct {
+	Left, Right Expr
+}
+
+// Or allows a choice between licenses
+type Or struct {
+	Left, Right Expr
+}
+
+func (l *License) String() string {
+	s := l.ID
+	if l.OrLater {
+		s += "+"
+	}
+	if l.Exception != "" {
+		s += " WITH " + l.Exception
+	}
+	return s
+}
+
+func (e *And) String() string { return group(e.Left, true) + " AND " + group(e.Right, true) }
+func (e *Or) String() string  { return group(e.Left, false) + " OR " + group(e.Right, false) }
+
+// group parenthesizes OR expressions inside AND, where precedence requires it
+func group(e Expr, inAnd bool) string {
+	if _, ok := e.(*Or); ok && inAnd {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+func (l *License) Licenses() []string { return []string{l.ID} }
+func (e *And) Licenses() []string     { return append(e.Left.Licenses(), e.Right.Licenses()...) }
+func (e *Or) Licenses() []string      { return append(e.Left.Licenses(), e.Right.Licenses()...) }
+
+// Parse parses an SPDX license expression such as "MIT",
+// "Apache-2.0 OR MIT" or "(GPL-2.0-only WITH Classpath-exception-2.0) AND
+// BSD-3-Clause". Identifiers must be on the SPDX list or start with
+// LicenseRef-; they are returned in their canonical spelling. AND binds
+// tighter than OR
+func Parse(expr string) (Expr, error) {
+	p := &parser{tokens: tokenize(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty license expression")
+	}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != "" {
+		return nil, fmt.Errorf("unexpected %q in license expression %q", tok, expr)
+	}
+	return e, nil
+}
+
+// Satisfies reports whether an expression can be complied with using only
+// licenses that allowed accepts: both sides of an AND must be accepted,
+// and at least one side of an OR
+func Satisfies(e Expr, allowed func(l *License) bool) bool {
+	switch e := e.(type) {
+	case *License:
+		return allowed(e)
+	case *And:
+		return Satisfies(e.Left, allowed) && Satisfies(e.Right, allowed)
+	case *Or:
+		return Satisfies(e.Left, allowed) || Satisfies(e.Right, allowed)
+	}
+	return false
+}
+
+// Valid reports whether expr is a valid SPDX license expression
+func Valid(expr string) bool {
+	_, err := Parse(expr)
+	return err == nil
+}
+
+func tokenize(expr string) []string {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	return strings.Fields(expr)
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "OR") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "AND") {
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (Expr, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("license expression ends too early")
+	case tok == "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in license expression")
+		}
+		return e, nil
+	case tok == ")" || isOperator(tok):
+		return nil, fmt.Errorf("unexpected %q in license expression", tok)
+	}
+
+	license, err := parseLicense(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(p.peek(), "WITH") {
+		p.next()
+		exception, ok := LookupException(p.next())
+		if !ok {
+			return nil, fmt.Errorf("unknown license exception after WITH in %q", license.ID)
+		}
+		license.Exception = exception
+	}
+	return license, nil
+}
+
+func parseLicense(tok string) (*License, error) {
+	license := &License{}
+
+	if strings.HasPrefix(tok, "LicenseRef-") || strings.HasPrefix(tok, "DocumentRef-") {
+		license.ID = tok
+		return license, nil
+	}
+
+	// Deprecated ids such as GPL-2.0+ are listed with their plus
+	if id, ok := LookupLicense(tok); ok {
+		license.ID = id
+		return license, nil
+	}
+
+	if base, ok := strings.CutSuffix(tok, "+"); ok {
+		if id, ok := LookupLicense(base); ok {
+			license.ID = id
+			license.OrLater = true
+			return license, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown SPDX license identifier %q", tok)
+}
+
+func isOperator(tok string) bool {
+	return strings.EqualFold(tok, "AND") || strings.EqualFold(tok, "OR") || strings.EqualFold(tok, "WITH")
+}
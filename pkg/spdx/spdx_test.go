@@ -0,0 +1,137 @@
+package spdx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr, want string
+	}{
+		{"MIT", "MIT"},
+		{"mit", "MIT"},
+		{"Apache-2.0 OR MIT", "Apache-2.0 OR MIT"},
+		{"mit and isc", "MIT AND ISC"},
+		{"(MIT OR Apache-2.0) AND BSD-3-Clause", "(MIT OR Apache-2.0) AND BSD-3-Clause"},
+		{"((MIT))", "MIT"},
+		{"MIT OR (Apache-2.0 AND BSD-3-Clause)", "MIT OR Apache-2.0 AND BSD-3-Clause"},
+		{"GPL-2.0-only WITH classpath-exception-2.0", "GPL-2.0-only WITH Classpath-exception-2.0"},
+		{"Apache-2.0+", "Apache-2.0+"},
+		{"GPL-2.0+", "GPL-2.0+"},
+		{"LicenseRef-Acme", "LicenseRef-Acme"},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := e.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+		again, err := Parse(e.String())
+		if err != nil || again.String() != e.String() {
+			t.Errorf("Parse(%q) does not round trip: %v, %v", e.String(), again, err)
+		}
+	}
+}
+
+func TestParseLicenseParts(t *testing.T) {
+	e, err := Parse("Apache-2.0+ WITH LLVM-exception")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := e.(*License)
+	if !ok || l.ID != "Apache-2.0" || !l.OrLater || l.Exception != "LLVM-exception" {
+		t.Errorf("Parse = %#v, want Apache-2.0 or later with LLVM-exception", e)
+	}
+
+	// Deprecated identifiers keep their plus rather than meaning "or later"
+	e, err = Parse("GPL-3.0+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := e.(*License); !ok || l.ID != "GPL-3.0+" || l.OrLater {
+		t.Errorf("Parse(GPL-3.0+) = %#v, want the deprecated GPL-3.0+ identifier", e)
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	e, err := Parse("MIT AND ISC OR Apache-2.0 AND BSD-3-Clause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	or, ok := e.(*Or)
+	if !ok {
+		t.Fatalf("Parse = %#v, want OR at the top", e)
+	}
+	if _, ok := or.Left.(*And); !ok {
+		t.Errorf("left of OR = %#v, want AND", or.Left)
+	}
+	if _, ok := or.Right.(*And); !ok {
+		t.Errorf("right of OR = %#v, want AND", or.Right)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"   ",
+		"GPLv3",
+		"MIT OR",
+		"OR MIT",
+		"MIT ISC",
+		"(MIT",
+		"MIT)",
+		"()",
+		"MIT WITH",
+		"MIT WITH Unknown-exception",
+		"WITH MIT",
+		"MIT AND AND ISC",
+	} {
+		if e, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) = %q, want an error", expr, e)
+		}
+		if Valid(expr) {
+			t.Errorf("Valid(%q) = true", expr)
+		}
+	}
+}
+
+func TestLicenses(t *testing.T) {
+	e, err := Parse("(MIT OR Apache-2.0 WITH LLVM-exception) AND BSD-3-Clause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "MIT Apache-2.0 BSD-3-Clause"
+	if got := strings.Join(e.Licenses(), " "); got != want {
+		t.Errorf("Licenses = %s, want %s", got, want)
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	notGPL := func(l *License) bool { return !strings.HasPrefix(l.ID, "GPL-") }
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"MIT", true},
+		{"GPL-3.0-only", false},
+		{"MIT OR GPL-3.0-only", true},
+		{"MIT AND GPL-3.0-only", false},
+		{"(GPL-3.0-only OR MIT) AND ISC", true},
+		{"GPL-3.0-only OR MIT AND GPL-2.0-only", false},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Satisfies(e, notGPL); got != tt.want {
+			t.Errorf("Satisfies(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
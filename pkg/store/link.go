@@ -0,0 +1,68 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Method is the way a stored package was linked into droy_modules
+type Method string
+
+// Link methods, in order of preference
+const (
+	Hardlink Method = "hardlink"
+	Reflink  Method = "reflink"
+	Symlink  Method = "symlink"
+)
+
+// Link makes the stored package at src available at dst. Files are
+// hardlinked where possible; across file systems they are cloned with a
+// reflink, and if neither works dst becomes a symlink to src.
+func Link(src, dst string) (Method, error) {
+	if err := linkTree(src, dst, os.Link); err == nil {
+		return Hardlink, nil
+	}
+	os.RemoveAll(dst)
+
+	if err := linkTree(src, dst, reflink); err == nil {
+		return Reflink, nil
+	}
+	os.RemoveAll(dst)
+
+	if err := os.Symlink(src, dst); err != nil {
+		return "", fmt.Errorf("failed to link %s: %w", src, err)
+	}
+	return Symlink, nil
+}
+
+// linkTree recreates the directory tree of src at dst, creating each
+// regular file with link
+func linkTree(src, dst string, link func(oldname, newname string) error) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			linkname, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(linkname, target)
+		case info.Mode().IsRegular():
+			return link(path, target)
+		default:
+			return nil
+		}
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package store
+
+import "errors"
+
+// reflink is only implemented on Linux
+func reflink(src, dst string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,425 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// Store is a content-addressable package store. Each package is extracted
+// once, under a directory derived from its integrity hash, and linked into
+// the droy_modules of every project that uses it.
+type Store struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// New creates a store rooted at path
+func New(path string) *Store {
+	return &Store{Path: path}
+}
+
+// Default returns the store in ~/.droy/store
+func Default() *Store {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return New(filepath.Join(".droy", "store"))
+	}
+	return New(filepath.Join(homeDir, ".droy", "store"))
+}
+
+// packagesDir holds the extracted packages, two levels deep by hash
+func (s *Store) packagesDir() string {
+	return filepath.Join(s.Path, "v1")
+}
+
+// projectsFile lists the droy_modules directories linked from the store
+func (s *Store) projectsFile() string {
+	return filepath.Join(s.Path, "projects")
+}
+
+// EntryPath returns the directory a package with the given integrity is
+// stored in. The first hash of the integrity string is used as the key
+func (s *Store) EntryPath(integrity string) (string, error) {
+	fields := strings.Fields(integrity)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("missing integrity")
+	}
+
+	algo, digest, ok := strings.Cut(fields[0], "-")
+	if !ok || algo == "" {
+		return "", fmt.Errorf("invalid integrity %q", fields[0])
+	}
+	raw, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil || len(raw) < 2 {
+		return "", fmt.Errorf("invalid integrity %q", fields[0])
+	}
+	sum := hex.EncodeToString(raw)
+
+	return filepath.Join(s.packagesDir(), algo, sum[:2], sum[2:]), nil
+}
+
+// Has reports whether a package with the given integrity is in the store
+func (s *Store) Has(integrity string) bool {
+	path, err := s.EntryPath(integrity)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Add makes sure a package is in the store, calling fill to populate a
+// fresh directory if it is not, and returns the entry's path. Entries are
+// written to a temporary directory and renamed into place, so a partially
+// extracted package is never visible.
+func (s *Store) Add(integrity string, fill func(dir string) error) (string, error) {
+	path, err := s.EntryPath(integrity)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create store directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	staged := filepath.Join(tmp, "package")
+	if err := fill(staged); err != nil {
+		return "", err
+	}
+
+	// Hardlinked files are shared with every project using the package, so
+	// they are made read-only to keep an edit in one project from reaching
+	// the others and the store
+	if err := readOnly(staged); err != nil {
+		return "", fmt.Errorf("failed to add package to store: %w", err)
+	}
+
+	if err := os.Rename(staged, path); err != nil {
+		// Another install may have stored the same package meanwhile
+		if _, statErr := os.Stat(path); statErr == nil {
+			return path, nil
+		}
+		return "", fmt.Errorf("failed to add package to store: %w", err)
+	}
+
+	return path, nil
+}
+
+// readOnly removes write permission from the regular files under dir.
+// Directories stay writable so that entries can still be pruned
+func readOnly(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return os.Chmod(path, info.Mode().Perm()&^0222)
+	})
+}
+
+// RegisterProject records a droy_modules directory as using the store, so
+// that Prune knows which packages are still needed
+func (s *Store) RegisterProject(modulesPath string) error {
+	abs, err := filepath.Abs(modulesPath)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	projects, err := s.readProjects()
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if p == abs {
+			return nil
+		}
+	}
+
+	return s.writeProjects(append(projects, abs))
+}
+
+func (s *Store) readProjects() ([]string, error) {
+	data, err := os.ReadFile(s.projectsFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			projects = append(projects, line)
+		}
+	}
+	return projects, nil
+}
+
+func (s *Store) writeProjects(projects []string) error {
+	sort.Strings(projects)
+	if err := os.MkdirAll(s.Path, 0755); err != nil {
+		return err
+	}
+
+	data := strings.Join(projects, "\n")
+	if data != "" {
+		data += "\n"
+	}
+
+	tmp := s.projectsFile() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.projectsFile())
+}
+
+// Status summarizes the contents of the store
+type Status struct {
+	Path     string
+	Packages int
+	Files    int
+	Size     int64
+	Projects int
+
+	// Unused counts packages not referenced by any registered project
+	Unused int
+}
+
+// Status walks the store and reports its size and usage
+func (s *Store) Status() (*Status, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	used, projects, err := s.usedEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Path: s.Path, Packages: len(entries), Projects: len(projects)}
+	for _, entry := range entries {
+		if !used[entry] {
+			status.Unused++
+		}
+
+		err := filepath.Walk(entry, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				status.Files++
+				status.Size += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+// Prune removes packages that no registered project references any more,
+// and forgets projects that no longer exist. It returns the removed entries
+func (s *Store) Prune() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	used, projects, err := s.usedEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if used[entry] {
+			continue
+		}
+		if err := os.RemoveAll(entry); err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry)
+	}
+
+	// Leftovers of interrupted installs
+	temps, _ := filepath.Glob(filepath.Join(s.packagesDir(), "*", "*", ".tmp-*"))
+	for _, tmp := range temps {
+		os.RemoveAll(tmp)
+	}
+
+	if err := s.writeProjects(projects); err != nil {
+		return removed, err
+	}
+
+	return removed, nil
+}
+
+// entries lists the package directories in the store
+func (s *Store) entries() ([]string, error) {
+	entries, err := filepath.Glob(filepath.Join(s.packagesDir(), "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, entry := range entries {
+		if strings.HasPrefix(filepath.Base(entry), ".") {
+			continue
+		}
+		result = append(result, entry)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// usedEntries returns the store entries referenced by the droy.lock of a
+// registered project or still linked into its modules directory, along
+// with the projects that still exist
+func (s *Store) usedEntries(entries []string) (map[string]bool, []string, error) {
+	projects, err := s.readProjects()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	used := make(map[string]bool)
+	var existing []string
+	for _, modules := range projects {
+		if _, err := os.Stat(modules); err != nil {
+			continue
+		}
+		existing = append(existing, modules)
+
+		lock, err := findLock(modules)
+		if err != nil {
+			continue
+		}
+		for _, pkg := range lock.Packages {
+			if pkg.Integrity == "" {
+				continue
+			}
+			if path, err := s.EntryPath(pkg.Integrity); err == nil {
+				used[path] = true
+			}
+		}
+	}
+
+	// A package can be linked without being locked, for instance when
+	// droy.lock was deleted or could not be written
+	links := findLinks(existing)
+	for _, entry := range entries {
+		if !used[entry] && links.linksTo(entry) {
+			used[entry] = true
+		}
+	}
+
+	return used, existing, nil
+}
+
+// links are the files in modules directories that may be links into the
+// store: symlink targets, and regular files indexed by size for finding
+// hardlinks
+type links struct {
+	targets []string
+	files   map[int64][]os.FileInfo
+}
+
+// findLinks collects the links in modules directories, skipping anything
+// that cannot be read
+func findLinks(modulesDirs []string) *links {
+	l := &links{files: make(map[int64][]os.FileInfo)}
+	for _, modules := range modulesDirs {
+		filepath.Walk(modules, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				if target, err := os.Readlink(path); err == nil {
+					if !filepath.IsAbs(target) {
+						target = filepath.Join(filepath.Dir(path), target)
+					}
+					l.targets = append(l.targets, filepath.Clean(target))
+				}
+			case info.Mode().IsRegular():
+				l.files[info.Size()] = append(l.files[info.Size()], info)
+			}
+			return nil
+		})
+	}
+	return l
+}
+
+// linksTo reports whether a store entry is symlinked to, or one of its
+// files is hardlinked from a modules directory
+func (l *links) linksTo(entry string) bool {
+	for _, target := range l.targets {
+		if target == entry || strings.HasPrefix(target, entry+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	found := false
+	filepath.Walk(entry, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		for _, other := range l.files[info.Size()] {
+			if os.SameFile(info, other) {
+				found = true
+				return filepath.SkipAll
+			}
+		}
+		return nil
+	})
+	return found
+}
+
+// findLock reads the droy.lock of the project a modules directory belongs
+// to, looking in its parent directories since the modules directory is
+// configurable and need not sit next to droy.lock
+func findLock(modules string) (*config.LockFile, error) {
+	dir := filepath.Dir(modules)
+	for {
+		lock, err := config.ReadLockFile(filepath.Join(dir, "droy.lock"))
+		if err == nil {
+			return lock, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, err
+		}
+		dir = parent
+	}
+}
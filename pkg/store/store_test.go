@@ -0,0 +1,74 @@
+package store
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// addPackage stores a package holding one file and returns its entry
+func addPackage(t *testing.T, s *Store, content string) string {
+	t.Helper()
+
+	sum := sha512.Sum512([]byte(content))
+	entry, err := s.Add("sha512-"+base64.StdEncoding.EncodeToString(sum[:]), func(dir string) error {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, "index.droy"), []byte(content), 0644)
+	})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	return entry
+}
+
+func TestAddMakesFilesReadOnly(t *testing.T) {
+	s := New(t.TempDir())
+	entry := addPackage(t, s, "lib")
+
+	info, err := os.Stat(filepath.Join(entry, "index.droy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode&0222 != 0 {
+		t.Errorf("stored file mode = %v, want it read-only", mode)
+	}
+}
+
+func TestPruneKeepsLinkedEntries(t *testing.T) {
+	s := New(t.TempDir())
+	hardlinked := addPackage(t, s, "hardlinked")
+	symlinked := addPackage(t, s, "symlinked")
+	unused := addPackage(t, s, "unused")
+
+	// A project without droy.lock, so only its links show what it uses
+	modules := filepath.Join(t.TempDir(), "droy_modules")
+	if err := os.MkdirAll(modules, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := linkTree(hardlinked, filepath.Join(modules, "hardlinked"), os.Link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(symlinked, filepath.Join(modules, "symlinked")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterProject(modules); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := s.Prune()
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != unused {
+		t.Errorf("removed = %q, want only %s", removed, unused)
+	}
+	for _, entry := range []string{hardlinked, symlinked} {
+		if _, err := os.Stat(entry); err != nil {
+			t.Errorf("linked entry was pruned: %v", err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package validate
+
+import (
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// maxScanSize bounds how much of each file is searched for secrets;
+// credentials are small and large files are almost always data
+const maxScanSize = 1 << 20 // 1 MB
+
+// secretNames are files that hold credentials by convention
+var secretNames = map[string]string{
+	".npmrc":           "a registry credentials file",
+	".netrc":           "a credentials file",
+	".pypirc":          "a registry credentials file",
+	"credentials":      "a credentials file",
+	"credentials.json": "a credentials file",
+	"id_rsa":           "a private SSH key",
+	"id_dsa":           "a private SSH key",
+	"id_ecdsa":         "a private SSH key",
+	"id_ed25519":       "a private SSH key",
+}
+
+// secretExtensions are file types that hold keys and certificates
+var secretExtensions = map[string]string{
+	".pem": "a private key or certificate",
+	".key": "a private key",
+	".p12": "a certificate bundle",
+	".pfx": "a certificate bundle",
+}
+
+// secretPatterns match credentials pasted into otherwise ordinary files
+var secretPatterns = []struct {
+	pattern *regexp.Regexp
+	reason  string
+}{
+	{regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`), "a private key"},
+	{regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), "an AWS access key"},
+	{regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36}\b`), "a GitHub token"},
+}
+
+// secretFile returns why a file's name suggests it holds secrets, or ""
+func secretFile(file string) string {
+	name := path.Base(file)
+
+	// .env, .env.local and .env.production, but not .env.example templates
+	if name == ".env" || strings.HasPrefix(name, ".env.") {
+		if strings.HasSuffix(name, ".example") || strings.HasSuffix(name, ".sample") || strings.HasSuffix(name, ".template") {
+			return ""
+		}
+		return "an environment file"
+	}
+
+	if reason, ok := secretNames[name]; ok {
+		return reason
+	}
+	return secretExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// secretContent returns what kind of secret a file contains, or ""
+func secretContent(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxScanSize))
+	if err != nil {
+		return "", err
+	}
+
+	for _, secret := range secretPatterns {
+		if secret.pattern.Match(data) {
+			return secret.reason, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,214 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+	"github.com/droy-go/droy-pm/pkg/installer"
+	"github.com/droy-go/droy-pm/pkg/pack"
+	"github.com/droy-go/droy-pm/pkg/registry"
+	"github.com/droy-go/droy-pm/pkg/semver"
+	"github.com/droy-go/droy-pm/pkg/spdx"
+)
+
+// MaxNameLength is the longest package name, including the scope
+const MaxNameLength = 214
+
+// MaxPackedSize is the largest tarball that may be published
+const MaxPackedSize = 50 << 20 // 50 MB
+
+var baseNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Severity says whether a problem blocks publishing
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+// Problem is a single validation finding
+type Problem struct {
+	Severity Severity
+	Message  string
+}
+
+// Problems collects the findings of several checks
+type Problems []Problem
+
+func (p *Problems) errorf(format string, args ...interface{}) {
+	*p = append(*p, Problem{Severity: Error, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *Problems) warnf(format string, args ...interface{}) {
+	*p = append(*p, Problem{Severity: Warning, Message: fmt.Sprintf(format, args...)})
+}
+
+// Errors returns the number of problems that block publishing
+func (p Problems) Errors() int {
+	n := 0
+	for _, problem := range p {
+		if problem.Severity == Error {
+			n++
+		}
+	}
+	return n
+}
+
+// Manifest checks droy.toml: the name, a strict semver version, that the
+// package is not private and that the license is an SPDX expression
+func Manifest(pkg *config.Package) Problems {
+	var problems Problems
+
+	if err := Name(pkg.Name); err != nil {
+		problems.errorf("%v", err)
+	}
+
+	if err := Version(pkg.Version); err != nil {
+		problems.errorf("%v", err)
+	}
+
+	if pkg.Private {
+		problems.errorf("package is private; remove private = true from droy.toml to publish it")
+	}
+
+	switch {
+	case pkg.License == "":
+		problems.warnf("no license in droy.toml; others may not be allowed to use the package")
+	case pkg.License == spdx.Unlicensed:
+	default:
+		if _, err := spdx.Parse(pkg.License); err != nil {
+			problems.errorf("invalid license %q: %v", pkg.License, err)
+		}
+	}
+
+	return problems
+}
+
+// Name checks a package name against the rules in LANGUAGE_SPEC.md:
+// lowercase letters, numbers, hyphens and underscores, not starting with a
+// dot, underscore or hyphen, at most 214 characters and optionally scoped
+func Name(name string) error {
+	if name == "" {
+		return fmt.Errorf("package name is required")
+	}
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("package name %q is longer than %d characters", name, MaxNameLength)
+	}
+
+	_, base, err := registry.SplitName(name)
+	if err != nil {
+		return err
+	}
+	if !baseNamePattern.MatchString(base) {
+		return fmt.Errorf("invalid package name %q: use lowercase letters, numbers, hyphens and underscores, starting with a letter or number", name)
+	}
+	return nil
+}
+
+// Version checks that a version is strict semver: MAJOR.MINOR.PATCH with
+// optional prerelease and build parts, and no "v" or "=" prefix
+func Version(version string) error {
+	if version == "" {
+		return fmt.Errorf("package version is required")
+	}
+
+	v, err := semver.Parse(version)
+	if err != nil {
+		return err
+	}
+	if v.String() != version {
+		return fmt.Errorf("invalid version %q: write it as %s", version, v)
+	}
+	return nil
+}
+
+// Contents checks a packed tarball: main and bin targets must be in it,
+// no secrets may be, and it must fit the size limits installers enforce
+func Contents(dir string, pkg *config.Package, result *pack.Result) Problems {
+	var problems Problems
+
+	packed := make(map[string]bool, len(result.Files))
+	for _, file := range result.Files {
+		packed[file.Path] = true
+	}
+
+	if pkg.Main != "" && !packed[clean(pkg.Main)] {
+		problems.errorf("main file %s is not in the tarball", pkg.Main)
+	}
+	for name, target := range pkg.Bin {
+		if !packed[clean(target)] {
+			problems.errorf("bin %s: %s is not in the tarball", name, target)
+		}
+	}
+
+	for _, file := range result.Files {
+		if reason := secretFile(file.Path); reason != "" {
+			problems.errorf("%s looks like %s; add it to %s", file.Path, reason, pack.IgnoreFile)
+			continue
+		}
+
+		reason, err := secretContent(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			problems.errorf("failed to scan %s: %v", file.Path, err)
+		} else if reason != "" {
+			problems.errorf("%s contains %s; remove it or add the file to %s", file.Path, reason, pack.IgnoreFile)
+		}
+	}
+
+	if result.Size > MaxPackedSize {
+		problems.errorf("tarball is %d bytes, more than the %d byte limit", result.Size, MaxPackedSize)
+	}
+	if result.UnpackedSize > installer.DefaultMaxUnpackedSize {
+		problems.errorf("package unpacks to %d bytes, more than the %d byte limit", result.UnpackedSize, installer.DefaultMaxUnpackedSize)
+	}
+	if len(result.Files) > installer.DefaultMaxFiles {
+		problems.errorf("package has %d files, more than the %d file limit", len(result.Files), installer.DefaultMaxFiles)
+	}
+
+	return problems
+}
+
+// Unpublished checks that the package's version is not on the registry
+// yet. A registry that cannot be reached is only a warning, since publish
+// itself will fail if it still cannot be
+func Unpublished(reg *registry.Registry, pkg *config.Package) Problems {
+	var problems Problems
+
+	var versions []string
+	list, err := reg.GetVersions(pkg.Name)
+	if err == nil {
+		versions = list.Versions
+	} else if errors.Is(err, registry.ErrPackageNotFound) || errors.Is(err, registry.ErrNotCached) {
+		// Registries without a versions endpoint answer 404 there
+		var info *registry.PackageInfo
+		info, err = reg.GetPackage(pkg.Name)
+		if err == nil {
+			versions = info.Versions
+		}
+	}
+
+	switch {
+	case errors.Is(err, registry.ErrPackageNotFound):
+		return nil
+	case err != nil:
+		problems.warnf("could not check whether %s@%s is already published: %v", pkg.Name, pkg.Version, err)
+		return problems
+	}
+
+	for _, version := range versions {
+		if version == pkg.Version {
+			problems.errorf("%s@%s is already published; bump the version first", pkg.Name, pkg.Version)
+			break
+		}
+	}
+	return problems
+}
+
+func clean(file string) string {
+	return path.Clean(filepath.ToSlash(file))
+}
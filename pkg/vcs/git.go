@@ -0,0 +1,166 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ErrNotRepository is returned by Open outside a git repository
+var ErrNotRepository = errors.New("not a git repository")
+
+// Repo is a git repository containing a project
+type Repo struct {
+	repo *git.Repository
+	root string
+}
+
+// Open opens the git repository containing dir, searching parent
+// directories as git does
+func Open(dir string) (*Repo, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, ErrNotRepository
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{repo: repo, root: wt.Filesystem.Root()}, nil
+}
+
+// Dirty returns the tracked files with uncommitted changes, sorted.
+// Untracked files are not reported, since a commit would not include them
+func (r *Repo) Dirty() ([]string, error) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git status: %w", err)
+	}
+
+	var dirty []string
+	for file, s := range status {
+		if s.Worktree == git.Untracked && s.Staging == git.Untracked {
+			continue
+		}
+		if s.Worktree != git.Unmodified || s.Staging != git.Unmodified {
+			dirty = append(dirty, file)
+		}
+	}
+	sort.Strings(dirty)
+	return dirty, nil
+}
+
+// HasTag reports whether a tag exists
+func (r *Repo) HasTag(name string) (bool, error) {
+	_, err := r.repo.Reference(plumbing.NewTagReferenceName(name), false)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Commit stages files, given as paths relative to the current directory,
+// and commits them. The author comes from the git configuration
+func (r *Repo) Commit(message string, files ...string) (plumbing.Hash, error) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	for _, file := range files {
+		rel, err := r.relative(file)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if _, err := wt.Add(rel); err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to stage %s: %w", file, err)
+		}
+	}
+
+	hash, err := wt.Commit(message, &git.CommitOptions{})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to commit: %w", err)
+	}
+	return hash, nil
+}
+
+// Tag creates an annotated tag on a commit, tagged by the commit's author
+func (r *Repo) Tag(name, message string, hash plumbing.Hash) error {
+	commit, err := r.repo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+
+	tagger := commit.Author
+	tagger.When = time.Now()
+
+	_, err = r.repo.CreateTag(name, hash, &git.CreateTagOptions{
+		Tagger:  &tagger,
+		Message: message,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tag %s: %w", name, err)
+	}
+	return nil
+}
+
+// Head returns the commit HEAD points to, or the zero hash in a
+// repository without commits
+func (r *Repo) Head() (plumbing.Hash, error) {
+	ref, err := r.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
+
+// Reset moves the current branch to a commit and resets the index to it,
+// leaving the files in the worktree alone, like git reset --mixed
+func (r *Repo) Reset(hash plumbing.Hash) error {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := wt.Reset(&git.ResetOptions{Commit: hash, Mode: git.MixedReset}); err != nil {
+		return fmt.Errorf("failed to reset to %s: %w", hash, err)
+	}
+	return nil
+}
+
+// relative returns a path relative to the repository root, as the
+// worktree expects
+func (r *Repo) relative(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(r.root)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,319 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/droy-go/droy-pm/pkg/config"
+)
+
+// ErrNoWorkspace is returned when a directory is not part of a workspace
+var ErrNoWorkspace = errors.New("not in a workspace; add a [workspace] table with members to the root droy.toml")
+
+// Workspace is a monorepo: a root droy.toml with a [workspace] table and
+// the member packages it lists. Members share the root's droy_modules and
+// droy.lock
+type Workspace struct {
+	// Root is the absolute path of the directory with the root droy.toml
+	Root    string
+	Package *config.Package
+
+	// Members are sorted so that every member comes after the members it
+	// depends on, and by name otherwise
+	Members []*Member
+}
+
+// Member is a package in a workspace
+type Member struct {
+	// Dir is the member's directory relative to the workspace root, with
+	// forward slashes
+	Dir     string
+	Package *config.Package
+}
+
+// Name returns the member's package name
+func (m *Member) Name() string {
+	return m.Package.Name
+}
+
+// Path returns the absolute path of the member's directory
+func (w *Workspace) Path(m *Member) string {
+	return filepath.Join(w.Root, filepath.FromSlash(m.Dir))
+}
+
+// Load reads the workspace whose root droy.toml is in dir
+func Load(dir string) (*Workspace, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := config.ReadPackageConfig(filepath.Join(root, "droy.toml"))
+	if err != nil {
+		return nil, err
+	}
+	if pkg.Workspace == nil {
+		return nil, ErrNoWorkspace
+	}
+
+	w := &Workspace{Root: root, Package: pkg}
+	seen := make(map[string]string)
+
+	for _, pattern := range pkg.Workspace.Members {
+		dirs, err := memberDirs(root, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rel := range dirs {
+			member, err := config.ReadPackageConfig(filepath.Join(root, filepath.FromSlash(rel), "droy.toml"))
+			if err != nil {
+				return nil, fmt.Errorf("workspace member %s: %w", rel, err)
+			}
+			if member.Name == "" {
+				return nil, fmt.Errorf("workspace member %s has no name", rel)
+			}
+			if other, ok := seen[member.Name]; ok {
+				if other == rel {
+					continue
+				}
+				return nil, fmt.Errorf("workspace members %s and %s are both named %s", other, rel, member.Name)
+			}
+			seen[member.Name] = rel
+
+			w.Members = append(w.Members, &Member{Dir: rel, Package: member})
+		}
+	}
+
+	w.Members = sortMembers(w.Members)
+	return w, nil
+}
+
+// Find returns the workspace that dir belongs to: the nearest enclosing
+// directory whose droy.toml has a [workspace] table, provided dir is its
+// root or inside one of its members
+func Find(dir string) (*Workspace, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for current := abs; ; {
+		pkg, err := config.ReadPackageConfig(filepath.Join(current, "droy.toml"))
+		if err == nil && pkg.Workspace != nil {
+			w, err := Load(current)
+			if err != nil {
+				return nil, err
+			}
+			if current == abs || w.MemberAt(abs) != nil {
+				return w, nil
+			}
+			return nil, ErrNoWorkspace
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return nil, ErrNoWorkspace
+		}
+		current = parent
+	}
+}
+
+// MemberAt returns the member whose directory contains path, or nil
+func (w *Workspace) MemberAt(path string) *Member {
+	for _, m := range w.Members {
+		rel, err := filepath.Rel(w.Path(m), path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return m
+		}
+	}
+	return nil
+}
+
+// Member returns the member with the given package name, or nil
+func (w *Workspace) Member(name string) *Member {
+	for _, m := range w.Members {
+		if m.Name() == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Filter returns the members whose name or directory matches one of the
+// glob patterns, in workspace order. A pattern that matches nothing is an
+// error, so that typos do not silently select fewer members
+func (w *Workspace) Filter(patterns []string) ([]*Member, error) {
+	if len(patterns) == 0 {
+		return w.Members, nil
+	}
+
+	selected := make(map[*Member]bool)
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+		pattern = strings.TrimSuffix(pattern, "/")
+
+		matched := false
+		for _, m := range w.Members {
+			byName, err := path.Match(pattern, m.Name())
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter %q: %w", pattern, err)
+			}
+			byDir, _ := path.Match(pattern, m.Dir)
+			if byName || byDir {
+				selected[m] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no workspace member matches %q", pattern)
+		}
+	}
+
+	var members []*Member
+	for _, m := range w.Members {
+		if selected[m] {
+			members = append(members, m)
+		}
+	}
+	return members, nil
+}
+
+// Local maps each member's name to its manifest, for the resolver
+func (w *Workspace) Local() map[string]*config.Package {
+	local := make(map[string]*config.Package, len(w.Members))
+	for _, m := range w.Members {
+		local[m.Name()] = m.Package
+	}
+	return local
+}
+
+// Dependencies returns what the workspace's droy.lock covers: the root's
+// dependencies and dev dependencies, and every member at its own version.
+// The members' dependencies are reached through the members themselves
+func (w *Workspace) Dependencies() map[string]string {
+	deps := make(map[string]string)
+	for name, spec := range w.Package.Dependencies {
+		deps[name] = spec
+	}
+	for name, spec := range w.Package.DevDependencies {
+		deps[name] = spec
+	}
+
+	// A range in the root keeps precedence so that a member outside it
+	// is reported as a conflict
+	for _, m := range w.Members {
+		if _, ok := deps[m.Name()]; !ok {
+			deps[m.Name()] = m.Package.Version
+		}
+	}
+	return deps
+}
+
+// memberDirs expands a members entry into the directories, relative to
+// root, that contain a droy.toml
+func memberDirs(root, pattern string) ([]string, error) {
+	if filepath.IsAbs(pattern) {
+		return nil, fmt.Errorf("workspace member %q must be relative to the workspace root", pattern)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace member %q: %w", pattern, err)
+	}
+
+	var dirs []string
+	for _, match := range matches {
+		if _, err := os.Stat(filepath.Join(match, "droy.toml")); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, match)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, filepath.ToSlash(rel))
+	}
+
+	// A plain path must exist; a glob may legitimately match nothing yet
+	if len(dirs) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return nil, fmt.Errorf("workspace member %s has no droy.toml", pattern)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// sortMembers orders members so that each comes after the members it
+// depends on, taking the first in name order whenever several could come
+// next. Members in a dependency cycle keep their name order
+func sortMembers(members []*Member) []*Member {
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name() < members[j].Name()
+	})
+
+	byName := make(map[string]*Member, len(members))
+	for _, m := range members {
+		byName[m.Name()] = m
+	}
+
+	// reaches holds the members each member depends on, directly or not
+	reaches := make(map[*Member]map[*Member]bool, len(members))
+	for _, m := range members {
+		seen := make(map[*Member]bool)
+		var visit func(m *Member)
+		visit = func(m *Member) {
+			for _, name := range memberDependencies(m) {
+				if dep := byName[name]; dep != nil && !seen[dep] {
+					seen[dep] = true
+					visit(dep)
+				}
+			}
+		}
+		visit(m)
+		reaches[m] = seen
+	}
+
+	// A member is ready once everything it depends on is placed, except
+	// for members in a cycle with it
+	placed := make(map[*Member]bool, len(members))
+	ready := func(m *Member) bool {
+		for dep := range reaches[m] {
+			if dep != m && !placed[dep] && !reaches[dep][m] {
+				return false
+			}
+		}
+		return true
+	}
+
+	sorted := make([]*Member, 0, len(members))
+	for len(sorted) < len(members) {
+		for _, m := range members {
+			if !placed[m] && ready(m) {
+				placed[m] = true
+				sorted = append(sorted, m)
+				break
+			}
+		}
+	}
+	return sorted
+}
+
+// memberDependencies returns the sorted names of everything a member
+// depends on, including dev dependencies
+func memberDependencies(m *Member) []string {
+	var names []string
+	for name := range m.Package.Dependencies {
+		names = append(names, name)
+	}
+	for name := range m.Package.DevDependencies {
+		if _, ok := m.Package.Dependencies[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
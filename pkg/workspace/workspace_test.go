@@ -0,0 +1,203 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeWorkspace creates a workspace from droy.toml contents keyed by
+// directory, "." being the root
+func writeWorkspace(t *testing.T, manifests map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for dir, manifest := range manifests {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "droy.toml"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func memberNames(members []*Member) string {
+	names := make([]string, 0, len(members))
+	for _, m := range members {
+		names = append(names, m.Name())
+	}
+	return strings.Join(names, " ")
+}
+
+// testWorkspace has members whose dependencies go against name order:
+// app needs web, which needs core; cli needs app through a dev dependency
+func testWorkspace(t *testing.T) string {
+	return writeWorkspace(t, map[string]string{
+		".":              "name = \"root\"\nversion = \"1.0.0\"\n\n[workspace]\nmembers = [\"packages/*\", \"tools/cli\"]\n",
+		"packages/app":   "name = \"app\"\nversion = \"1.0.0\"\n\n[dependencies]\nweb = \"^1.0.0\"\nlodash = \"^4.0.0\"\n",
+		"packages/web":   "name = \"web\"\nversion = \"1.1.0\"\n\n[dependencies]\ncore = \"^2.0.0\"\n",
+		"packages/core":  "name = \"core\"\nversion = \"2.0.0\"\n",
+		"packages/alpha": "name = \"alpha\"\nversion = \"0.1.0\"\n",
+		"tools/cli":      "name = \"cli\"\nversion = \"1.0.0\"\n\n[devDependencies]\napp = \"^1.0.0\"\n",
+	})
+}
+
+func TestLoadOrdersMembersByDependencies(t *testing.T) {
+	root := testWorkspace(t)
+	// Not a member: it has no droy.toml
+	if err := os.MkdirAll(filepath.Join(root, "packages", "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := memberNames(w.Members); got != "alpha core web app cli" {
+		t.Errorf("members = %s, want alpha core web app cli", got)
+	}
+	if m := w.Member("web"); m == nil || m.Dir != "packages/web" {
+		t.Errorf("Member(web) = %+v", m)
+	}
+	if got := w.MemberAt(filepath.Join(root, "tools", "cli", "src")); got == nil || got.Name() != "cli" {
+		t.Errorf("MemberAt(tools/cli/src) = %v, want cli", got)
+	}
+	if got := w.MemberAt(filepath.Join(root, "tools")); got != nil {
+		t.Errorf("MemberAt(tools) = %s, want none", got.Name())
+	}
+}
+
+func TestLoadKeepsNameOrderInCycles(t *testing.T) {
+	root := writeWorkspace(t, map[string]string{
+		".":   "name = \"root\"\n\n[workspace]\nmembers = [\"*\"]\n",
+		"b":   "name = \"b\"\nversion = \"1.0.0\"\n\n[dependencies]\na = \"*\"\n",
+		"a":   "name = \"a\"\nversion = \"1.0.0\"\n\n[dependencies]\nb = \"*\"\n",
+		"top": "name = \"top\"\nversion = \"1.0.0\"\n\n[dependencies]\nb = \"*\"\n",
+	})
+
+	w, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := memberNames(w.Members); got != "a b top" {
+		t.Errorf("members = %s, want a b top", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"no workspace table": {".": "name = \"root\"\n"},
+		"missing member":     {".": "name = \"root\"\n\n[workspace]\nmembers = [\"packages/app\"]\n"},
+		"duplicate names": {
+			".": "name = \"root\"\n\n[workspace]\nmembers = [\"a\", \"b\"]\n",
+			"a": "name = \"lib\"\nversion = \"1.0.0\"\n",
+			"b": "name = \"lib\"\nversion = \"2.0.0\"\n",
+		},
+		"unnamed member": {
+			".": "name = \"root\"\n\n[workspace]\nmembers = [\"a\"]\n",
+			"a": "version = \"1.0.0\"\n",
+		},
+	}
+	for desc, manifests := range tests {
+		if w, err := Load(writeWorkspace(t, manifests)); err == nil {
+			t.Errorf("%s: Load = %s, want an error", desc, memberNames(w.Members))
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := testWorkspace(t)
+	if err := os.MkdirAll(filepath.Join(root, "packages", "app", "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "scripts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{".", "packages/app/src", "tools/cli"} {
+		w, err := Find(filepath.Join(root, filepath.FromSlash(dir)))
+		if err != nil {
+			t.Errorf("Find(%s): %v", dir, err)
+			continue
+		}
+		if w.Root != root {
+			t.Errorf("Find(%s) root = %s, want %s", dir, w.Root, root)
+		}
+	}
+
+	if _, err := Find(filepath.Join(root, "scripts")); err != ErrNoWorkspace {
+		t.Errorf("Find(scripts) = %v, want ErrNoWorkspace", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	w, err := Load(testWorkspace(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		patterns []string
+		want     string
+	}{
+		{nil, "alpha core web app cli"},
+		{[]string{"app"}, "app"},
+		{[]string{"cli", "core"}, "core cli"},
+		{[]string{"packages/*"}, "alpha core web app"},
+		{[]string{"./tools/cli/"}, "cli"},
+		{[]string{"[a-c]*"}, "alpha core app cli"},
+		{[]string{"app", "packages/app"}, "app"},
+	}
+	for _, tt := range tests {
+		members, err := w.Filter(tt.patterns)
+		if err != nil {
+			t.Errorf("Filter(%q): %v", tt.patterns, err)
+			continue
+		}
+		if got := memberNames(members); got != tt.want {
+			t.Errorf("Filter(%q) = %s, want %s", tt.patterns, got, tt.want)
+		}
+	}
+
+	for _, patterns := range [][]string{{"ap"}, {"app", "missing"}, {"[app"}} {
+		if members, err := w.Filter(patterns); err == nil {
+			t.Errorf("Filter(%q) = %s, want an error", patterns, memberNames(members))
+		}
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	root := testWorkspace(t)
+	manifest := "name = \"root\"\n\n[workspace]\nmembers = [\"packages/*\", \"tools/cli\"]\n\n[dependencies]\nweb = \"^1.0.0\"\n\n[devDependencies]\ntest = \"^3.0.0\"\n"
+	if err := os.WriteFile(filepath.Join(root, "droy.toml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"web":   "^1.0.0",
+		"test":  "^3.0.0",
+		"alpha": "0.1.0",
+		"core":  "2.0.0",
+		"app":   "1.0.0",
+		"cli":   "1.0.0",
+	}
+	deps := w.Dependencies()
+	if len(deps) != len(want) {
+		t.Errorf("Dependencies = %v, want %v", deps, want)
+	}
+	for name, spec := range want {
+		if deps[name] != spec {
+			t.Errorf("Dependencies[%s] = %q, want %q", name, deps[name], spec)
+		}
+	}
+}